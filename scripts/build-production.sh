@@ -10,8 +10,10 @@ cp -R www/build dist/www
 rm -rf www/build
 
 cd server
-rm -f audiotic
-go build -o audiotic main.go
+rm -f audiotic audiotic-cli
+go build -o audiotic
+go build -o audiotic-cli ./cmd/audiotic
 cd ..
 cp server/audiotic dist/audiotic
-rm -f server/audiotic
\ No newline at end of file
+cp server/audiotic-cli dist/audiotic-cli
+rm -f server/audiotic server/audiotic-cli
\ No newline at end of file
@@ -1,63 +1,114 @@
 package api
 
 import (
+	"gngeorgiev/audiotic/server/hub"
 	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/zones"
 	"log"
-
 	"sync"
 
-	"github.com/adrg/libvlc-go"
+	vlc "github.com/adrg/libvlc-go/v3"
 )
 
-var (
-	autoplayEnabled  = make(chan struct{})
-	autoplayDisabled = make(chan struct{})
+type autoplay struct {
+	enabled, disabled chan struct{}
+	isEnabled         bool
+}
 
-	isAutoplayEnabled   bool
-	autoplayInitialized bool
-	mutex               sync.Mutex
+var (
+	mutex     sync.Mutex
+	autoplays = make(map[*zones.Zone]*autoplay)
 )
 
-func Autoplay(enabled bool) {
+// Autoplay plays the next track of a zone whenever the current one ends, while enabled
+func Autoplay(zone string, enabled bool) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	if !autoplayInitialized {
-		go initAutoplay()
-		autoplayInitialized = true
+	a, ok := autoplays[z]
+	if !ok {
+		a = &autoplay{
+			enabled:  make(chan struct{}),
+			disabled: make(chan struct{}),
+		}
+		autoplays[z] = a
+		go a.run(z)
 	}
 
-	if isAutoplayEnabled == enabled {
-		return
+	if a.isEnabled == enabled {
+		return nil
 	}
 
+	toggle := a.disabled
 	if enabled {
-		autoplayEnabled <- struct{}{}
-	} else {
-		autoplayDisabled <- struct{}{}
+		toggle = a.enabled
+	}
+
+	select {
+	case toggle <- struct{}{}:
+		a.isEnabled = enabled
+		go notifyModes(z.Name)
+		return nil
+	case <-z.Done():
+		return zoneError(zones.ErrNotFound, zone)
 	}
 }
 
-func initAutoplay() {
+func IsAutoplayEnabled(zone string) bool {
+	z, ok := zones.Get(zone)
+	if !ok {
+		return false
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	a, ok := autoplays[z]
+	return ok && a.isEnabled
+}
+
+func (a *autoplay) run(z *zones.Zone) {
+	defer func() {
+		mutex.Lock()
+		delete(autoplays, z)
+		mutex.Unlock()
+	}()
+
 	for {
-		<-autoplayEnabled
-		p := player.Get()
-		updatesCh := make(chan *player.VlcStatus)
-		p.OnUpdated(updatesCh)
+		select {
+		case <-a.enabled:
+		case <-z.Done():
+			return
+		}
+
+		updates, unsubscribe := z.Player.Subscribe(hub.Options{
+			Name:       "autoplay",
+			BufferSize: 16,
+			Policy:     hub.DropOldest,
+		})
 
+	loop:
 		for {
 			select {
-			case status := <-updatesCh:
-				t := p.Track()
-				if status.State == player.MediaStateToString(vlc.MediaEnded) {
-					//if st.Time >= st.Duration && t.Provider != "" && t.Next != "" {
-					if err := Play(t.Provider, t.Next); err != nil {
+			case msg := <-updates:
+				status := msg.(*player.VlcStatus)
+				if status != nil && status.State == player.MediaStateToString(vlc.MediaEnded) {
+					if err := Next(z.Name); err != nil {
 						log.Println(err)
+						notifyError(z.Name, err)
 					}
 				}
-			case <-autoplayDisabled:
-				close(updatesCh)
-				break
+			case <-a.disabled:
+				unsubscribe()
+				break loop
+			case <-z.Done():
+				unsubscribe()
+				return
 			}
 		}
 	}
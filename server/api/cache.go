@@ -0,0 +1,67 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/cache"
+	"gngeorgiev/audiotic/server/metadata"
+	"gngeorgiev/audiotic/server/zones"
+)
+
+func cacheError(err error, id string) error {
+	details := map[string]interface{}{"id": id}
+	switch err {
+	case cache.ErrDisabled:
+		return newError(CodeInvalidState, nil, "The cache is disabled")
+	case cache.ErrNotFound:
+		return newError(CodeNotFound, details, "Track %s isn't cached", id)
+	default:
+		return err
+	}
+}
+
+// CachedInUse returns the cache ids of the current tracks of the zones, the players have their files open
+func CachedInUse() []string {
+	var res []string
+	for _, z := range zones.All() {
+		if t := z.Player.Track(); t.ID != "" {
+			res = append(res, cache.Key(t.Provider, t.ID))
+		}
+	}
+
+	return res
+}
+
+func CacheStats() (cache.Stats, error) {
+	s, err := cache.All()
+	return s, cacheError(err, "")
+}
+
+// CacheTrack downloads a track to the cache in the background
+func CacheTrack(providerName, id string, pinned bool) error {
+	if !cache.Enabled() {
+		return cacheError(cache.ErrDisabled, "")
+	}
+
+	provider, err := getProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	track, err := provider.Resolve(id)
+	if err != nil {
+		return err
+	}
+
+	metadata.Enrich(&track)
+	return cacheError(cache.Fetch(track, pinned), cache.Key(providerName, id))
+}
+
+func PinCached(providerName, id string, pinned bool) (cache.Entry, error) {
+	key := cache.Key(providerName, id)
+	e, err := cache.Pin(key, pinned)
+	return e, cacheError(err, key)
+}
+
+func RemoveCached(providerName, id string) error {
+	key := cache.Key(providerName, id)
+	return cacheError(cache.Remove(key), key)
+}
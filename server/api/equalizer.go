@@ -0,0 +1,192 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/equalizer"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/settings"
+	"gngeorgiev/audiotic/server/zones"
+)
+
+const (
+	MinEqualizerGain = -20.0
+	MaxEqualizerGain = 20.0
+)
+
+// EqualizerChange picks the gains of a zone's equalizer, the gains of a preset or profile
+// are used as the base and Preamp and Bands, when set, override them
+type EqualizerChange struct {
+	Preset  string    `json:"preset"`
+	Profile string    `json:"profile"`
+	Preamp  *float64  `json:"preamp"`
+	Bands   []float64 `json:"bands"`
+}
+
+type EqualizerPresets struct {
+	// Frequencies are the frequencies of the bands in Hz
+	Frequencies []float64          `json:"frequencies"`
+	Presets     []player.Equalizer `json:"presets"`
+}
+
+func GetEqualizerPresets() (EqualizerPresets, error) {
+	presets, err := player.EqualizerPresets()
+	if err != nil {
+		return EqualizerPresets{}, err
+	}
+
+	return EqualizerPresets{
+		Frequencies: player.EqualizerBands(),
+		Presets:     presets,
+	}, nil
+}
+
+// Equalizer returns the equalizer of a zone, nil when it's off
+func Equalizer(zone string) (*player.Equalizer, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.Player.Equalizer(), nil
+}
+
+// SetEqualizer turns on the equalizer of a zone with the picked gains and remembers them
+func SetEqualizer(zone string, c EqualizerChange) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	e, err := resolveEqualizer(c)
+	if err != nil {
+		return err
+	}
+
+	return saveEqualizer(z, e)
+}
+
+func DisableEqualizer(zone string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	return saveEqualizer(z, nil)
+}
+
+func saveEqualizer(z *zones.Zone, e *player.Equalizer) error {
+	if err := z.Player.SetEqualizer(e); err != nil {
+		return err
+	}
+
+	return settings.Update(z.Name, func(s *settings.Settings) {
+		s.Equalizer = e
+	})
+}
+
+func resolveEqualizer(c EqualizerChange) (*player.Equalizer, error) {
+	if c.Preset != "" && c.Profile != "" {
+		return nil, newError(CodeInvalidArgument, map[string]interface{}{"fields": []string{"preset", "profile"}},
+			"Only one of preset and profile can be set")
+	}
+
+	e := &player.Equalizer{Bands: make([]float64, len(player.EqualizerBands()))}
+	switch {
+	case c.Preset != "":
+		presets, err := player.EqualizerPresets()
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, p := range presets {
+			if p.Preset == c.Preset {
+				*e = p
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, newError(CodeNotFound, map[string]interface{}{"preset": c.Preset}, "Unknown equalizer preset - %s", c.Preset)
+		}
+	case c.Profile != "":
+		p, err := GetEqualizerProfile(c.Profile)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Preset = p.Name
+		e.Preamp = p.Preamp
+		copy(e.Bands, p.Bands)
+	}
+
+	if c.Preamp != nil {
+		e.Preamp = *c.Preamp
+		e.Preset = ""
+	}
+
+	if c.Bands != nil {
+		e.Bands = c.Bands
+		e.Preset = ""
+	}
+
+	if err := validateGains(e.Preamp, e.Bands); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func validateGains(preamp float64, bands []float64) error {
+	if count := len(player.EqualizerBands()); len(bands) != count {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "bands",
+			"count": count,
+		}, "The equalizer has %d bands", count)
+	}
+
+	for _, gain := range append([]float64{preamp}, bands...) {
+		if gain < MinEqualizerGain || gain > MaxEqualizerGain {
+			return newError(CodeInvalidArgument, map[string]interface{}{
+				"min": MinEqualizerGain,
+				"max": MaxEqualizerGain,
+			}, "Gains must be between %v and %v dB", MinEqualizerGain, MaxEqualizerGain)
+		}
+	}
+
+	return nil
+}
+
+func EqualizerProfiles() ([]equalizer.Profile, error) {
+	return equalizer.Profiles()
+}
+
+func GetEqualizerProfile(name string) (equalizer.Profile, error) {
+	p, err := equalizer.Get(name)
+	if err == equalizer.ErrNotFound {
+		return p, newError(CodeNotFound, map[string]interface{}{"profile": name}, "Unknown equalizer profile - %s", name)
+	}
+
+	return p, err
+}
+
+func SaveEqualizerProfile(p equalizer.Profile) (equalizer.Profile, error) {
+	if p.Name == "" {
+		return p, newError(CodeInvalidArgument, map[string]interface{}{"field": "name"}, "Missing required field name")
+	}
+
+	if err := validateGains(p.Preamp, p.Bands); err != nil {
+		return p, err
+	}
+
+	return equalizer.Save(p)
+}
+
+func RemoveEqualizerProfile(name string) error {
+	err := equalizer.Remove(name)
+	if err == equalizer.ErrNotFound {
+		return newError(CodeNotFound, map[string]interface{}{"profile": name}, "Unknown equalizer profile - %s", name)
+	}
+
+	return err
+}
@@ -0,0 +1,44 @@
+package api
+
+import "fmt"
+
+const (
+	CodeInvalidArgument = "invalid_argument"
+	CodeInvalidState    = "invalid_state"
+	CodeNotFound        = "not_found"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeInternal        = "internal"
+)
+
+// Error is returned by the api functions when the failure can be described to the client,
+// e.g. a value out of range or an unknown provider.
+type Error struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code string, details map[string]interface{}, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Details: details,
+	}
+}
+
+// ToError converts any error to an *Error, wrapping unknown errors as internal ones
+func ToError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	return &Error{
+		Code:    CodeInternal,
+		Message: err.Error(),
+	}
+}
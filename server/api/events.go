@@ -0,0 +1,27 @@
+package api
+
+import "gngeorgiev/audiotic/server/hub"
+
+// ZoneError is a failure of an operation nobody waits on, e.g. autoplay, in the zone it happened in
+type ZoneError struct {
+	Zone  string
+	Error *Error
+}
+
+var errorsHub = hub.New("errors")
+
+// SubscribeErrors returns a channel receiving a ZoneError for every failure of an operation nobody waits on
+func SubscribeErrors(o hub.Options) (<-chan interface{}, func()) {
+	return errorsHub.Subscribe(o)
+}
+
+func ErrorsMetrics() hub.Metrics {
+	return errorsHub.Metrics()
+}
+
+func notifyError(zone string, err error) {
+	errorsHub.Publish(ZoneError{
+		Zone:  zone,
+		Error: ToError(err),
+	})
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/cache"
+	"gngeorgiev/audiotic/server/export"
+	"gngeorgiev/audiotic/server/metadata"
+	"gngeorgiev/audiotic/server/models"
+)
+
+// ExportTrack returns a track with its stream url to be downloaded, the cached tracks are read from the disk
+func ExportTrack(providerName, id string) (models.Track, error) {
+	provider, err := getProvider(providerName)
+	if err != nil {
+		return models.Track{}, err
+	}
+
+	if t, ok := cache.Get(providerName, id); ok {
+		return t, nil
+	}
+
+	t, err := provider.Resolve(id)
+	if err != nil {
+		return models.Track{}, err
+	}
+
+	metadata.Enrich(&t)
+	return t, nil
+}
+
+// ExportFormat returns the format the tracks are transcoded to, ffmpeg has to be available
+func ExportFormat(name string) (export.Format, error) {
+	f, ok := export.Get(name)
+	if !ok {
+		return export.Format{}, newError(CodeInvalidArgument, map[string]interface{}{
+			"format":  name,
+			"formats": export.Formats,
+		}, "Unknown format %s", name)
+	}
+
+	if !export.Available() {
+		return export.Format{}, newError(CodeInvalidState, nil, "Transcoding needs ffmpeg, which wasn't found")
+	}
+
+	return f, nil
+}
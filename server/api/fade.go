@@ -0,0 +1,38 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/settings"
+	"time"
+)
+
+const (
+	MinFadeLength = 0
+	// MaxFadeLength keeps the ramps short, in milliseconds
+	MaxFadeLength = 5000
+)
+
+// SetFadeLength sets the length of the ramps around pause, resume, stop and track changes of a zone in milliseconds,
+// 0 turns them off. The length is remembered
+func SetFadeLength(zone string, length int) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	if length < MinFadeLength || length > MaxFadeLength {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "length",
+			"min":   MinFadeLength,
+			"max":   MaxFadeLength,
+		}, "Fade length must be between %d and %d ms", MinFadeLength, MaxFadeLength)
+	}
+
+	if err := settings.Update(z.Name, func(s *settings.Settings) {
+		s.FadeLength = &length
+	}); err != nil {
+		return err
+	}
+
+	z.Player.SetFadeLength(time.Duration(length) * time.Millisecond)
+	return nil
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/lyrics"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/zones"
+	"log"
+)
+
+// Lyrics returns the lyrics of the track playing in a zone
+func Lyrics(zone string) (lyrics.Lyrics, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return lyrics.Lyrics{}, err
+	}
+
+	t := z.Player.Track()
+	if t.ID == "" {
+		return lyrics.Lyrics{}, newError(CodeNotFound, map[string]interface{}{"zone": zone}, "Nothing was played in zone %s", zone)
+	}
+
+	l, err := lyrics.Get(t)
+	if err == lyrics.ErrNotFound {
+		return lyrics.Lyrics{}, newError(CodeNotFound, map[string]interface{}{
+			"provider": t.Provider,
+			"id":       t.ID,
+		}, "No lyrics found for %s", t.Title)
+	}
+
+	if err != nil {
+		return lyrics.Lyrics{}, err
+	}
+
+	if l.Synced {
+		z.Player.SetLyrics(t.StreamUrl, l.Times())
+	}
+
+	return l, nil
+}
+
+// syncLyrics finds the lyrics of a track played in a zone, the status follows the lines of the synced ones
+func syncLyrics(z *zones.Zone, t models.Track) {
+	l, err := lyrics.Get(t)
+	if err != nil {
+		if err != lyrics.ErrNotFound {
+			log.Println(err)
+		}
+		return
+	}
+
+	if l.Synced {
+		z.Player.SetLyrics(t.StreamUrl, l.Times())
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/settings"
+	"log"
+)
+
+// Modes are the playback settings of a zone which outlive the current track
+type Modes struct {
+	Autoplay      bool   `json:"autoplay"`
+	Normalization string `json:"normalization"`
+}
+
+// ZoneModes are the modes of a zone after one of them changed
+type ZoneModes struct {
+	Zone  string
+	Modes Modes
+}
+
+var modesHub = hub.New("modes")
+
+// SubscribeModes returns a channel receiving ZoneModes every time a mode of a zone changes
+func SubscribeModes(o hub.Options) (<-chan interface{}, func()) {
+	return modesHub.Subscribe(o)
+}
+
+func ModesMetrics() hub.Metrics {
+	return modesHub.Metrics()
+}
+
+func GetModes(zone string) Modes {
+	return Modes{
+		Autoplay:      IsAutoplayEnabled(zone),
+		Normalization: normalizationMode(zone),
+	}
+}
+
+func notifyModes(zone string) {
+	modesHub.Publish(ZoneModes{
+		Zone:  zone,
+		Modes: GetModes(zone),
+	})
+}
+
+func normalizationMode(zone string) string {
+	s, err := settings.Get(zone)
+	if err != nil {
+		log.Println(err)
+		return NormalizationOff
+	}
+
+	if s.Normalization == "" {
+		return NormalizationOff
+	}
+
+	return s.Normalization
+}
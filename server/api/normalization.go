@@ -0,0 +1,81 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/loudness"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/settings"
+	"gngeorgiev/audiotic/server/zones"
+	"log"
+)
+
+const (
+	// NormalizationTrack brings every track to the same loudness
+	NormalizationTrack = "track"
+	NormalizationOff   = "off"
+)
+
+func NormalizationModes() []string {
+	return []string{NormalizationTrack, NormalizationOff}
+}
+
+// SetNormalization switches the loudness normalization of a zone, the gain of the current track changes right away
+func SetNormalization(zone, mode string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	if mode != NormalizationTrack && mode != NormalizationOff {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field":   "mode",
+			"allowed": NormalizationModes(),
+		}, "Unknown normalization mode - %s", mode)
+	}
+
+	if err := settings.Update(z.Name, func(s *settings.Settings) {
+		s.Normalization = mode
+	}); err != nil {
+		return err
+	}
+
+	if err := z.Player.SetGain(trackGain(z, z.Player.Track())); err != nil {
+		return err
+	}
+
+	notifyModes(z.Name)
+	return nil
+}
+
+// trackGain returns the gain normalizing the loudness of a track in a zone, 0 when normalization is off
+// or the loudness is unknown. The loudness reported by the provider is cached for the next time
+func trackGain(z *zones.Zone, t models.Track) float64 {
+	if t.Provider == "" || normalizationMode(z.Name) != NormalizationTrack {
+		return 0
+	}
+
+	l, ok, err := loudness.Get(t.Provider, t.ID)
+	if err != nil {
+		log.Println(err)
+	}
+
+	if !ok {
+		if t.Loudness == nil {
+			return 0
+		}
+
+		l = *t.Loudness
+	}
+
+	return loudness.Gain(l)
+}
+
+// cacheLoudness saves the loudness reported by the provider, so it's known even when the provider can't tell it next time
+func cacheLoudness(t models.Track) {
+	if t.Loudness == nil {
+		return
+	}
+
+	if err := loudness.Save(t.Provider, t.ID, *t.Loudness); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/settings"
+)
+
+func AudioOutputs(zone string) (player.AudioOutputs, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return player.AudioOutputs{}, err
+	}
+
+	return z.Player.AudioOutputs()
+}
+
+// SetAudioOutput switches the output of a zone and remembers the choice, empty values keep the current ones.
+// Only the devices of the active output module are known, so the device is validated only when the module stays
+func SetAudioOutput(zone, output, device string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := z.Player.AudioOutputs()
+	if err != nil {
+		return err
+	}
+
+	if output != "" && !hasOutput(outputs.Outputs, output) {
+		return newError(CodeNotFound, map[string]interface{}{"output": output}, "Unknown audio output - %s", output)
+	}
+
+	if device != "" && (output == "" || output == outputs.Output) && !hasDevice(outputs.Devices, device) {
+		return newError(CodeNotFound, map[string]interface{}{"device": device}, "Unknown audio device - %s", device)
+	}
+
+	if err := z.Player.SetAudioOutput(output, device); err != nil {
+		return err
+	}
+
+	return settings.Update(z.Name, func(s *settings.Settings) {
+		rememberOutput(s, output, device)
+	})
+}
+
+// rememberOutput stores the output in the settings the way the player switches it, the device of
+// a previous module is forgotten when the module changes
+func rememberOutput(s *settings.Settings, output, device string) {
+	if output != "" && output != s.Output {
+		s.Output = output
+		s.OutputDevice = ""
+	}
+
+	if device != "" {
+		s.OutputDevice = device
+	}
+}
+
+func hasOutput(outputs []player.AudioOutput, name string) bool {
+	for _, o := range outputs {
+		if o.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasDevice(devices []player.AudioDevice, id string) bool {
+	for _, d := range devices {
+		if d.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/settings"
+	"testing"
+)
+
+func TestRememberOutput(t *testing.T) {
+	for _, c := range []struct {
+		name           string
+		saved          settings.Settings
+		output, device string
+		expected       settings.Settings
+	}{
+		{"first output", settings.Settings{}, "alsa", "", settings.Settings{Output: "alsa"}},
+		{"output and device", settings.Settings{}, "alsa", "hw:1,0", settings.Settings{Output: "alsa", OutputDevice: "hw:1,0"}},
+		{"new module", settings.Settings{Output: "alsa", OutputDevice: "hw:1,0"}, "pulse", "", settings.Settings{Output: "pulse"}},
+		{"new module and device", settings.Settings{Output: "alsa", OutputDevice: "hw:1,0"}, "pulse", "sink", settings.Settings{Output: "pulse", OutputDevice: "sink"}},
+		{"same module", settings.Settings{Output: "alsa", OutputDevice: "hw:1,0"}, "alsa", "", settings.Settings{Output: "alsa", OutputDevice: "hw:1,0"}},
+		{"device only", settings.Settings{Output: "alsa", OutputDevice: "hw:1,0"}, "", "hw:2,0", settings.Settings{Output: "alsa", OutputDevice: "hw:2,0"}},
+	} {
+		s := c.saved
+		rememberOutput(&s, c.output, c.device)
+		if s.Output != c.expected.Output || s.OutputDevice != c.expected.OutputDevice {
+			t.Errorf("%s: expected %s on %q, got %s on %q", c.name, c.expected.Output, c.expected.OutputDevice, s.Output, s.OutputDevice)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package api
 
-import "gngeorgiev/audiotic/server/player"
+func Pause(zone string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
 
-func Pause() error {
-	return player.Get().Pause()
+	return z.Player.Pause()
 }
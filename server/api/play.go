@@ -1,9 +1,13 @@
 package api
 
 import (
-	"fmt"
+	"gngeorgiev/audiotic/server/cache"
+	"gngeorgiev/audiotic/server/metadata"
+	"gngeorgiev/audiotic/server/models"
 	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/profiles"
 	"gngeorgiev/audiotic/server/providers"
+	"gngeorgiev/audiotic/server/zones"
 	"strings"
 
 	"gngeorgiev/audiotic/server/history"
@@ -11,35 +15,119 @@ import (
 	"time"
 
 	"log"
-
-	"github.com/go-errors/errors"
 )
 
-func Play(providerName, id string) error {
+func getProvider(providerName string) (providers.Provider, error) {
 	p := providers.Container().GetComponent(func(p interface{}) bool {
 		provider := p.(providers.Provider)
 		return strings.ToLower(provider.GetName()) == strings.ToLower(providerName)
 	})
 
 	if p == nil {
-		return errors.New(fmt.Sprintf("Unknown provider - %s", providerName))
+		return nil, newError(CodeNotFound, map[string]interface{}{
+			"provider": providerName,
+		}, "Unknown provider - %s", providerName)
+	}
+
+	return p.(providers.Provider), nil
+}
+
+// Resolve returns the track info without the stream url, which expires and is resolved again when the track is played
+func Resolve(providerName, id string) (models.Track, error) {
+	provider, err := getProvider(providerName)
+	if err != nil {
+		return models.Track{}, err
 	}
 
-	provider := p.(providers.Provider)
 	track, err := provider.Resolve(id)
+	if err != nil {
+		return models.Track{}, err
+	}
+
+	track.StreamUrl = ""
+	metadata.Fill(&track)
+	return track, nil
+}
+
+// Play resolves and plays a track in a zone on behalf of queuedBy, the name of the user, empty when auth is disabled
+func Play(zone, providerName, id, queuedBy string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	return play(z, providerName, id, queuedBy, false)
+}
+
+func play(z *zones.Zone, providerName, id, queuedBy string, autoplayed bool) error {
+	provider, err := getProvider(providerName)
 	if err != nil {
 		return err
 	}
 
-	if err := player.Get().Play(track); err != nil {
+	// the cached tracks are played from the disk, they were enriched before they were cached. The others play with
+	// the metadata parsed from their title while it's looked up
+	track, cached := cache.Get(providerName, id)
+	if !cached {
+		if track, err = provider.Resolve(id); err != nil {
+			return err
+		}
+
+		metadata.Fill(&track)
+	}
+
+	track.QueuedBy = queuedBy
+	track.Autoplayed = autoplayed
+	cacheLoudness(track)
+	// the gain and the rate are applied once the track starts, the track playing until then keeps its own
+	o := player.PlayOptions{Gain: trackGain(z, track), Rate: trackRate(track)}
+	if err := z.Player.Play(track, o); err != nil {
 		log.Println(err)
 	}
 
-	track.StreamUrl = ""
 	track.LastPlayed = time.Now()
+	err = addToHistory(track, queuedBy, autoplayed)
+
+	// the lookup starts once the play is in the history, so the looked up metadata replaces the parsed one there
+	go enrich(z, track, cached, profiles.Name(queuedBy))
+	return err
+}
+
+func addToHistory(track models.Track, queuedBy string, autoplayed bool) error {
+	track.StreamUrl = ""
 	if err := history.Add(&track); err != nil {
 		return err
 	}
 
+	if !autoplayed {
+		if err := history.AddPlay(profiles.Name(queuedBy), track); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// enrich looks up the metadata of a track played in a zone and updates the status and the history with it.
+// The lyrics are found and the track is cached afterwards, both use the looked up metadata
+func enrich(z *zones.Zone, track models.Track, cached bool, user string) {
+	if !cached {
+		parsed := track
+		metadata.Enrich(&track)
+
+		if track != parsed {
+			z.Player.UpdateTrack(track)
+			if err := history.Update(user, track); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	syncLyrics(z, track)
+
+	if !cached && cache.Enabled() {
+		if err := cache.Fetch(track, false); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/profiles"
+)
+
+func Queue(zone string) ([]models.Track, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.Queue.Get(), nil
+}
+
+func Enqueue(zone, providerName, id, queuedBy string) (models.Track, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return models.Track{}, err
+	}
+
+	track, err := Resolve(providerName, id)
+	if err != nil {
+		return models.Track{}, err
+	}
+
+	track.QueuedBy = queuedBy
+	z.Queue.Add(track)
+	return track, nil
+}
+
+func Dequeue(zone string, index int) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	length := len(z.Queue.Get())
+	if index < 0 || index >= length {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "index",
+			"min":   0,
+			"max":   length - 1,
+		}, "Index must be between 0 and %d", length-1)
+	}
+
+	return z.Queue.Remove(index)
+}
+
+func ClearQueue(zone string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	z.Queue.Clear()
+	return nil
+}
+
+// Next plays the first queued track of the zone or, when the queue is empty, a track picked by the profile
+// of whoever queued the current one, falling back to the track the provider suggested
+func Next(zone string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	if t, ok := z.Queue.Pop(); ok {
+		return play(z, t.Provider, t.ID, t.QueuedBy, false)
+	}
+
+	t := z.Player.Track()
+	if seed, ok := profiles.SeedTrack(profiles.Name(t.QueuedBy), t); ok {
+		return play(z, seed.Provider, seed.ID, t.QueuedBy, true)
+	}
+
+	if t.Provider == "" || t.Next == "" {
+		return newError(CodeInvalidState, nil, "There is no next track")
+	}
+
+	return play(z, t.Provider, t.Next, t.QueuedBy, true)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/trackStore"
+	"log"
+)
+
+const (
+	MinRate = 0.5
+	MaxRate = 3.0
+)
+
+// rates are the playback rates remembered for the tracks, e.g. for a podcast always played faster
+var rates = trackStore.New("rate")
+
+// Rate sets the playback rate of a zone, the pitch stays the same. When remember is set the rate is used
+// every time the current track is played, remembering the normal rate forgets it
+func Rate(zone string, rate float64, remember bool) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	if rate < MinRate || rate > MaxRate {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "rate",
+			"min":   MinRate,
+			"max":   MaxRate,
+		}, "Rate must be between %g and %g", MinRate, MaxRate)
+	}
+
+	if remember {
+		t := z.Player.Track()
+		if t.Provider == "" {
+			return newError(CodeInvalidState, nil, "Nothing is playing")
+		}
+
+		if rate == 1 {
+			err = rates.Remove(t.Provider, t.ID)
+		} else {
+			err = rates.Save(t.Provider, t.ID, rate)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return z.Player.Rate(rate)
+}
+
+// trackRate returns the remembered rate of a track, tracks without one play at the normal rate
+func trackRate(t models.Track) float64 {
+	r, ok, err := rates.Get(t.Provider, t.ID)
+	if err != nil {
+		log.Println(err)
+	}
+
+	if !ok {
+		return 1
+	}
+
+	return r
+}
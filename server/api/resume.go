@@ -1,7 +1,10 @@
 package api
 
-import "gngeorgiev/audiotic/server/player"
+func Resume(zone string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
 
-func Resume() error {
-	return player.Get().Resume()
+	return z.Player.Resume()
 }
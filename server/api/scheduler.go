@@ -0,0 +1,406 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/scheduler"
+	"gngeorgiev/audiotic/server/zones"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	MaxSleepMinutes = 24 * 60
+	// MaxFade is the longest fade out or ramp up of a job in seconds
+	MaxFade = 10 * 60
+)
+
+// JobRequest describes a new sleep timer or alarm, the fields of the other kind are ignored
+type JobRequest struct {
+	Kind string `json:"kind"`
+	// Minutes until a sleep timer stops the playback, unused when AfterTrack is set
+	Minutes    int               `json:"minutes"`
+	AfterTrack bool              `json:"afterTrack"`
+	Fade       int               `json:"fade"`
+	Time       string            `json:"time"`
+	Days       []string          `json:"days"`
+	Tracks     []scheduler.Track `json:"tracks"`
+	Volume     int               `json:"volume"`
+}
+
+var (
+	jobsMutex sync.Mutex
+	jobs      []scheduler.Job
+
+	schedulerInterval = time.Second
+)
+
+// StartScheduler loads the jobs and fires them once they are due. Sleep timers which expired while the server
+// was down and the jobs of zones which no longer exist are dropped, missed alarms ring the next time
+func StartScheduler() error {
+	all, err := scheduler.All()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	for _, j := range all {
+		_, zoneExists := zones.Get(j.Zone)
+		if !zoneExists || (j.Kind == scheduler.KindSleep && !j.AfterTrack && j.At.Before(now)) {
+			if err := scheduler.Remove(j.ID); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if j.Kind == scheduler.KindAlarm && j.At.Before(now) {
+			if j.At, err = j.NextAlarm(now); err != nil {
+				return err
+			}
+
+			if err := scheduler.Save(&j); err != nil {
+				return err
+			}
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	for _, z := range zones.All() {
+		syncTimers(z.Name)
+	}
+
+	go runScheduler()
+	return nil
+}
+
+// Jobs returns the sleep timers and alarms of a zone
+func Jobs(zone string) ([]scheduler.Job, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	res := make([]scheduler.Job, 0)
+	for _, j := range jobs {
+		if j.Zone == z.Name {
+			res = append(res, j)
+		}
+	}
+
+	return res, nil
+}
+
+// CreateJob schedules a sleep timer or an alarm in a zone on behalf of createdBy
+func CreateJob(zone string, r JobRequest, createdBy string) (scheduler.Job, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return scheduler.Job{}, err
+	}
+
+	if r.Fade < 0 || r.Fade > MaxFade {
+		return scheduler.Job{}, newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "fade",
+			"min":   0,
+			"max":   MaxFade,
+		}, "Fade must be between 0 and %d seconds", MaxFade)
+	}
+
+	now := time.Now()
+	j := scheduler.Job{
+		Zone:      z.Name,
+		Kind:      r.Kind,
+		Fade:      r.Fade,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}
+
+	switch r.Kind {
+	case scheduler.KindSleep:
+		if err := sleepJob(&j, r, now); err != nil {
+			return scheduler.Job{}, err
+		}
+	case scheduler.KindAlarm:
+		if err := alarmJob(&j, r, now); err != nil {
+			return scheduler.Job{}, err
+		}
+	default:
+		return scheduler.Job{}, newError(CodeInvalidArgument, map[string]interface{}{
+			"field":   "kind",
+			"allowed": []string{scheduler.KindSleep, scheduler.KindAlarm},
+		}, "Unknown job kind - %s", r.Kind)
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	if err := scheduler.Save(&j); err != nil {
+		return scheduler.Job{}, err
+	}
+
+	jobs = append(jobs, j)
+	syncTimers(z.Name)
+	return j, nil
+}
+
+func sleepJob(j *scheduler.Job, r JobRequest, now time.Time) error {
+	if r.AfterTrack {
+		j.AfterTrack = true
+		return nil
+	}
+
+	if r.Minutes < 1 || r.Minutes > MaxSleepMinutes {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "minutes",
+			"min":   1,
+			"max":   MaxSleepMinutes,
+		}, "Minutes must be between 1 and %d", MaxSleepMinutes)
+	}
+
+	j.At = now.Add(time.Duration(r.Minutes) * time.Minute)
+	return nil
+}
+
+func alarmJob(j *scheduler.Job, r JobRequest, now time.Time) error {
+	if _, _, err := scheduler.ParseTime(r.Time); err != nil {
+		return newError(CodeInvalidArgument, map[string]interface{}{"field": "time"}, err.Error())
+	}
+
+	for _, d := range r.Days {
+		if !scheduler.IsDay(d) {
+			return newError(CodeInvalidArgument, map[string]interface{}{
+				"field":   "days",
+				"allowed": scheduler.Days,
+			}, "Unknown day - %s", d)
+		}
+	}
+
+	if len(r.Tracks) == 0 {
+		return newError(CodeInvalidArgument, map[string]interface{}{"field": "tracks"}, "An alarm needs at least one track")
+	}
+
+	for _, t := range r.Tracks {
+		if _, err := getProvider(t.Provider); err != nil {
+			return err
+		}
+	}
+
+	if r.Volume < MinVolume || r.Volume > MaxVolume {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "volume",
+			"min":   MinVolume,
+			"max":   MaxVolume,
+		}, "Volume must be between %d and %d", MinVolume, MaxVolume)
+	}
+
+	j.Time = r.Time
+	j.Days = r.Days
+	j.Tracks = r.Tracks
+	j.Volume = r.Volume
+
+	var err error
+	j.At, err = j.NextAlarm(now)
+	return err
+}
+
+// CancelJob removes a sleep timer or an alarm of a zone
+func CancelJob(zone string, id int) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	for i, j := range jobs {
+		if j.ID == id && j.Zone == z.Name {
+			if err := scheduler.Remove(id); err != nil {
+				return err
+			}
+
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			syncTimers(z.Name)
+			return nil
+		}
+	}
+
+	return newError(CodeNotFound, map[string]interface{}{"id": id}, "Unknown job - %d", id)
+}
+
+// removeZoneJobs drops the jobs of a removed zone
+func removeZoneJobs(zone string) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	kept := jobs[:0]
+	for _, j := range jobs {
+		if j.Zone != zone {
+			kept = append(kept, j)
+			continue
+		}
+
+		if err := scheduler.Remove(j.ID); err != nil {
+			log.Println(err)
+		}
+	}
+
+	jobs = kept
+}
+
+func runScheduler() {
+	t := time.NewTicker(schedulerInterval)
+	for now := range t.C {
+		for _, j := range dueJobs(now) {
+			go fireJob(j)
+		}
+	}
+}
+
+// dueJobs returns the jobs to fire now, sleep timers are removed and alarms are moved to the next time they ring
+func dueJobs(now time.Time) []scheduler.Job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	var due []scheduler.Job
+	kept := jobs[:0]
+	changed := make(map[string]bool)
+	for _, j := range jobs {
+		if !isDue(j, now) {
+			kept = append(kept, j)
+			continue
+		}
+
+		due = append(due, j)
+		changed[j.Zone] = true
+
+		if j.Kind == scheduler.KindSleep {
+			if err := scheduler.Remove(j.ID); err != nil {
+				log.Println(err)
+			}
+
+			continue
+		}
+
+		next := j
+		var err error
+		if next.At, err = j.NextAlarm(now); err != nil {
+			log.Println(err)
+		} else if err := scheduler.Save(&next); err != nil {
+			log.Println(err)
+		}
+
+		kept = append(kept, next)
+	}
+
+	jobs = kept
+	for zone := range changed {
+		syncTimers(zone)
+	}
+
+	return due
+}
+
+// isDue tells whether a job fires now, sleep timers waiting for the end of the track fire early enough to fade out
+// and stop it before autoplay moves to the next track
+func isDue(j scheduler.Job, now time.Time) bool {
+	if !j.AfterTrack {
+		return !j.At.After(now)
+	}
+
+	z, ok := zones.Get(j.Zone)
+	if !ok {
+		return false
+	}
+
+	status, err := z.Player.Status()
+	if err != nil || status == nil || !status.IsPlaying || status.Duration <= 0 {
+		return false
+	}
+
+	left := j.Fade
+	if left < 1 {
+		left = 1
+	}
+
+	return status.Duration-status.Time <= left
+}
+
+func fireJob(j scheduler.Job) {
+	if err := runJob(j); err != nil {
+		log.Println(err)
+		notifyError(j.Zone, err)
+	}
+}
+
+func runJob(j scheduler.Job) error {
+	z, err := Zone(j.Zone)
+	if err != nil {
+		return err
+	}
+
+	fade := time.Duration(j.Fade) * time.Second
+	if j.Kind == scheduler.KindSleep {
+		return z.Player.FadeOutAndStop(fade)
+	}
+
+	// the alarm starts muted and ramps up to the volume
+	if err := z.Player.Fade(0, 0); err != nil {
+		return err
+	}
+
+	if j.Volume > 0 {
+		if err := z.Player.Volume(j.Volume); err != nil {
+			return err
+		}
+	}
+
+	first := j.Tracks[0]
+	if err := play(z, first.Provider, first.ID, j.CreatedBy, false); err != nil {
+		if err := z.Player.Fade(1, 0); err != nil {
+			log.Println(err)
+		}
+
+		return err
+	}
+
+	for _, t := range j.Tracks[1:] {
+		if _, err := Enqueue(z.Name, t.Provider, t.ID, j.CreatedBy); err != nil {
+			notifyError(z.Name, err)
+		}
+	}
+
+	return z.Player.Fade(1, fade)
+}
+
+// syncTimers shows the jobs of a zone in its status, it's called with jobsMutex held
+func syncTimers(zone string) {
+	z, ok := zones.Get(zone)
+	if !ok {
+		return
+	}
+
+	timers := make([]player.Timer, 0)
+	for _, j := range jobs {
+		if j.Zone != zone {
+			continue
+		}
+
+		t := player.Timer{ID: j.ID, Kind: j.Kind}
+		if !j.AfterTrack {
+			at := j.At
+			t.At = &at
+		}
+
+		timers = append(timers, t)
+	}
+
+	z.Player.SetTimers(timers)
+}
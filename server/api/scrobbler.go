@@ -0,0 +1,53 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/scrobbler"
+	"gngeorgiev/audiotic/server/zones"
+)
+
+// StartScrobbler follows the updates of the zones the scrobbler wants, including the ones created later
+func StartScrobbler(s *scrobbler.Scrobbler) {
+	for _, z := range zones.All() {
+		if s.Wants(z.Name) {
+			go followScrobbles(s, z)
+		}
+	}
+
+	created, _ := SubscribeZones(hub.Options{Name: "scrobbler", Policy: hub.DropOldest})
+	go func() {
+		for msg := range created {
+			if z, ok := zones.Get(msg.(string)); ok && s.Wants(z.Name) {
+				go followScrobbles(s, z)
+			}
+		}
+	}()
+}
+
+func followScrobbles(s *scrobbler.Scrobbler, z *zones.Zone) {
+	updates, unsubscribe := z.Player.Subscribe(hub.Options{Name: "scrobbler", BufferSize: 16, Policy: hub.DropOldest})
+	defer unsubscribe()
+	defer s.Forget(z.Name)
+
+	var source string
+	var track models.Track
+	for {
+		select {
+		case msg := <-updates:
+			status, _ := msg.(*player.VlcStatus)
+			if status == nil {
+				continue
+			}
+
+			if status.Source != source {
+				source, track = status.Source, z.Player.Track()
+			}
+
+			s.Update(z.Name, status, track)
+		case <-z.Done():
+			return
+		}
+	}
+}
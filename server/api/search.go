@@ -1,6 +1,9 @@
 package api
 
-import "gngeorgiev/audiotic/server/providers"
+import (
+	"gngeorgiev/audiotic/server/metadata"
+	"gngeorgiev/audiotic/server/providers"
+)
 
 func Search(query string) ([]interface{}, error) {
 	prov := providers.Container().GetComponents()
@@ -14,6 +17,8 @@ func Search(query string) ([]interface{}, error) {
 
 		result := make([]interface{}, len(tracks))
 		for i, t := range tracks {
+			// only the titles are parsed, looking up every result would be too slow
+			metadata.Fill(&t)
 			result[i] = t
 		}
 
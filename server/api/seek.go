@@ -1,7 +1,27 @@
 package api
 
-import "gngeorgiev/audiotic/server/player"
+func Seek(zone string, time int) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
 
-func Seek(time int) error {
-	return player.Get().Seek(time)
+	status, err := z.Player.Status()
+	if err != nil {
+		return err
+	}
+
+	if status == nil || status.Duration <= 0 {
+		return newError(CodeInvalidState, nil, "Nothing is playing")
+	}
+
+	if time < 0 || time > status.Duration {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "time",
+			"min":   0,
+			"max":   status.Duration,
+		}, "Time must be between 0 and %d", status.Duration)
+	}
+
+	return z.Player.Seek(time)
 }
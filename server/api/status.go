@@ -1,7 +1,25 @@
 package api
 
-import "gngeorgiev/audiotic/server/player"
+import (
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+)
 
-func Status() (*player.VlcStatus, error) {
-	return player.Get().Status()
+func Status(zone string) (*player.VlcStatus, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.Player.Status()
+}
+
+// CurrentTrack returns the track loaded in the player of a zone, it's empty until a track is played
+func CurrentTrack(zone string) (models.Track, error) {
+	z, err := Zone(zone)
+	if err != nil {
+		return models.Track{}, err
+	}
+
+	return z.Player.Track(), nil
 }
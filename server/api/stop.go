@@ -1,7 +1,10 @@
 package api
 
-import "gngeorgiev/audiotic/server/player"
+func Stop(zone string) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
 
-func Stop() error {
-	return player.Get().Stop()
+	return z.Player.Stop()
 }
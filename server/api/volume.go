@@ -1,7 +1,23 @@
 package api
 
-import "gngeorgiev/audiotic/server/player"
+const (
+	MinVolume = 0
+	MaxVolume = 200
+)
 
-func Volume(v int) error {
-	return player.Get().Volume(v)
+func Volume(zone string, v int) error {
+	z, err := Zone(zone)
+	if err != nil {
+		return err
+	}
+
+	if v < MinVolume || v > MaxVolume {
+		return newError(CodeInvalidArgument, map[string]interface{}{
+			"field": "volume",
+			"min":   MinVolume,
+			"max":   MaxVolume,
+		}, "Volume must be between %d and %d", MinVolume, MaxVolume)
+	}
+
+	return z.Player.Volume(v)
 }
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/webhooks"
+	"gngeorgiev/audiotic/server/zones"
+	"net/url"
+	"time"
+)
+
+// WebhookRequest creates or updates a webhook. A secret is generated for the new webhooks without one,
+// updates without a secret or enabled keep the current ones
+type WebhookRequest struct {
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+	Zone    string   `json:"zone"`
+	Enabled *bool    `json:"enabled"`
+}
+
+func webhookError(err error, id int) error {
+	if err == webhooks.ErrNotFound {
+		return newError(CodeNotFound, map[string]interface{}{"id": id}, "Webhook %d not found", id)
+	}
+
+	return err
+}
+
+func validateWebhook(r WebhookRequest) error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return newError(CodeInvalidArgument, map[string]interface{}{"field": "url"}, "Webhook urls must be absolute http or https urls")
+	}
+
+	for _, e := range r.Events {
+		if !webhooks.IsEvent(e) {
+			return newError(CodeInvalidArgument, map[string]interface{}{
+				"field":  "events",
+				"events": webhooks.Events,
+			}, "Unknown event %s", e)
+		}
+	}
+
+	if r.Zone != "" {
+		if _, err := Zone(r.Zone); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func Webhooks() ([]webhooks.Webhook, error) {
+	return webhooks.All()
+}
+
+// CreateWebhook saves a new webhook, the returned webhook is the only place its secret is visible
+func CreateWebhook(r WebhookRequest, createdBy string) (webhooks.Webhook, error) {
+	if err := validateWebhook(r); err != nil {
+		return webhooks.Webhook{}, err
+	}
+
+	w := webhooks.Webhook{
+		URL:       r.URL,
+		Secret:    r.Secret,
+		Events:    r.Events,
+		Zone:      r.Zone,
+		Enabled:   r.Enabled == nil || *r.Enabled,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+
+	if w.Events == nil {
+		w.Events = make([]string, 0)
+	}
+
+	if w.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			return webhooks.Webhook{}, err
+		}
+
+		w.Secret = secret
+	}
+
+	if err := webhooks.Save(&w); err != nil {
+		return webhooks.Webhook{}, err
+	}
+
+	return w, nil
+}
+
+func UpdateWebhook(id int, r WebhookRequest) (webhooks.Webhook, error) {
+	if err := validateWebhook(r); err != nil {
+		return webhooks.Webhook{}, err
+	}
+
+	w, err := webhooks.Get(id)
+	if err != nil {
+		return webhooks.Webhook{}, webhookError(err, id)
+	}
+
+	w.URL, w.Secret, w.Events, w.Zone = r.URL, r.Secret, r.Events, r.Zone
+	if w.Events == nil {
+		w.Events = make([]string, 0)
+	}
+
+	if r.Enabled != nil {
+		w.Enabled = *r.Enabled
+	}
+
+	if err := webhooks.Update(&w); err != nil {
+		return webhooks.Webhook{}, err
+	}
+
+	return w, nil
+}
+
+func RemoveWebhook(id int) error {
+	return webhookError(webhooks.Remove(id), id)
+}
+
+// WebhookDeliveries returns the delivery log of a webhook, the latest delivery first
+func WebhookDeliveries(id int) ([]webhooks.Delivery, error) {
+	if _, err := webhooks.Get(id); err != nil {
+		return nil, webhookError(err, id)
+	}
+
+	return webhooks.Deliveries(id)
+}
+
+// StartWebhooks follows the updates of the zones, the history and the errors and sends their events to the webhooks
+func StartWebhooks() {
+	for _, z := range zones.All() {
+		go followWebhookEvents(z)
+	}
+
+	created, _ := SubscribeZones(hub.Options{Name: "webhooks", Policy: hub.DropOldest})
+	added, _ := history.Subscribe(hub.Options{Name: "webhooks", Policy: hub.DropOldest})
+	failures, _ := SubscribeErrors(hub.Options{Name: "webhooks", Policy: hub.DropOldest})
+
+	go func() {
+		for {
+			select {
+			case msg := <-created:
+				if z, ok := zones.Get(msg.(string)); ok {
+					go followWebhookEvents(z)
+				}
+			case msg := <-added:
+				webhooks.Dispatch(webhooks.Event{Type: webhooks.EventHistoryAdded, Track: webhookTrack(msg.(models.Track))})
+			case msg := <-failures:
+				e := msg.(ZoneError)
+				status, _ := Status(e.Zone)
+				event := webhooks.Event{Type: webhooks.EventError, Zone: e.Zone, Status: status, Error: e.Error}
+				if t, err := CurrentTrack(e.Zone); err == nil && t.ID != "" {
+					event.Track = webhookTrack(t)
+				}
+
+				webhooks.Dispatch(event)
+			}
+		}
+	}()
+}
+
+func webhookTrack(t models.Track) *models.Track {
+	t.StreamUrl = ""
+	return &t
+}
+
+// followWebhookEvents turns the updates of a zone, the same ones autoplay follows, into webhook events
+func followWebhookEvents(z *zones.Zone) {
+	updates, unsubscribe := z.Player.Subscribe(hub.Options{Name: "webhooks", BufferSize: 16, Policy: hub.DropOldest})
+	defer unsubscribe()
+
+	var last *player.VlcStatus
+	var track models.Track
+	for {
+		select {
+		case msg := <-updates:
+			status, _ := msg.(*player.VlcStatus)
+			if status == nil {
+				continue
+			}
+
+			previous := track
+			if last == nil || status.Source != last.Source {
+				track = z.Player.Track()
+			}
+
+			for _, e := range webhooks.Transitions(last, status) {
+				t := track
+				if e == webhooks.EventTrackSkipped {
+					t = previous
+				}
+
+				webhooks.Dispatch(webhooks.Event{Type: e, Zone: z.Name, Track: webhookTrack(t), Status: status})
+			}
+
+			last = status
+		case <-z.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"gngeorgiev/audiotic/server/audioStream"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/zones"
+)
+
+type ZoneInfo struct {
+	Name    string            `json:"name"`
+	Default bool              `json:"default"`
+	Status  *player.VlcStatus `json:"status"`
+	Modes   Modes             `json:"modes"`
+}
+
+var zonesHub = hub.New("zones")
+
+// SubscribeZones returns a channel receiving the name of every zone created at runtime,
+// the removed zones close their Done channel instead
+func SubscribeZones(o hub.Options) (<-chan interface{}, func()) {
+	return zonesHub.Subscribe(o)
+}
+
+func ZonesMetrics() hub.Metrics {
+	return zonesHub.Metrics()
+}
+
+// Zone returns the zone with the given name, unknown zones are reported as not_found
+func Zone(name string) (*zones.Zone, error) {
+	z, ok := zones.Get(name)
+	if !ok {
+		return nil, zoneError(zones.ErrNotFound, name)
+	}
+
+	return z, nil
+}
+
+func zoneError(err error, name string) error {
+	details := map[string]interface{}{"zone": name}
+	switch err {
+	case zones.ErrNotFound:
+		return newError(CodeNotFound, details, "Unknown zone - %s", name)
+	case zones.ErrExists:
+		return newError(CodeInvalidState, details, "Zone %s already exists", name)
+	case zones.ErrInvalidName, zones.ErrDefault:
+		return newError(CodeInvalidArgument, details, err.Error())
+	default:
+		return err
+	}
+}
+
+func zoneInfo(z *zones.Zone) (ZoneInfo, error) {
+	status, err := z.Player.Status()
+	if err != nil {
+		return ZoneInfo{}, err
+	}
+
+	return ZoneInfo{
+		Name:    z.Name,
+		Default: z.Name == zones.Default,
+		Status:  status,
+		Modes:   GetModes(z.Name),
+	}, nil
+}
+
+func Zones() ([]ZoneInfo, error) {
+	all := zones.All()
+	res := make([]ZoneInfo, 0, len(all))
+	for _, z := range all {
+		info, err := zoneInfo(z)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, info)
+	}
+
+	return res, nil
+}
+
+// CreateZone starts a new zone with autoplay enabled, like the zones created on start
+func CreateZone(c config.ZoneConfig) (ZoneInfo, error) {
+	z, err := zones.Create(c)
+	if err != nil {
+		return ZoneInfo{}, zoneError(err, c.Name)
+	}
+
+	if err := Autoplay(z.Name, true); err != nil {
+		return ZoneInfo{}, err
+	}
+
+	if s := config.Get().Streaming; s.Enabled {
+		if err := audioStream.Start(z, s); err != nil {
+			return ZoneInfo{}, err
+		}
+	}
+
+	zonesHub.Publish(z.Name)
+	return zoneInfo(z)
+}
+
+func RemoveZone(name string) error {
+	if err := zones.Remove(name); err != nil {
+		return zoneError(err, name)
+	}
+
+	removeZoneJobs(name)
+
+	return nil
+}
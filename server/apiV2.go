@@ -0,0 +1,601 @@
+package main
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/audioStream"
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/controlProtocol"
+	"gngeorgiev/audiotic/server/eventStream"
+	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/zones"
+	"log"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/gin-gonic/gin.v1"
+	"gopkg.in/igm/sockjs-go.v2/sockjs"
+)
+
+type errorResponse struct {
+	Error *api.Error `json:"error"`
+}
+
+type playRequest struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+type seekRequest struct {
+	Time *int `json:"time"`
+}
+
+type volumeRequest struct {
+	Volume *int `json:"volume"`
+}
+
+type rateRequest struct {
+	Rate     *float64 `json:"rate"`
+	Remember bool     `json:"remember"`
+}
+
+type fadeRequest struct {
+	Length *int `json:"length"`
+}
+
+type normalizationRequest struct {
+	Mode string `json:"mode"`
+}
+
+type outputRequest struct {
+	Output string `json:"output"`
+	Device string `json:"device"`
+}
+
+func registerV2Routes(g *gin.RouterGroup) {
+	m := g.Group("/meta")
+	{
+		m.GET("/autocomplete", v2AutocompleteHandler())
+		m.GET("/search", v2SearchHandler())
+	}
+
+	listener := auth.Require(auth.RoleListener)
+	controller := auth.Require(auth.RoleController)
+	admin := auth.Require(auth.RoleAdmin)
+
+	registerZoneRoutes(g)
+	registerEqualizerRoutes(g)
+
+	z := g.Group("/zones")
+	{
+		z.GET("", listener, v2GetZonesHandler())
+		z.POST("", admin, v2CreateZoneHandler())
+		z.DELETE("/:zone", admin, v2DeleteZoneHandler())
+		registerZoneRoutes(z.Group("/:zone"))
+	}
+
+	g.GET("/metrics/hubs", admin, v2HubsMetricsHandler())
+
+	h := g.Group("/history")
+	{
+		h.GET("", listener, v2GetHistoryHandler())
+		h.DELETE("/:id", controller, v2DeleteHistoryHandler())
+	}
+
+	g.GET("/tracks/:provider/:id/download", listener, v2DownloadTrackHandler())
+	g.GET("/me", listener, v2MeHandler())
+	registerProfileRoutes(g)
+
+	u := g.Group("/users", admin)
+	{
+		u.GET("", v2GetUsersHandler())
+		u.POST("", v2CreateUserHandler())
+		u.DELETE("/:id", v2DeleteUserHandler())
+		u.GET("/:id/tokens", v2GetTokensHandler())
+		u.POST("/:id/tokens", v2CreateTokenHandler())
+		u.DELETE("/:id/tokens/:tokenId", v2DeleteTokenHandler())
+	}
+
+	w := g.Group("/webhooks", admin)
+	{
+		w.GET("", v2GetWebhooksHandler())
+		w.POST("", v2CreateWebhookHandler())
+		w.PUT("/:id", v2UpdateWebhookHandler())
+		w.DELETE("/:id", v2DeleteWebhookHandler())
+		w.GET("/:id/deliveries", v2WebhookDeliveriesHandler())
+	}
+
+	ca := g.Group("/cache")
+	{
+		ca.GET("", listener, v2GetCacheHandler())
+		ca.POST("", controller, v2CacheTrackHandler())
+		ca.PUT("/:provider/:id", controller, v2PinCachedHandler())
+		ca.DELETE("/:provider/:id", controller, v2RemoveCachedHandler())
+	}
+}
+
+// registerZoneRoutes registers the player and queue routes of a zone, the zone is taken from the :zone param
+// of the group and the default zone is used when there isn't one
+func registerZoneRoutes(g *gin.RouterGroup) {
+	listener := auth.Require(auth.RoleListener)
+	controller := auth.Require(auth.RoleController)
+
+	p := g.Group("/player")
+	{
+		p.GET("/status", listener, v2StatusHandler())
+		p.POST("/play", controller, v2PlayHandler())
+		p.POST("/pause", controller, v2ActionHandler(api.Pause))
+		p.POST("/resume", controller, v2ActionHandler(api.Resume))
+		p.POST("/stop", controller, v2ActionHandler(api.Stop))
+		p.PUT("/seek", controller, v2SeekHandler())
+		p.PUT("/volume", controller, v2VolumeHandler())
+		p.PUT("/rate", controller, v2RateHandler())
+		p.PUT("/fade", controller, v2FadeHandler())
+		p.GET("/modes", listener, v2ModesHandler())
+		p.PUT("/normalization", controller, v2NormalizationHandler())
+		p.GET("/equalizer", listener, v2GetEqualizerHandler())
+		p.PUT("/equalizer", controller, v2SetEqualizerHandler())
+		p.DELETE("/equalizer", controller, v2DisableEqualizerHandler())
+		p.GET("/outputs", listener, v2OutputsHandler())
+		p.PUT("/output", controller, v2OutputHandler())
+		p.POST("/next", controller, v2ActionHandler(api.Next))
+		p.GET("/clients", listener, v2ClientsHandler())
+		p.GET("/lyrics", listener, v2LyricsHandler())
+		p.GET("/events", listener, playerEventsHandler())
+		p.GET("/updates/*info", listener, controlHandler(routePath(g, "/player/updates")))
+	}
+
+	q := g.Group("/queue")
+	{
+		q.GET("", listener, v2GetQueueHandler())
+		q.POST("", controller, v2EnqueueHandler())
+		q.DELETE("", controller, v2ClearQueueHandler())
+		q.DELETE("/:index", controller, v2DequeueHandler())
+	}
+
+	s := g.Group("/scheduler")
+	{
+		s.GET("", listener, v2GetJobsHandler())
+		s.POST("", controller, v2CreateJobHandler())
+		s.DELETE("/:id", controller, v2CancelJobHandler())
+	}
+}
+
+func statusForErrorCode(code string) int {
+	switch code {
+	case api.CodeInvalidArgument:
+		return http.StatusBadRequest
+	case api.CodeInvalidState:
+		return http.StatusConflict
+	case api.CodeNotFound:
+		return http.StatusNotFound
+	case api.CodeUnauthorized:
+		return http.StatusUnauthorized
+	case api.CodeForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func abortWithApiError(c *gin.Context, err error) {
+	e := api.ToError(err)
+	c.Error(err)
+	c.JSON(statusForErrorCode(e.Code), errorResponse{e})
+	c.Abort()
+}
+
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		abortWithApiError(c, &api.Error{
+			Code:    api.CodeInvalidArgument,
+			Message: "Invalid JSON body",
+			Details: map[string]interface{}{"reason": err.Error()},
+		})
+		return false
+	}
+
+	return true
+}
+
+func missingField(field string) *api.Error {
+	return &api.Error{
+		Code:    api.CodeInvalidArgument,
+		Message: "Missing required field " + field,
+		Details: map[string]interface{}{"field": field},
+	}
+}
+
+func v2AutocompleteHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := api.Autocomplete(c.Query("q"))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func v2SearchHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := c.Query("q")
+		if q == "" {
+			abortWithApiError(c, missingField("q"))
+			return
+		}
+
+		result, err := api.Search(q)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func v2StatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := api.Status(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+func v2LyricsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l, err := api.Lyrics(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, l)
+	}
+}
+
+func v2PlayHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req playRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Provider == "" {
+			abortWithApiError(c, missingField("provider"))
+			return
+		}
+
+		if req.ID == "" {
+			abortWithApiError(c, missingField("id"))
+			return
+		}
+
+		if err := api.Play(zoneName(c), req.Provider, req.ID, auth.CallerOf(c).Name); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2ActionHandler(action func(zone string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := action(zoneName(c)); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2SeekHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req seekRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Time == nil {
+			abortWithApiError(c, missingField("time"))
+			return
+		}
+
+		if err := api.Seek(zoneName(c), *req.Time); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2VolumeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req volumeRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Volume == nil {
+			abortWithApiError(c, missingField("volume"))
+			return
+		}
+
+		if err := api.Volume(zoneName(c), *req.Volume); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2RateHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req rateRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Rate == nil {
+			abortWithApiError(c, missingField("rate"))
+			return
+		}
+
+		if err := api.Rate(zoneName(c), *req.Rate, req.Remember); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2FadeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req fadeRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Length == nil {
+			abortWithApiError(c, missingField("length"))
+			return
+		}
+
+		if err := api.SetFadeLength(zoneName(c), *req.Length); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2ModesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		z, err := api.Zone(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, api.GetModes(z.Name))
+	}
+}
+
+func v2NormalizationHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req normalizationRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Mode == "" {
+			abortWithApiError(c, missingField("mode"))
+			return
+		}
+
+		if err := api.SetNormalization(zoneName(c), req.Mode); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2OutputsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		outputs, err := api.AudioOutputs(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, outputs)
+	}
+}
+
+func v2OutputHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req outputRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Output == "" && req.Device == "" {
+			abortWithApiError(c, missingField("device"))
+			return
+		}
+
+		if err := api.SetAudioOutput(zoneName(c), req.Output, req.Device); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// controlHandler serves the control protocol, the session receives the pushed events
+// and its requests are executed concurrently, the responses are correlated by their ids
+func controlHandler(prefix string) gin.HandlerFunc {
+	return zoneSockJSHandler(prefix, func(sessions *zoneSessions, zone string, s sockjs.Session) {
+		// the handshake passed the auth middleware, so the caller can't be missing unless the user was deleted since
+		caller, err := auth.RequestCaller(s.Request())
+		if err != nil {
+			s.Close(4401, err.Error())
+			return
+		}
+
+		sessions.control.Handle(s, func(msg string) {
+			go func() {
+				if reply := controlProtocol.Handle(msg, caller, zone); reply != "" {
+					if err := s.Send(reply); err != nil {
+						log.Println(err)
+					}
+				}
+			}()
+		})
+	})
+}
+
+func v2ClientsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"count": connectedClients(zoneName(c))})
+	}
+}
+
+func v2HubsMetricsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res := []hub.Metrics{
+			history.Metrics(),
+			api.ErrorsMetrics(),
+			api.ModesMetrics(),
+			api.ZonesMetrics(),
+		}
+
+		for _, z := range zones.All() {
+			res = append(res, z.Player.UpdatesMetrics(), z.Queue.Metrics())
+		}
+
+		res = append(res, eventStream.Metrics()...)
+		c.JSON(http.StatusOK, append(res, audioStream.Metrics()...))
+	}
+}
+
+func v2GetQueueHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := api.Queue(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, q)
+	}
+}
+
+func v2EnqueueHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req playRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Provider == "" {
+			abortWithApiError(c, missingField("provider"))
+			return
+		}
+
+		if req.ID == "" {
+			abortWithApiError(c, missingField("id"))
+			return
+		}
+
+		t, err := api.Enqueue(zoneName(c), req.Provider, req.ID, auth.CallerOf(c).Name)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, t)
+	}
+}
+
+func v2DequeueHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		index, err := strconv.Atoi(c.Param("index"))
+		if err != nil {
+			abortWithApiError(c, &api.Error{
+				Code:    api.CodeInvalidArgument,
+				Message: "Index must be a number",
+				Details: map[string]interface{}{"field": "index"},
+			})
+			return
+		}
+
+		if err := api.Dequeue(zoneName(c), index); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2ClearQueueHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.ClearQueue(zoneName(c)); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// v2GetHistoryHandler serves the room history, the tracks played by everyone no matter who asks,
+// the plays of the caller are served by v2UserHistoryHandler
+func v2GetHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h, err := history.Get()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, h)
+	}
+}
+
+func v2DeleteHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if err := history.Remove(id); err != nil {
+			if err == history.ErrNotFound {
+				err = &api.Error{
+					Code:    api.CodeNotFound,
+					Message: "Track not found in history",
+					Details: map[string]interface{}{"id": id},
+				}
+			}
+
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gngeorgiev/audiotic/server/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+func v2Router() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerV2Routes(r.Group("/api/v2"))
+	return r
+}
+
+func TestV2ErrorResponses(t *testing.T) {
+	r := v2Router()
+
+	// there are no zones, so every request reaching a zone fails with not_found
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+		code               string
+		details            map[string]interface{}
+	}{
+		{"POST", "/api/v2/player/play", `{`, http.StatusBadRequest, api.CodeInvalidArgument, nil},
+		{"POST", "/api/v2/player/play", `[]`, http.StatusBadRequest, api.CodeInvalidArgument, nil},
+		{"POST", "/api/v2/player/play", `{}`, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "provider"}},
+		{"POST", "/api/v2/player/play", `{"provider": "youtube"}`, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "id"}},
+		{"PUT", "/api/v2/player/seek", `{}`, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "time"}},
+		{"PUT", "/api/v2/player/seek", `{"time": "soon"}`, http.StatusBadRequest, api.CodeInvalidArgument, nil},
+		{"PUT", "/api/v2/player/volume", `{}`, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "volume"}},
+		{"DELETE", "/api/v2/queue/first", ``, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "index"}},
+		{"GET", "/api/v2/meta/search", ``, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "q"}},
+		{"DELETE", "/api/v2/users/abc", ``, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "id"}},
+		{"POST", "/api/v2/zones", `{}`, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "name"}},
+		{"POST", "/api/v2/zones", `{"name": "kitchen", "volume": -1}`, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"field": "volume"}},
+		{"POST", "/api/v2/zones", `{"name": "Living Room"}`, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"zone": "Living Room"}},
+		{"DELETE", "/api/v2/zones/default", ``, http.StatusBadRequest, api.CodeInvalidArgument, map[string]interface{}{"zone": "default"}},
+		{"DELETE", "/api/v2/zones/kitchen", ``, http.StatusNotFound, api.CodeNotFound, map[string]interface{}{"zone": "kitchen"}},
+		{"GET", "/api/v2/player/status", ``, http.StatusNotFound, api.CodeNotFound, map[string]interface{}{"zone": "default"}},
+		{"POST", "/api/v2/player/pause", ``, http.StatusNotFound, api.CodeNotFound, map[string]interface{}{"zone": "default"}},
+		{"GET", "/api/v2/zones/kitchen/player/status", ``, http.StatusNotFound, api.CodeNotFound, map[string]interface{}{"zone": "kitchen"}},
+		{"POST", "/api/v2/zones/kitchen/player/play", `{"provider": "youtube", "id": "abc"}`, http.StatusNotFound, api.CodeNotFound, map[string]interface{}{"zone": "kitchen"}},
+	} {
+		name := c.method + " " + c.path + " " + c.body
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+
+		if w.Code != c.status {
+			t.Errorf("%s: expected %d, got %d %s", name, c.status, w.Code, w.Body.String())
+			continue
+		}
+
+		var res errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Error == nil {
+			t.Errorf("%s: expected the error envelope, got %s", name, w.Body.String())
+			continue
+		}
+
+		if res.Error.Code != c.code || res.Error.Message == "" {
+			t.Errorf("%s: expected the code %s with a message, got %+v", name, c.code, res.Error)
+		}
+
+		for k, v := range c.details {
+			if res.Error.Details[k] != v {
+				t.Errorf("%s: expected the detail %s %v, got %v", name, k, v, res.Error.Details)
+			}
+		}
+	}
+}
+
+func TestStatusForErrorCode(t *testing.T) {
+	for code, status := range map[string]int{
+		api.CodeInvalidArgument: http.StatusBadRequest,
+		api.CodeInvalidState:    http.StatusConflict,
+		api.CodeNotFound:        http.StatusNotFound,
+		api.CodeUnauthorized:    http.StatusUnauthorized,
+		api.CodeForbidden:       http.StatusForbidden,
+		api.CodeInternal:        http.StatusInternalServerError,
+		"":                      http.StatusInternalServerError,
+	} {
+		if s := statusForErrorCode(code); s != status {
+			t.Errorf("Expected %d for %q, got %d", status, code, s)
+		}
+	}
+}
+
+func TestAbortWithApiErrorWrapsUnknownErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) { abortWithApiError(c, errors.New("disk full")) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", w.Code)
+	}
+
+	var res errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Error == nil {
+		t.Fatalf("Expected the error envelope, got %s", w.Body.String())
+	}
+
+	if res.Error.Code != api.CodeInternal || res.Error.Message != "disk full" {
+		t.Errorf("Expected an internal error, got %+v", res.Error)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/audioStream"
+	"gngeorgiev/audiotic/server/zones"
+	"io"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+// icyMetaInt is how many bytes of audio are sent between two ICY metadata blocks
+const icyMetaInt = 16000
+
+// audioStreamHandler serves the playback of a zone as an endless ogg/opus stream, Icecast style.
+// The zone is picked with the zone query param, clients sending Icy-MetaData: 1 get the track names inline
+func audioStreamHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zone := c.Query("zone")
+		if zone == "" {
+			zone = zones.Default
+		}
+
+		r, ok := audioStream.For(zone)
+		if !ok {
+			abortWithApiError(c, &api.Error{
+				Code:    api.CodeNotFound,
+				Message: "The zone is unknown or streaming is disabled",
+				Details: map[string]interface{}{"zone": zone},
+			})
+			return
+		}
+
+		headers, pages, stop := r.Listen(c.ClientIP())
+		defer stop()
+
+		header := c.Writer.Header()
+		header.Set("Content-Type", "audio/ogg")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("icy-name", "audiotic "+zone)
+
+		var w io.Writer = c.Writer
+		if c.Request.Header.Get("Icy-MetaData") == "1" {
+			header.Set("icy-metaint", strconv.Itoa(icyMetaInt))
+			w = audioStream.NewIcyWriter(c.Writer, icyMetaInt, r.Title)
+		}
+		c.Status(http.StatusOK)
+
+		for _, h := range headers {
+			if _, err := w.Write(h); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		closed := c.Writer.CloseNotify()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-r.Done():
+				return
+			case msg, ok := <-pages:
+				if !ok {
+					return
+				}
+
+				if _, err := w.Write(msg.([]byte)); err != nil {
+					return
+				}
+			}
+
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package audioStream
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxIcyMetadata = 255 * 16
+	icyTitlePrefix = "StreamTitle='"
+	icyTitleSuffix = "';"
+)
+
+// IcyWriter interleaves the ICY metadata with the audio, every MetaInt bytes of audio are followed by a metadata block,
+// the way Icecast does it for clients sending Icy-MetaData: 1. The title is sent when it changes
+type IcyWriter struct {
+	w       io.Writer
+	metaInt int
+	left    int
+	title   func() string
+	sent    string
+}
+
+func NewIcyWriter(w io.Writer, metaInt int, title func() string) *IcyWriter {
+	return &IcyWriter{
+		w:       w,
+		metaInt: metaInt,
+		left:    metaInt,
+		title:   title,
+	}
+}
+
+func (w *IcyWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > w.left {
+			n = w.left
+		}
+
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+		w.left -= n
+		if w.left == 0 {
+			if err := w.writeMetadata(); err != nil {
+				return written, err
+			}
+
+			w.left = w.metaInt
+		}
+	}
+
+	return written, nil
+}
+
+func (w *IcyWriter) writeMetadata() error {
+	title := w.title()
+	if title == w.sent {
+		_, err := w.w.Write([]byte{0})
+		return err
+	}
+
+	w.sent = title
+	_, err := w.w.Write(icyMetadata(title))
+	return err
+}
+
+// icyMetadata returns the metadata block of a title, the length byte counts 16 byte blocks
+func icyMetadata(title string) []byte {
+	// the title is quoted with ' and there is no escaping
+	title = strings.Replace(title, "'", "’", -1)
+	if max := maxIcyMetadata - len(icyTitlePrefix) - len(icyTitleSuffix); len(title) > max {
+		// the title is cut at the start of a rune, so the players don't get a broken character
+		for max > 0 && !utf8.RuneStart(title[max]) {
+			max--
+		}
+
+		title = title[:max]
+	}
+
+	meta := icyTitlePrefix + title + icyTitleSuffix
+	blocks := (len(meta) + 15) / 16
+	res := make([]byte, 1+blocks*16)
+	res[0] = byte(blocks)
+	copy(res[1:], meta)
+
+	return res
+}
@@ -0,0 +1,63 @@
+package audioStream
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIcyWriterInterleavesMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewIcyWriter(&buf, 4, func() string { return "song" })
+
+	if _, err := w.Write([]byte("abcdefghij")); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := icyMetadata("song")
+	expected := append([]byte("abcd"), meta...)
+	expected = append(expected, []byte("efgh")...)
+	// the title didn't change, so the second block is empty
+	expected = append(expected, 0)
+	expected = append(expected, []byte("ij")...)
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected %q, got %q", expected, buf.Bytes())
+	}
+}
+
+func TestIcyMetadataIsPaddedToBlocks(t *testing.T) {
+	meta := icyMetadata("it's")
+	if int(meta[0])*16 != len(meta)-1 {
+		t.Fatalf("Expected %d blocks of metadata, got %d bytes", meta[0], len(meta)-1)
+	}
+
+	if bytes.Contains(meta[1:], []byte("it's")) {
+		t.Fatal("Expected the quote in the title to be replaced")
+	}
+}
+
+func TestIcyMetadataCutsTheTitleAtARune(t *testing.T) {
+	max := maxIcyMetadata - len(icyTitlePrefix) - len(icyTitleSuffix)
+	// the limit falls in the middle of a two byte rune
+	title := strings.Repeat("é", max)
+	if max%2 == 0 {
+		title = "a" + title
+	}
+
+	meta := icyMetadata(title)
+	text := strings.TrimRight(string(meta[1:]), "\x00")
+	if !strings.HasPrefix(text, icyTitlePrefix) || !strings.HasSuffix(text, icyTitleSuffix) {
+		t.Fatalf("Expected a quoted title, got %q", text)
+	}
+
+	cut := strings.TrimSuffix(strings.TrimPrefix(text, icyTitlePrefix), icyTitleSuffix)
+	if !utf8.ValidString(cut) || !strings.HasPrefix(title, cut) || len(cut) != max-1 {
+		t.Fatalf("Expected the title to be cut before the broken rune, got %d bytes", len(cut))
+	}
+
+	if len(meta)-1 > maxIcyMetadata {
+		t.Fatalf("Expected at most %d bytes of metadata, got %d", maxIcyMetadata, len(meta)-1)
+	}
+}
@@ -0,0 +1,79 @@
+package audioStream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	oggHeaderSize = 27
+	oggBOS        = 0x02
+)
+
+var oggCapture = []byte("OggS")
+
+// page is an ogg page, the stream is relayed page by page so a listener never gets half of one
+type page struct {
+	data []byte
+	// bos marks the first page of a logical stream, every track starts a new one
+	bos bool
+	// granule is 0 for the pages carrying the codec headers
+	granule uint64
+}
+
+// readPage reads the next page, skipping whatever precedes its capture pattern
+func readPage(r *bufio.Reader) (page, error) {
+	if err := syncPage(r); err != nil {
+		return page{}, err
+	}
+
+	header := make([]byte, oggHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return page{}, err
+	}
+
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(r, segments); err != nil {
+		return page{}, err
+	}
+
+	size := 0
+	for _, s := range segments {
+		size += int(s)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return page{}, err
+	}
+
+	data := make([]byte, 0, len(header)+len(segments)+len(body))
+	data = append(data, header...)
+	data = append(data, segments...)
+	data = append(data, body...)
+
+	return page{
+		data:    data,
+		bos:     header[5]&oggBOS != 0,
+		granule: binary.LittleEndian.Uint64(header[6:14]),
+	}, nil
+}
+
+// syncPage discards the bytes before the next capture pattern, leaving it unread
+func syncPage(r *bufio.Reader) error {
+	for {
+		b, err := r.Peek(len(oggCapture))
+		if err != nil {
+			return err
+		}
+
+		if string(b) == string(oggCapture) {
+			return nil
+		}
+
+		if _, err := r.Discard(1); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package audioStream
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func oggPage(flags byte, granule byte, body string) []byte {
+	header := make([]byte, oggHeaderSize)
+	copy(header, oggCapture)
+	header[5] = flags
+	header[6] = granule
+	header[26] = 1
+
+	p := append(header, byte(len(body)))
+	return append(p, body...)
+}
+
+func TestReadPageSkipsGarbageBeforeThePage(t *testing.T) {
+	first := oggPage(oggBOS, 0, "head")
+	second := oggPage(0, 7, "audio")
+	stream := append([]byte("garbage"), first...)
+	stream = append(stream, second...)
+	r := bufio.NewReader(bytes.NewReader(stream))
+
+	p, err := readPage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.bos || p.granule != 0 || !bytes.Equal(p.data, first) {
+		t.Fatalf("Expected the header page, got %+v", p)
+	}
+
+	p, err = readPage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.bos || p.granule != 7 || !bytes.Equal(p.data, second) {
+		t.Fatalf("Expected the audio page, got %+v", p)
+	}
+}
@@ -0,0 +1,237 @@
+package audioStream
+
+import (
+	"bufio"
+	"fmt"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/zones"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Relay re-streams the playback of a zone over http. libvlc transcodes every track to ogg/opus and serves it
+// on a local port next to the local output, the relay reads it and fans the pages out to the listeners,
+// which stay connected across the tracks
+type Relay struct {
+	zone   *zones.Zone
+	source string
+
+	mutex     sync.Mutex
+	headers   [][]byte
+	pages     *hub.Hub
+	listeners int
+	title     string
+}
+
+var (
+	relaysMutex sync.Mutex
+	relays      = make(map[*zones.Zone]*Relay)
+
+	pagesBufferSize = 64
+	defaultBitrate  = 128
+
+	// sourceClient connects to the stream output of libvlc, the stream has no end so only the connection
+	// and the response headers have a timeout
+	sourceClient = &http.Client{Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: sourceTimeout}).DialContext,
+		ResponseHeaderTimeout: sourceTimeout,
+	}}
+)
+
+const sourceTimeout = 10 * time.Second
+
+// Start streams the playback of a zone over http from the next track on
+func Start(z *zones.Zone, c config.StreamingConfig) error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+
+	bitrate := c.Bitrate
+	if bitrate <= 0 {
+		bitrate = defaultBitrate
+	}
+
+	r := &Relay{
+		zone:   z,
+		source: fmt.Sprintf("http://127.0.0.1:%d/", port),
+		pages:  hub.New(z.Name + " audio stream"),
+	}
+
+	relaysMutex.Lock()
+	relays[z] = r
+	relaysMutex.Unlock()
+
+	z.Player.SetStreamOutput(soutChain(port, bitrate))
+	go r.run()
+
+	return nil
+}
+
+// For returns the relay of a zone, false is returned when the zone isn't streamed
+func For(zone string) (*Relay, bool) {
+	z, ok := zones.Get(zone)
+	if !ok {
+		return nil, false
+	}
+
+	relaysMutex.Lock()
+	defer relaysMutex.Unlock()
+
+	r, ok := relays[z]
+	return r, ok
+}
+
+// Metrics returns the metrics of the relays of all zones
+func Metrics() []hub.Metrics {
+	relaysMutex.Lock()
+	defer relaysMutex.Unlock()
+
+	res := make([]hub.Metrics, 0, len(relays))
+	for _, r := range relays {
+		res = append(res, r.pages.Metrics())
+	}
+
+	return res
+}
+
+func soutChain(port, bitrate int) string {
+	return fmt.Sprintf(`#duplicate{dst=display,dst="transcode{vcodec=none,acodec=opus,ab=%d,channels=2,samplerate=48000}`+
+		`:std{access=http,mux=ogg,dst=127.0.0.1:%d/}"}`, bitrate, port)
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// run follows the status of the player and reads the libvlc output while something is playing,
+// the output is only up while a track plays, so connecting is retried on the next status
+func (r *Relay) run() {
+	updates, unsubscribe := r.zone.Player.Subscribe(hub.Options{Name: "audio stream", BufferSize: 1, Policy: hub.Coalesce})
+	defer func() {
+		unsubscribe()
+
+		relaysMutex.Lock()
+		delete(relays, r.zone)
+		relaysMutex.Unlock()
+	}()
+
+	var relaying chan struct{}
+	for {
+		select {
+		case msg := <-updates:
+			status := msg.(*player.VlcStatus)
+			if status == nil {
+				continue
+			}
+
+			r.setTitle(status.Name)
+			if status.IsPlaying && relaying == nil {
+				relaying = make(chan struct{})
+				go r.relay(relaying)
+			}
+		case <-relaying:
+			relaying = nil
+		case <-r.zone.Done():
+			return
+		}
+	}
+}
+
+func (r *Relay) relay(done chan struct{}) {
+	defer close(done)
+
+	res, err := sourceClient.Get(r.source)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	br := bufio.NewReader(res.Body)
+	for {
+		p, err := readPage(br)
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				log.Println(err)
+			}
+
+			return
+		}
+
+		r.publish(p)
+	}
+}
+
+// publish sends a page to the listeners, the header pages of the current track are kept for the listeners joining later
+func (r *Relay) publish(p page) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if p.bos {
+		r.headers = nil
+	}
+
+	if p.granule == 0 {
+		r.headers = append(r.headers, p.data)
+	}
+
+	r.pages.Publish(p.data)
+}
+
+// Listen returns the header pages of the current track and a channel receiving the following pages as []byte,
+// the listener is counted in the status of the zone until the returned func is called
+func (r *Relay) Listen(name string) ([][]byte, <-chan interface{}, func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	headers := make([][]byte, len(r.headers))
+	copy(headers, r.headers)
+
+	pages, unsubscribe := r.pages.Subscribe(hub.Options{Name: name, BufferSize: pagesBufferSize, Policy: hub.DropOldest})
+	r.listeners++
+	r.zone.Player.SetListeners(r.listeners)
+
+	once := sync.Once{}
+	return headers, pages, func() {
+		once.Do(func() {
+			unsubscribe()
+
+			r.mutex.Lock()
+			r.listeners--
+			r.zone.Player.SetListeners(r.listeners)
+			r.mutex.Unlock()
+		})
+	}
+}
+
+// Title is the name of the playing track, sent as ICY metadata
+func (r *Relay) Title() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.title
+}
+
+func (r *Relay) setTitle(title string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.title = title
+}
+
+// Done is closed once the zone is removed
+func (r *Relay) Done() <-chan struct{} {
+	return r.zone.Done()
+}
@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/database"
+	"log"
+	"time"
+
+	"github.com/asdine/storm"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	tokenPrefix       = "at_"
+	tokenBytes        = 32
+	bootstrapUserName = "admin"
+
+	// lastUsedInterval is how stale the last use of a token may get, so not every request writes to the database
+	lastUsedInterval = time.Minute
+)
+
+var (
+	db      *storm.DB
+	enabled bool
+)
+
+// Init prepares the users storage, when auth is enabled and there are no users
+// an admin is created and its token is logged, it's the only time the token is visible
+func Init(isEnabled bool) error {
+	enabled = isEnabled
+	db = database.Get()
+
+	if err := db.Init(userRecord{}); err != nil {
+		return err
+	}
+
+	if err := db.Init(tokenRecord{}); err != nil {
+		return err
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	users, err := Users()
+	if err != nil {
+		return err
+	}
+
+	if len(users) > 0 {
+		return nil
+	}
+
+	u, err := CreateUser(bootstrapUserName, "", RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	token, _, err := CreateToken(u.ID, "bootstrap")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Created user %s with token %s, create your own users and delete it", u.Name, token)
+	return nil
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+func Users() ([]User, error) {
+	var records []userRecord
+	if err := db.All(&records); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(records))
+	for i := range records {
+		users = append(users, *records[i].user())
+	}
+
+	return users, nil
+}
+
+func GetUser(id int) (*User, error) {
+	r, err := getUserRecord(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.user(), nil
+}
+
+func getUserRecord(id int) (*userRecord, error) {
+	var r userRecord
+	if err := db.One("ID", id, &r); err != nil {
+		if err == storm.ErrNotFound {
+			return nil, userNotFound(id)
+		}
+
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// CreateUser adds a user, users without a password can authenticate only with tokens
+func CreateUser(name, password, role string) (*User, error) {
+	if name == "" {
+		return nil, &api.Error{
+			Code:    api.CodeInvalidArgument,
+			Message: "Missing user name",
+			Details: map[string]interface{}{"field": "name"},
+		}
+	}
+
+	if !IsValidRole(role) {
+		return nil, &api.Error{
+			Code:    api.CodeInvalidArgument,
+			Message: "Unknown role " + role,
+			Details: map[string]interface{}{
+				"field": "role",
+				"roles": []string{RoleAdmin, RoleController, RoleListener},
+			},
+		}
+	}
+
+	u := &userRecord{
+		Name:      name,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+
+		u.PasswordHash = hash
+	}
+
+	if err := db.Save(u); err != nil {
+		if err == storm.ErrAlreadyExists {
+			return nil, &api.Error{
+				Code:    api.CodeInvalidArgument,
+				Message: "User " + name + " already exists",
+				Details: map[string]interface{}{"field": "name"},
+			}
+		}
+
+		return nil, err
+	}
+
+	return u.user(), nil
+}
+
+func DeleteUser(id int) error {
+	u, err := getUserRecord(id)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := tokenRecords(id)
+	if err != nil {
+		return err
+	}
+
+	for i := range tokens {
+		if err := db.Remove(&tokens[i]); err != nil {
+			return err
+		}
+	}
+
+	return db.Remove(u)
+}
+
+func Tokens(userID int) ([]Token, error) {
+	records, err := tokenRecords(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]Token, 0, len(records))
+	for i := range records {
+		tokens = append(tokens, *records[i].token())
+	}
+
+	return tokens, nil
+}
+
+func tokenRecords(userID int) ([]tokenRecord, error) {
+	var records []tokenRecord
+	if err := db.Find("UserID", userID, &records); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// CreateToken generates a new api token for the user, only its hash is stored
+// so the returned plain token can't be retrieved later
+func CreateToken(userID int, name string) (string, *Token, error) {
+	if _, err := GetUser(userID); err != nil {
+		return "", nil, err
+	}
+
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	plain := tokenPrefix + hex.EncodeToString(b)
+	t := &tokenRecord{
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashToken(plain),
+		CreatedAt: time.Now(),
+	}
+
+	if err := db.Save(t); err != nil {
+		return "", nil, err
+	}
+
+	return plain, t.token(), nil
+}
+
+func DeleteToken(userID, id int) error {
+	var t tokenRecord
+	if err := db.One("ID", id, &t); err != nil || t.UserID != userID {
+		return &api.Error{
+			Code:    api.CodeNotFound,
+			Message: "Token not found",
+			Details: map[string]interface{}{"id": id},
+		}
+	}
+
+	return db.Remove(&t)
+}
+
+func AuthenticateToken(plain string) (*User, error) {
+	var t tokenRecord
+	if err := db.One("Hash", hashToken(plain), &t); err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	u, err := GetUser(t.UserID)
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	if now := time.Now(); now.Sub(t.LastUsed) >= lastUsedInterval {
+		if err := db.UpdateField(&t, "LastUsed", now); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return u, nil
+}
+
+func AuthenticatePassword(name, password string) (*User, error) {
+	var u userRecord
+	if err := db.One("Name", name, &u); err != nil || len(u.PasswordHash) == 0 {
+		return nil, errInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)); err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	return u.user(), nil
+}
+
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func userNotFound(id int) *api.Error {
+	return &api.Error{
+		Code:    api.CodeNotFound,
+		Message: "User not found",
+		Details: map[string]interface{}{"id": id},
+	}
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/database"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Open(filepath.Join(dir, "db.db")); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := Init(false); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	database.Release()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// createUser adds a user with a unique name, so the tests don't share users
+func createUser(t *testing.T, password, role string) *User {
+	u, err := CreateUser(t.Name()+strconv.FormatInt(time.Now().UnixNano(), 10), password, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func TestTokensAreStoredHashed(t *testing.T) {
+	u := createUser(t, "", RoleListener)
+	plain, token, err := CreateToken(u.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(plain, tokenPrefix) {
+		t.Errorf("Expected the token %s to start with %s", plain, tokenPrefix)
+	}
+
+	records, err := tokenRecords(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].ID != token.ID {
+		t.Fatalf("Expected the token of the user, got %v", records)
+	}
+
+	if records[0].Hash != hashToken(plain) {
+		t.Errorf("Expected the sha256 of the token to be stored, got %s", records[0].Hash)
+	}
+
+	b, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), records[0].Hash) || strings.Contains(string(b), plain) {
+		t.Errorf("Expected the token info not to contain the token, got %s", b)
+	}
+}
+
+func TestAuthenticateTokenLooksUpTheHash(t *testing.T) {
+	u := createUser(t, "", RoleController)
+	plain, _, err := CreateToken(u.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := AuthenticateToken(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.ID != u.ID || found.Role != RoleController {
+		t.Errorf("Expected the user %d, got %v", u.ID, found)
+	}
+
+	tokens, err := Tokens(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 1 || tokens[0].LastUsed.IsZero() {
+		t.Errorf("Expected the last use of the token to be stored, got %v", tokens)
+	}
+
+	for _, bad := range []string{"", hashToken(plain), plain + "0", strings.TrimPrefix(plain, tokenPrefix)} {
+		if _, err := AuthenticateToken(bad); err != errInvalidCredentials {
+			t.Errorf("Expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestDeletedTokensAndUsersDontAuthenticate(t *testing.T) {
+	u := createUser(t, "", RoleListener)
+	first, token, err := CreateToken(u.ID, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, _, err := CreateToken(u.ID, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteToken(u.ID+1, token.ID); api.ToError(err).Code != api.CodeNotFound {
+		t.Errorf("Expected the token of another user not to be found, got %v", err)
+	}
+
+	if err := DeleteToken(u.ID, token.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AuthenticateToken(first); err != errInvalidCredentials {
+		t.Errorf("Expected the deleted token to be rejected, got %v", err)
+	}
+
+	if err := DeleteUser(u.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AuthenticateToken(second); err != errInvalidCredentials {
+		t.Errorf("Expected the token of the deleted user to be rejected, got %v", err)
+	}
+}
+
+func TestAuthenticatePasswordChecksTheBcryptHash(t *testing.T) {
+	u := createUser(t, "secret", RoleAdmin)
+	r, err := getUserRecord(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bcrypt.CompareHashAndPassword(r.PasswordHash, []byte("secret")) != nil {
+		t.Fatalf("Expected a bcrypt hash of the password to be stored, got %q", r.PasswordHash)
+	}
+
+	found, err := AuthenticatePassword(u.Name, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.ID != u.ID {
+		t.Errorf("Expected the user %d, got %d", u.ID, found.ID)
+	}
+
+	for _, c := range []struct{ name, password string }{
+		{u.Name, "Secret"},
+		{u.Name, ""},
+		{u.Name + "x", "secret"},
+	} {
+		if _, err := AuthenticatePassword(c.name, c.password); err != errInvalidCredentials {
+			t.Errorf("Expected %s:%s to be rejected, got %v", c.name, c.password, err)
+		}
+	}
+}
+
+func TestUsersWithoutAPasswordCantAuthenticateWithOne(t *testing.T) {
+	u := createUser(t, "", RoleAdmin)
+	if _, err := AuthenticatePassword(u.Name, ""); err != errInvalidCredentials {
+		t.Errorf("Expected an empty password to be rejected, got %v", err)
+	}
+}
+
+func TestCreateUserValidatesTheArguments(t *testing.T) {
+	u := createUser(t, "", RoleListener)
+	for _, c := range []struct{ name, role string }{
+		{"", RoleListener},
+		{"someone", "root"},
+		{u.Name, RoleListener},
+	} {
+		if _, err := CreateUser(c.name, "", c.role); api.ToError(err).Code != api.CodeInvalidArgument {
+			t.Errorf("Expected %s with role %s to be invalid, got %v", c.name, c.role, err)
+		}
+	}
+}
+
+func TestIsRoleAllowed(t *testing.T) {
+	for _, c := range []struct {
+		role, required string
+		allowed        bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleController, true},
+		{RoleAdmin, RoleListener, true},
+		{RoleController, RoleAdmin, false},
+		{RoleController, RoleController, true},
+		{RoleController, RoleListener, true},
+		{RoleListener, RoleAdmin, false},
+		{RoleListener, RoleController, false},
+		{RoleListener, RoleListener, true},
+		{"", RoleListener, false},
+		{"root", RoleListener, false},
+	} {
+		if allowed := IsRoleAllowed(c.role, c.required); allowed != c.allowed {
+			t.Errorf("Expected %q with %q required to be %v", c.role, c.required, c.allowed)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"net/http"
+	"strings"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+const (
+	userKey     = "user"
+	tokenQuery  = "token"
+	bearerToken = "Bearer "
+)
+
+var (
+	errInvalidCredentials = &api.Error{
+		Code:    api.CodeUnauthorized,
+		Message: "Invalid credentials",
+	}
+	errMissingCredentials = &api.Error{
+		Code:    api.CodeUnauthorized,
+		Message: "Missing credentials, use a bearer token, basic auth or the token query param",
+	}
+)
+
+// FromRequest authenticates a request with a bearer token, basic auth or the token query param.
+// The query param is meant for the sockjs and EventSource clients which can't set headers
+func FromRequest(r *http.Request) (*User, error) {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, bearerToken) {
+		return AuthenticateToken(strings.TrimPrefix(h, bearerToken))
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		return AuthenticatePassword(name, password)
+	}
+
+	if token := r.URL.Query().Get(tokenQuery); token != "" {
+		return AuthenticateToken(token)
+	}
+
+	return nil, errMissingCredentials
+}
+
+// Caller identifies whoever sent a request, when auth is disabled the name is empty and everyone is an admin
+type Caller struct {
+	Name string
+	Role string
+}
+
+var anonymous = Caller{Role: RoleAdmin}
+
+func RequestCaller(r *http.Request) (Caller, error) {
+	if !enabled {
+		return anonymous, nil
+	}
+
+	u, err := FromRequest(r)
+	if err != nil {
+		return Caller{}, err
+	}
+
+	return Caller{Name: u.Name, Role: u.Role}, nil
+}
+
+// CallerOf returns the caller of a request which went through Require
+func CallerOf(c *gin.Context) Caller {
+	u := CurrentUser(c)
+	if u == nil {
+		return anonymous
+	}
+
+	return Caller{Name: u.Name, Role: u.Role}
+}
+
+func Forbidden(role, required string) *api.Error {
+	return &api.Error{
+		Code:    api.CodeForbidden,
+		Message: "The " + role + " role is not allowed to do this",
+		Details: map[string]interface{}{"role": role, "required": required},
+	}
+}
+
+// Require is a middleware rejecting the requests of users without the role or a more privileged one
+func Require(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled {
+			return
+		}
+
+		u, err := FromRequest(c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="audiotic"`)
+			abort(c, http.StatusUnauthorized, api.ToError(err))
+			return
+		}
+
+		if !IsRoleAllowed(u.Role, role) {
+			abort(c, http.StatusForbidden, Forbidden(u.Role, role))
+			return
+		}
+
+		c.Set(userKey, u)
+	}
+}
+
+// CurrentUser returns the user authenticated by Require, nil when auth is disabled
+func CurrentUser(c *gin.Context) *User {
+	u, ok := c.Get(userKey)
+	if !ok {
+		return nil
+	}
+
+	return u.(*User)
+}
+
+func abort(c *gin.Context, status int, err *api.Error) {
+	c.JSON(status, gin.H{"error": err})
+	c.Abort()
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+// withAuth toggles auth for a test, the returned func restores the default
+func withAuth(isEnabled bool) func() {
+	enabled = isEnabled
+	return func() { enabled = false }
+}
+
+// router serves the routes the way registerRoutes does, a listener sockjs endpoint and a controller route
+func router() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/player/updates/*info", Require(RoleListener), func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("info"))
+	})
+	r.GET("/player/pause", Require(RoleController), func(c *gin.Context) {
+		c.JSON(http.StatusOK, CallerOf(c))
+	})
+
+	return r
+}
+
+func serve(r *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func errorOf(t *testing.T, w *httptest.ResponseRecorder) *api.Error {
+	var res struct {
+		Error *api.Error `json:"error"`
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Error == nil {
+		t.Fatalf("Expected an error response, got %s", w.Body.String())
+	}
+
+	return res.Error
+}
+
+func tokenOf(t *testing.T, role string) string {
+	u := createUser(t, "", role)
+	token, _, err := CreateToken(u.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestRequireRejectsMissingAndBadCredentials(t *testing.T) {
+	defer withAuth(true)()
+	u := createUser(t, "secret", RoleAdmin)
+
+	for name, setUp := range map[string]func(r *http.Request){
+		"missing":         func(r *http.Request) {},
+		"bad bearer":      func(r *http.Request) { r.Header.Set("Authorization", "Bearer at_nope") },
+		"empty bearer":    func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") },
+		"bad password":    func(r *http.Request) { r.SetBasicAuth(u.Name, "nope") },
+		"bad query token": func(r *http.Request) { r.URL.RawQuery = "token=at_nope" },
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/player/pause", nil)
+		setUp(req)
+
+		w := serve(router(), req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+			continue
+		}
+
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate header", name)
+		}
+
+		if err := errorOf(t, w); err.Code != api.CodeUnauthorized {
+			t.Errorf("%s: expected the unauthorized code, got %s", name, err.Code)
+		}
+	}
+}
+
+func TestRequireRejectsALowerRole(t *testing.T) {
+	defer withAuth(true)()
+	token := tokenOf(t, RoleListener)
+
+	req := httptest.NewRequest(http.MethodGet, "/player/pause", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := serve(router(), req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d", w.Code)
+	}
+
+	if err := errorOf(t, w); err.Code != api.CodeForbidden {
+		t.Errorf("Expected the forbidden code, got %s", err.Code)
+	}
+}
+
+func TestRequireAcceptsEveryCredentialKind(t *testing.T) {
+	defer withAuth(true)()
+	token := tokenOf(t, RoleAdmin)
+	u := createUser(t, "secret", RoleController)
+
+	for name, setUp := range map[string]func(r *http.Request){
+		"bearer":      func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) },
+		"basic":       func(r *http.Request) { r.SetBasicAuth(u.Name, "secret") },
+		"query token": func(r *http.Request) { r.URL.RawQuery = "token=" + token },
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/player/pause", nil)
+		setUp(req)
+
+		w := serve(router(), req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d %s", name, w.Code, w.Body.String())
+			continue
+		}
+
+		var caller Caller
+		if err := json.Unmarshal(w.Body.Bytes(), &caller); err != nil {
+			t.Fatal(err)
+		}
+
+		if caller.Name == "" || caller.Role == "" {
+			t.Errorf("%s: expected the authenticated caller, got %v", name, caller)
+		}
+	}
+}
+
+func TestRequireCoversTheSockJSHandshake(t *testing.T) {
+	defer withAuth(true)()
+	token := tokenOf(t, RoleListener)
+
+	// the sockjs client requests the info first and then opens a transport, both keep the query of the url
+	for _, path := range []string{"/player/updates/info", "/player/updates/123/abcdef/websocket", "/player/updates/123/abcdef/xhr_streaming"} {
+		if w := serve(router(), httptest.NewRequest(http.MethodGet, path, nil)); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected %s without a token to be rejected, got %d", path, w.Code)
+		}
+
+		w := serve(router(), httptest.NewRequest(http.MethodGet, path+"?token="+token, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %s with a token to be served, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRequireLetsEveryoneInWhenAuthIsDisabled(t *testing.T) {
+	defer withAuth(false)()
+
+	w := serve(router(), httptest.NewRequest(http.MethodGet, "/player/pause", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var caller Caller
+	if err := json.Unmarshal(w.Body.Bytes(), &caller); err != nil {
+		t.Fatal(err)
+	}
+
+	if caller != anonymous {
+		t.Errorf("Expected the anonymous admin, got %v", caller)
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import "time"
+
+const (
+	// RoleAdmin can do everything, including managing the users
+	RoleAdmin = "admin"
+	// RoleController can control the playback
+	RoleController = "controller"
+	// RoleListener can only follow what is playing
+	RoleListener = "listener"
+)
+
+var roleLevels = map[string]int{
+	RoleListener:   1,
+	RoleController: 2,
+	RoleAdmin:      3,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// IsRoleAllowed checks whether role grants at least the permissions of required
+func IsRoleAllowed(role, required string) bool {
+	return roleLevels[role] >= roleLevels[required]
+}
+
+type User struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Token struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"userId"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+}
+
+// userRecord is how a user is stored, storm encodes the records as JSON so the hash can't be hidden
+// with a json tag, the records never leave the package
+type userRecord struct {
+	ID           int       `json:"id" storm:"id,increment"`
+	Name         string    `json:"name" storm:"unique"`
+	Role         string    `json:"role"`
+	PasswordHash []byte    `json:"passwordHash"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (r *userRecord) user() *User {
+	return &User{ID: r.ID, Name: r.Name, Role: r.Role, CreatedAt: r.CreatedAt}
+}
+
+type tokenRecord struct {
+	ID        int       `json:"id" storm:"id,increment"`
+	UserID    int       `json:"userId" storm:"index"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash" storm:"unique"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+}
+
+func (r *tokenRecord) token() *Token {
+	return &Token{ID: r.ID, UserID: r.UserID, Name: r.Name, CreatedAt: r.CreatedAt, LastUsed: r.LastUsed}
+}
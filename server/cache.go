@@ -0,0 +1,89 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"net/http"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+type cacheRequest struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+	Pinned   bool   `json:"pinned"`
+}
+
+type pinRequest struct {
+	Pinned *bool `json:"pinned"`
+}
+
+func v2GetCacheHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := api.CacheStats()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+func v2CacheTrackHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req cacheRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Provider == "" {
+			abortWithApiError(c, missingField("provider"))
+			return
+		}
+
+		if req.ID == "" {
+			abortWithApiError(c, missingField("id"))
+			return
+		}
+
+		if err := api.CacheTrack(req.Provider, req.ID, req.Pinned); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func v2PinCachedHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req pinRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Pinned == nil {
+			abortWithApiError(c, missingField("pinned"))
+			return
+		}
+
+		e, err := api.PinCached(c.Param("provider"), c.Param("id"), *req.Pinned)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, e)
+	}
+}
+
+func v2RemoveCachedHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.RemoveCached(c.Param("provider"), c.Param("id")); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,328 @@
+package cache
+
+import (
+	"errors"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/models"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDir     = "cache"
+	defaultMaxSize = 1024
+	megabyte       = 1024 * 1024
+)
+
+var (
+	ErrDisabled = errors.New("The cache is disabled")
+	ErrNotFound = errors.New("The track isn't cached")
+	ErrTooLarge = errors.New("The track is larger than the cache")
+
+	// c is nil while the cache is disabled
+	c *Cache
+)
+
+// Entry is a downloaded track
+type Entry struct {
+	// ID is provider/id of the track
+	ID string `json:"id" storm:"id"`
+	// Track is the track without its stream url
+	Track  models.Track `json:"track"`
+	File   string       `json:"file"`
+	Size   int64        `json:"size"`
+	Pinned bool         `json:"pinned"`
+	// LastUsed is when the track was downloaded or last played from the cache, the least recently used tracks are evicted first
+	LastUsed  time.Time `json:"lastUsed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Download is a download in progress
+type Download struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Bytes int64  `json:"bytes"`
+	// Total is -1 when the length of the stream is unknown
+	Total int64 `json:"total"`
+
+	pinned bool
+}
+
+// Stats describe the contents of the cache, the sizes are in bytes
+type Stats struct {
+	Size      int64      `json:"size"`
+	MaxSize   int64      `json:"maxSize"`
+	Entries   []Entry    `json:"entries"`
+	Downloads []Download `json:"downloads"`
+}
+
+// Cache keeps the downloaded tracks in a dir, the entries are kept in the database
+type Cache struct {
+	dir     string
+	maxSize int64
+	store   store
+	client  *http.Client
+	// inUse returns the ids of the tracks the players have open, they aren't evicted
+	inUse func() []string
+
+	mutex     sync.Mutex
+	downloads map[string]*Download
+	// evictMutex keeps two evictions from removing the same entries
+	evictMutex sync.Mutex
+}
+
+func newCache(dir string, maxSize int64, s store) *Cache {
+	return &Cache{
+		dir:       dir,
+		maxSize:   maxSize,
+		store:     s,
+		client:    newClient(idleTimeout),
+		inUse:     func() []string { return nil },
+		downloads: make(map[string]*Download),
+	}
+}
+
+// Init enables the cache when it's configured, the partial downloads of the previous runs are removed.
+// inUse returns the ids of the tracks being played, which are kept until they're done
+func Init(cfg config.CacheConfig, inUse func() []string) error {
+	if err := initStore(); err != nil {
+		return err
+	}
+
+	if !cfg.Enabled {
+		return nil
+	}
+
+	dir, maxSize := cfg.Dir, cfg.MaxSize
+	if dir == "" {
+		dir = defaultDir
+	}
+
+	if maxSize == 0 {
+		maxSize = defaultMaxSize
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	parts, err := filepath.Glob(filepath.Join(dir, "*"+partSuffix))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range parts {
+		if err := os.Remove(p); err != nil {
+			log.Println(err)
+		}
+	}
+
+	c = newCache(dir, maxSize*megabyte, dbStore{})
+	c.inUse = inUse
+	return nil
+}
+
+func Enabled() bool {
+	return c != nil
+}
+
+// Key returns the id of the entry of a track
+func Key(provider, id string) string {
+	return strings.ToLower(provider) + "/" + id
+}
+
+// Get returns a cached track with its stream url pointing to the cached file
+func Get(provider, id string) (models.Track, bool) {
+	if c == nil {
+		return models.Track{}, false
+	}
+
+	return c.get(Key(provider, id))
+}
+
+// Fetch downloads a track in the background, t needs its stream url.
+// The tracks already cached or being downloaded are only pinned when pinned is set
+func Fetch(t models.Track, pinned bool) error {
+	if c == nil {
+		return ErrDisabled
+	}
+
+	return c.fetch(t, pinned)
+}
+
+func All() (Stats, error) {
+	if c == nil {
+		return Stats{}, ErrDisabled
+	}
+
+	return c.stats()
+}
+
+// Pin keeps a track from being evicted, or lets it be evicted again
+func Pin(id string, pinned bool) (Entry, error) {
+	if c == nil {
+		return Entry{}, ErrDisabled
+	}
+
+	return c.pin(id, pinned)
+}
+
+func Remove(id string) error {
+	if c == nil {
+		return ErrDisabled
+	}
+
+	return c.remove(id)
+}
+
+func (c *Cache) path(e Entry) string {
+	return filepath.Join(c.dir, e.File)
+}
+
+func fileURL(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func (c *Cache) get(id string) (models.Track, bool) {
+	e, err := c.store.get(id)
+	if err != nil {
+		if err != ErrNotFound {
+			log.Println(err)
+		}
+		return models.Track{}, false
+	}
+
+	path := c.path(e)
+	if _, err := os.Stat(path); err != nil {
+		// the file was removed behind our back
+		log.Println(err)
+		if err := c.store.remove(&e); err != nil {
+			log.Println(err)
+		}
+		return models.Track{}, false
+	}
+
+	e.LastUsed = time.Now()
+	if err := c.store.save(&e); err != nil {
+		log.Println(err)
+	}
+
+	t := e.Track
+	t.StreamUrl = fileURL(path)
+	return t, true
+}
+
+func (c *Cache) stats() (Stats, error) {
+	entries, err := c.store.all()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	sort.Sort(byLastUsed(entries))
+	res := Stats{MaxSize: c.maxSize, Entries: entries, Downloads: make([]Download, 0)}
+	for _, e := range entries {
+		res.Size += e.Size
+	}
+
+	c.mutex.Lock()
+	for _, d := range c.downloads {
+		res.Downloads = append(res.Downloads, *d)
+	}
+	c.mutex.Unlock()
+
+	return res, nil
+}
+
+func (c *Cache) pin(id string, pinned bool) (Entry, error) {
+	e, err := c.store.get(id)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e.Pinned = pinned
+	if err := c.store.save(&e); err != nil {
+		return Entry{}, err
+	}
+
+	// unpinned entries may be over the size limit
+	if !pinned {
+		c.evict()
+	}
+
+	return e, nil
+}
+
+func (c *Cache) remove(id string) error {
+	e, err := c.store.get(id)
+	if err != nil {
+		return err
+	}
+
+	return c.removeEntry(e)
+}
+
+func (c *Cache) removeEntry(e Entry) error {
+	if err := os.Remove(c.path(e)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return c.store.remove(&e)
+}
+
+type byLastUsed []Entry
+
+func (e byLastUsed) Len() int           { return len(e) }
+func (e byLastUsed) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byLastUsed) Less(i, j int) bool { return e[i].LastUsed.Before(e[j].LastUsed) }
+
+// evict removes the least recently used entries which aren't pinned or being played until the cache fits its size limit
+func (c *Cache) evict() {
+	c.evictMutex.Lock()
+	defer c.evictMutex.Unlock()
+
+	entries, err := c.store.all()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+
+	inUse := make(map[string]bool)
+	for _, id := range c.inUse() {
+		inUse[id] = true
+	}
+
+	sort.Sort(byLastUsed(entries))
+	for _, e := range entries {
+		if size <= c.maxSize {
+			return
+		}
+
+		if e.Pinned || inUse[e.ID] {
+			continue
+		}
+
+		if err := c.removeEntry(e); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		size -= e.Size
+	}
+}
@@ -0,0 +1,299 @@
+package cache
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mutex   sync.Mutex
+	entries map[string]Entry
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]Entry)}
+}
+
+func (s *memoryStore) get(id string) (Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+
+	return e, nil
+}
+
+func (s *memoryStore) all() ([]Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		res = append(res, e)
+	}
+
+	return res, nil
+}
+
+func (s *memoryStore) save(e *Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[e.ID] = *e
+	return nil
+}
+
+func (s *memoryStore) remove(e *Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, e.ID)
+	return nil
+}
+
+func newTestCache(t *testing.T, maxSize int64) (*Cache, *memoryStore, func()) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newMemoryStore()
+	return newCache(dir, maxSize, s), s, func() { os.RemoveAll(dir) }
+}
+
+// streams serves a body of the size in the path, e.g. /100
+func streams() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "audio/webm")
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.Write([]byte(strings.Repeat("a", size)))
+	}))
+}
+
+func track(server *httptest.Server, id string, size int) models.Track {
+	return models.Track{Provider: "YouTube", ID: id, Title: id, StreamUrl: server.URL + "/" + strconv.Itoa(size)}
+}
+
+func download(t *testing.T, c *Cache, tr models.Track, pinned bool) {
+	d := &Download{ID: Key(tr.Provider, tr.ID), pinned: pinned}
+	if err := c.download(tr, d); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := streams()
+	defer server.Close()
+	c, s, cleanup := newTestCache(t, 1000)
+	defer cleanup()
+
+	download(t, c, track(server, "abc", 100), false)
+
+	e, err := s.get("youtube/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Size != 100 || e.File != "youtube_abc.webm" || e.Track.StreamUrl != "" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+
+	info, err := os.Stat(filepath.Join(c.dir, e.File))
+	if err != nil || info.Size() != 100 {
+		t.Errorf("The file wasn't downloaded: %v", err)
+	}
+
+	parts, _ := filepath.Glob(filepath.Join(c.dir, "*"+partSuffix))
+	if len(parts) != 0 {
+		t.Errorf("The part file was left: %v", parts)
+	}
+
+	got, ok := c.get("youtube/abc")
+	if !ok || got.StreamUrl != fileURL(filepath.Join(c.dir, e.File)) || got.Title != "abc" {
+		t.Errorf("Unexpected cached track %+v", got)
+	}
+}
+
+func TestFetchRunsInBackground(t *testing.T) {
+	server := streams()
+	defer server.Close()
+	c, s, cleanup := newTestCache(t, 1000)
+	defer cleanup()
+
+	c.fetch(track(server, "abc", 100), true)
+	for i := 0; i < 100; i++ {
+		if e, err := s.get("youtube/abc"); err == nil {
+			if !e.Pinned {
+				t.Error("The entry isn't pinned")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("The track wasn't cached")
+}
+
+func TestFetchPinsCachedTracks(t *testing.T) {
+	server := streams()
+	defer server.Close()
+	c, s, cleanup := newTestCache(t, 1000)
+	defer cleanup()
+
+	tr := track(server, "abc", 100)
+	download(t, c, tr, false)
+	if err := c.fetch(tr, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, _ := s.get("youtube/abc"); !e.Pinned {
+		t.Error("The cached entry wasn't pinned")
+	}
+
+	// a download in progress is pinned once it completes
+	d := &Download{ID: "youtube/def"}
+	c.downloads[d.ID] = d
+	if err := c.fetch(track(server, "def", 100), true); err != nil || !d.pinned {
+		t.Errorf("The download wasn't pinned: %v", err)
+	}
+}
+
+func TestStalledDownloadFails(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/webm")
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("aaaa"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c, s, cleanup := newTestCache(t, 1000)
+	defer cleanup()
+	c.client = newClient(50 * time.Millisecond)
+
+	c.fetch(models.Track{Provider: "YouTube", ID: "abc", StreamUrl: server.URL}, false)
+	for i := 0; i < 200; i++ {
+		c.mutex.Lock()
+		n := len(c.downloads)
+		c.mutex.Unlock()
+
+		if n == 0 {
+			if _, err := s.get("youtube/abc"); err != ErrNotFound {
+				t.Errorf("The stalled track was cached: %v", err)
+			}
+
+			if parts, _ := filepath.Glob(filepath.Join(c.dir, "*"+partSuffix)); len(parts) != 0 {
+				t.Errorf("The part file was left: %v", parts)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("The stalled download wasn't dropped")
+}
+
+func TestGetDropsMissingFiles(t *testing.T) {
+	c, s, cleanup := newTestCache(t, 1000)
+	defer cleanup()
+
+	s.save(&Entry{ID: "youtube/abc", File: "youtube_abc.webm", Size: 100})
+	if _, ok := c.get("youtube/abc"); ok {
+		t.Error("A missing file was returned")
+	}
+
+	if _, err := s.get("youtube/abc"); err != ErrNotFound {
+		t.Errorf("The entry wasn't removed: %v", err)
+	}
+}
+
+func TestEvictionKeepsPinnedAndRecent(t *testing.T) {
+	server := streams()
+	defer server.Close()
+	c, s, cleanup := newTestCache(t, 250)
+	defer cleanup()
+
+	download(t, c, track(server, "pinned", 100), true)
+	download(t, c, track(server, "old", 100), false)
+	download(t, c, track(server, "new", 100), false)
+
+	if _, err := s.get("youtube/old"); err != ErrNotFound {
+		t.Errorf("The least recently used track wasn't evicted: %v", err)
+	}
+
+	for _, id := range []string{"youtube/pinned", "youtube/new"} {
+		if _, err := s.get(id); err != nil {
+			t.Errorf("%s was evicted", id)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(c.dir, "youtube_old.webm")); !os.IsNotExist(err) {
+		t.Errorf("The evicted file wasn't removed: %v", err)
+	}
+
+	// once unpinned the track is the least recently used one
+	if _, err := c.pin("youtube/pinned", false); err != nil {
+		t.Fatal(err)
+	}
+	download(t, c, track(server, "newest", 100), false)
+
+	if _, err := s.get("youtube/pinned"); err != ErrNotFound {
+		t.Errorf("The unpinned track wasn't evicted: %v", err)
+	}
+}
+
+func TestEvictionKeepsPlayedTracks(t *testing.T) {
+	server := streams()
+	defer server.Close()
+	c, s, cleanup := newTestCache(t, 150)
+	defer cleanup()
+	c.inUse = func() []string { return []string{"youtube/playing"} }
+
+	download(t, c, track(server, "playing", 100), false)
+	download(t, c, track(server, "new", 100), false)
+
+	if _, err := s.get("youtube/playing"); err != nil {
+		t.Errorf("The played track was evicted: %v", err)
+	}
+
+	if _, err := s.get("youtube/new"); err != ErrNotFound {
+		t.Errorf("Expected the track which isn't played to be evicted: %v", err)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	server := streams()
+	defer server.Close()
+	c, s, cleanup := newTestCache(t, 50)
+	defer cleanup()
+
+	tr := track(server, "abc", 100)
+	if err := c.download(tr, &Download{ID: Key(tr.Provider, tr.ID)}); err != ErrTooLarge {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+
+	if entries, _ := s.all(); len(entries) != 0 {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+}
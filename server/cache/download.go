@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"gngeorgiev/audiotic/server/models"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// partSuffix ends the names of the files being downloaded, they're renamed once they're complete
+	partSuffix = ".part"
+
+	dialTimeout   = 10 * time.Second
+	headerTimeout = 15 * time.Second
+	// idleTimeout is how long a stream may send nothing before its download fails
+	idleTimeout = 30 * time.Second
+)
+
+// extensions are the extensions of the cached files by the content type of the streams
+var extensions = map[string]string{
+	"audio/webm": ".webm",
+	"video/webm": ".webm",
+	"audio/mp4":  ".m4a",
+	"video/mp4":  ".mp4",
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+	"audio/flac": ".flac",
+}
+
+// Extension returns the file extension of a stream by its content type
+func Extension(contentType string) string {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	if ext, ok := extensions[strings.TrimSpace(strings.ToLower(contentType))]; ok {
+		return ext
+	}
+
+	return ".audio"
+}
+
+// fileName returns a name of the file of an entry which is safe on every os
+func fileName(id string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(id)
+}
+
+// idleConn fails the reads which wait longer than the timeout, so a stalled stream doesn't hold its download forever
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c idleConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Read(b)
+}
+
+func newClient(idle time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+	return &http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+
+			return idleConn{conn, idle}, nil
+		},
+		TLSHandshakeTimeout:   dialTimeout,
+		ResponseHeaderTimeout: headerTimeout,
+	}}
+}
+
+// progress counts the downloaded bytes into the download
+type progress struct {
+	c *Cache
+	d *Download
+}
+
+func (p progress) Write(b []byte) (int, error) {
+	p.c.mutex.Lock()
+	p.d.Bytes += int64(len(b))
+	p.c.mutex.Unlock()
+
+	return len(b), nil
+}
+
+func (c *Cache) fetch(t models.Track, pinned bool) error {
+	id := Key(t.Provider, t.ID)
+	if e, err := c.store.get(id); err == nil {
+		if pinned && !e.Pinned {
+			_, err = c.pin(id, true)
+		}
+		return err
+	}
+
+	c.mutex.Lock()
+	if d, ok := c.downloads[id]; ok {
+		// the pin is applied once the download completes
+		d.pinned = d.pinned || pinned
+		c.mutex.Unlock()
+		return nil
+	}
+
+	d := &Download{ID: id, Title: t.Title, Total: -1, pinned: pinned}
+	c.downloads[id] = d
+	c.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			c.mutex.Lock()
+			delete(c.downloads, id)
+			c.mutex.Unlock()
+		}()
+
+		if err := c.download(t, d); err != nil {
+			log.Printf("Caching %s failed: %s", id, err)
+		}
+	}()
+
+	return nil
+}
+
+// download writes the stream of a track to a part file, which is renamed and added to the entries once it's complete
+func (c *Cache) download(t models.Track, d *Download) error {
+	res, err := c.client.Get(t.StreamUrl)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("The stream responded with %s", res.Status)
+	}
+
+	if res.ContentLength > c.maxSize {
+		return ErrTooLarge
+	}
+
+	c.mutex.Lock()
+	d.Total = res.ContentLength
+	c.mutex.Unlock()
+
+	e := Entry{ID: d.ID, File: fileName(d.ID) + Extension(res.Header.Get("Content-Type"))}
+	part := c.path(e) + partSuffix
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+
+	// the streams of an unknown length are cut at the size of the cache
+	n, err := io.Copy(io.MultiWriter(f, progress{c, d}), io.LimitReader(res.Body, c.maxSize+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil && n > c.maxSize {
+		err = ErrTooLarge
+	}
+
+	if err == nil && res.ContentLength >= 0 && n != res.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+
+	if err := os.Rename(part, filepath.Join(c.dir, e.File)); err != nil {
+		os.Remove(part)
+		return err
+	}
+
+	c.mutex.Lock()
+	e.Pinned = d.pinned
+	c.mutex.Unlock()
+
+	t.StreamUrl = ""
+	e.Track, e.Size = t, n
+	e.CreatedAt = time.Now()
+	e.LastUsed = e.CreatedAt
+	if err := c.store.save(&e); err != nil {
+		return err
+	}
+
+	c.evict()
+	return nil
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"gngeorgiev/audiotic/server/database"
+
+	"github.com/asdine/storm"
+)
+
+var db *storm.DB
+
+// store keeps the entries, it's the database outside of the tests
+type store interface {
+	get(id string) (Entry, error)
+	all() ([]Entry, error)
+	save(e *Entry) error
+	remove(e *Entry) error
+}
+
+func initStore() error {
+	db = database.Get()
+	return db.Init(Entry{})
+}
+
+type dbStore struct{}
+
+func (dbStore) get(id string) (Entry, error) {
+	var e Entry
+	if err := db.One("ID", id, &e); err != nil {
+		if err == storm.ErrNotFound {
+			return Entry{}, ErrNotFound
+		}
+
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+func (dbStore) all() ([]Entry, error) {
+	var res []Entry
+	if err := db.All(&res); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if res == nil {
+		res = make([]Entry, 0)
+	}
+
+	return res, nil
+}
+
+func (dbStore) save(e *Entry) error {
+	return db.Save(e)
+}
+
+func (dbStore) remove(e *Entry) error {
+	return db.Remove(e)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiError is the error the v2 api responds with, its codes are the codes of the api package of the server
+type apiError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+}
+
+type errorResponse struct {
+	Error *apiError `json:"error"`
+}
+
+// client calls the v2 api of the server, the player and queue routes go to its zone
+type client struct {
+	server string
+	token  string
+	zone   string
+	http   *http.Client
+
+	streams *http.Client
+}
+
+func newClient(server, token, zone string) *client {
+	return &client{
+		server: strings.TrimSuffix(server, "/"),
+		token:  token,
+		zone:   zone,
+		http:   &http.Client{Timeout: 30 * time.Second},
+
+		streams: &http.Client{},
+	}
+}
+
+func (c *client) zonePath(path string) string {
+	return "/api/v2/zones/" + c.zone + path
+}
+
+func (c *client) newRequest(method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return req, nil
+}
+
+// call sends a request and returns the raw body of a successful response,
+// the error responses of the api are returned as *apiError
+func (c *client) call(method, path string, query url.Values, body interface{}) ([]byte, error) {
+	req, err := c.newRequest(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, responseError(res.StatusCode, b)
+	}
+
+	return b, nil
+}
+
+// stream opens a response which isn't read at once, like the server-sent events, so it has no timeout
+func (c *client) stream(path string, header http.Header) (*http.Response, error) {
+	req, err := c.newRequest(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := c.streams.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, responseError(res.StatusCode, b)
+	}
+
+	return res, nil
+}
+
+// do calls the api and decodes the response into out, when out isn't nil
+func (c *client) do(method, path string, query url.Values, body, out interface{}) error {
+	b, err := c.call(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+func responseError(statusCode int, body []byte) error {
+	var res errorResponse
+	if err := json.Unmarshal(body, &res); err == nil && res.Error != nil {
+		return res.Error
+	}
+
+	return fmt.Errorf("%s: %s", http.StatusText(statusCode), strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"gngeorgiev/audiotic/server/models"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/urfave/cli"
+)
+
+// status holds the fields of the player status the commands print
+type status struct {
+	Zone       string `json:"zone"`
+	Length     int    `json:"length"`
+	Time       int    `json:"time"`
+	Volume     int    `json:"volume"`
+	Name       string `json:"name"`
+	Artist     string `json:"artist"`
+	CleanTitle string `json:"cleanTitle"`
+	Source     string `json:"source"`
+	State      string `json:"state"`
+	QueuedBy   string `json:"queuedBy"`
+	Autoplayed bool   `json:"autoplayed"`
+}
+
+type trackRequest struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+func usageError(c *cli.Context, command string) error {
+	cli.ShowCommandHelp(c, command)
+	return cli.NewExitError("", 1)
+}
+
+// printRaw prints a response of the server as it is, used for the json output
+func printRaw(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(stdout, strings.TrimSpace(string(b)))
+	return err
+}
+
+// title prefers the parsed artist and title over the raw title
+func title(raw, artist, clean string) string {
+	if artist == "" || clean == "" {
+		return raw
+	}
+
+	return artist + " - " + clean
+}
+
+func trackLine(t models.Track) string {
+	return fmt.Sprintf("%s  %s/%s", title(t.Title, t.Artist, t.CleanTitle), strings.ToLower(t.Provider), t.ID)
+}
+
+// nowPlaying describes the status, the time and the length of the track are included when progress is true
+func nowPlaying(s status, progress bool) string {
+	switch {
+	case models.IsActiveState(s.State):
+		s.State = "Playing"
+	case s.State == models.StatePaused:
+		s.State = "Paused"
+	default:
+		return "Stopped"
+	}
+
+	name := title(s.Name, s.Artist, s.CleanTitle)
+	res := fmt.Sprintf("%s: %s volume %d", s.State, name, s.Volume)
+	if progress {
+		res = fmt.Sprintf("%s: %s [%s/%s] volume %d", s.State, name, formatTime(s.Time), formatTime(s.Length), s.Volume)
+	}
+
+	if s.Autoplayed {
+		res += ", autoplayed"
+	} else if s.QueuedBy != "" {
+		res += ", queued by " + s.QueuedBy
+	}
+
+	return res
+}
+
+func getStatus() (status, error) {
+	var s status
+	err := server.do(http.MethodGet, server.zonePath("/player/status"), nil, nil, &s)
+	return s, err
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// pick asks for the position of one of n items, false is returned when the user picks nothing
+func pick(in io.Reader, out io.Writer, n int) (int, bool, error) {
+	r := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Pick a track [1-%d, enter to cancel]: ", n)
+
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if err != nil && err != io.EOF {
+				return 0, false, err
+			}
+
+			return 0, false, nil
+		}
+
+		if i, convErr := strconv.Atoi(line); convErr == nil && i >= 1 && i <= n {
+			return i - 1, true, nil
+		}
+
+		if err != nil {
+			return 0, false, nil
+		}
+	}
+}
+
+func searchAction(c *cli.Context) error {
+	query := strings.TrimSpace(strings.Join(c.Args(), " "))
+	if query == "" {
+		return usageError(c, "search")
+	}
+
+	b, err := server.call(http.MethodGet, "/api/v2/meta/search", url.Values{"q": {query}}, nil)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printRaw(b)
+	}
+
+	var tracks []models.Track
+	if err := json.Unmarshal(b, &tracks); err != nil {
+		return err
+	}
+
+	if len(tracks) == 0 {
+		fmt.Fprintln(stdout, "No tracks found")
+		return nil
+	}
+
+	for i, t := range tracks {
+		fmt.Fprintf(stdout, "%3d. %s\n", i+1, trackLine(t))
+	}
+
+	if c.Bool("no-select") || !isTerminal(os.Stdin) {
+		return nil
+	}
+
+	i, ok, err := pick(os.Stdin, stdout, len(tracks))
+	if err != nil || !ok {
+		return err
+	}
+
+	if c.Bool("queue") {
+		return enqueue(tracks[i].Provider, tracks[i].ID)
+	}
+
+	return play(tracks[i].Provider, tracks[i].ID)
+}
+
+func play(provider, id string) error {
+	return server.do(http.MethodPost, server.zonePath("/player/play"), nil, trackRequest{provider, id}, nil)
+}
+
+func enqueue(provider, id string) error {
+	b, err := server.call(http.MethodPost, server.zonePath("/queue"), nil, trackRequest{provider, id})
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printRaw(b)
+	}
+
+	var t models.Track
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Queued %s\n", trackLine(t))
+	return nil
+}
+
+func playAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return usageError(c, "play")
+	}
+
+	provider, id, err := models.ParseTrack(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return play(provider, id)
+}
+
+// playerAction returns the action of the player commands which take no arguments
+func playerAction(path string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		return server.do(http.MethodPost, server.zonePath(path), nil, nil, nil)
+	}
+}
+
+func seekAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return usageError(c, "seek")
+	}
+
+	t, relative, err := parseTime(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if relative {
+		s, err := getStatus()
+		if err != nil {
+			return err
+		}
+
+		if t += s.Time; t < 0 {
+			t = 0
+		}
+	}
+
+	return server.do(http.MethodPut, server.zonePath("/player/seek"), nil, map[string]int{"time": t}, nil)
+}
+
+func volumeAction(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return usageError(c, "volume")
+	}
+
+	s, err := getStatus()
+	if err != nil {
+		return err
+	}
+
+	if c.NArg() == 0 {
+		if jsonOutput {
+			return json.NewEncoder(stdout).Encode(map[string]int{"volume": s.Volume})
+		}
+
+		fmt.Fprintln(stdout, s.Volume)
+		return nil
+	}
+
+	arg := c.Args().First()
+	volume, err := strconv.Atoi(arg)
+	if err != nil {
+		return errors.New("Expected a volume like 50, +10 or -10")
+	}
+
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		volume += s.Volume
+	}
+
+	return server.do(http.MethodPut, server.zonePath("/player/volume"), nil, map[string]int{"volume": volume}, nil)
+}
+
+func statusAction(c *cli.Context) error {
+	b, err := server.call(http.MethodGet, server.zonePath("/player/status"), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printRaw(b)
+	}
+
+	var s status
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, nowPlaying(s, true))
+	return nil
+}
+
+func historyAction(c *cli.Context) error {
+	b, err := server.call(http.MethodGet, "/api/v2/history", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	var tracks []models.Track
+	if err := json.Unmarshal(b, &tracks); err != nil {
+		return err
+	}
+
+	if limit := c.Int("limit"); limit > 0 && len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
+	if jsonOutput {
+		return json.NewEncoder(stdout).Encode(tracks)
+	}
+
+	for _, t := range tracks {
+		fmt.Fprintf(stdout, "%s  %s\n", t.LastPlayed.Local().Format("2006-01-02 15:04"), trackLine(t))
+	}
+
+	return nil
+}
+
+func queueAction(c *cli.Context) error {
+	b, err := server.call(http.MethodGet, server.zonePath("/queue"), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printRaw(b)
+	}
+
+	var tracks []models.Track
+	if err := json.Unmarshal(b, &tracks); err != nil {
+		return err
+	}
+
+	if len(tracks) == 0 {
+		fmt.Fprintln(stdout, "The queue is empty")
+		return nil
+	}
+
+	for i, t := range tracks {
+		line := fmt.Sprintf("%3d. %s", i+1, trackLine(t))
+		if t.QueuedBy != "" {
+			line += ", queued by " + t.QueuedBy
+		}
+
+		fmt.Fprintln(stdout, line)
+	}
+
+	return nil
+}
+
+func queueAddAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return usageError(c, "add")
+	}
+
+	provider, id, err := models.ParseTrack(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return enqueue(provider, id)
+}
+
+// queueRemoveAction takes the position printed by the queue command, positions start from 1
+func queueRemoveAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return usageError(c, "remove")
+	}
+
+	position, err := strconv.Atoi(c.Args().First())
+	if err != nil || position < 1 {
+		return errors.New("Expected the position of a track in the queue")
+	}
+
+	return server.do(http.MethodDelete, server.zonePath("/queue/"+strconv.Itoa(position-1)), nil, nil, nil)
+}
+
+func queueClearAction(c *cli.Context) error {
+	return server.do(http.MethodDelete, server.zonePath("/queue"), nil, nil, nil)
+}
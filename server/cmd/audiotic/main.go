@@ -0,0 +1,160 @@
+// Command audiotic controls an audiotic server from the command line
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+var (
+	server     *client
+	jsonOutput bool
+
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "audiotic"
+	app.Usage = "control an audiotic server"
+	app.Version = "0.1.0"
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "server, s",
+			Usage:  "url of the server",
+			Value:  "http://localhost:8090",
+			EnvVar: "AUDIOTIC_SERVER",
+		},
+		cli.StringFlag{
+			Name:   "token, t",
+			Usage:  "api token, needed when the server has auth enabled",
+			EnvVar: "AUDIOTIC_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "zone, z",
+			Usage:  "zone to control",
+			Value:  "default",
+			EnvVar: "AUDIOTIC_ZONE",
+		},
+		cli.BoolFlag{
+			Name:   "json, j",
+			Usage:  "print the responses of the server as json",
+			EnvVar: "AUDIOTIC_JSON",
+		},
+	}
+
+	app.Before = func(c *cli.Context) error {
+		server = newClient(c.GlobalString("server"), c.GlobalString("token"), c.GlobalString("zone"))
+		jsonOutput = c.GlobalBool("json")
+		return nil
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:      "search",
+			Usage:     "search for tracks and pick one to play",
+			ArgsUsage: "<query>",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "queue, q", Usage: "queue the picked track instead of playing it"},
+				cli.BoolFlag{Name: "no-select, n", Usage: "only list the results"},
+			},
+			Action: searchAction,
+		},
+		{
+			Name:      "play",
+			Usage:     "play a track",
+			ArgsUsage: "<provider/id | url>",
+			Action:    playAction,
+		},
+		{
+			Name:   "pause",
+			Usage:  "pause the playback",
+			Action: playerAction("/player/pause"),
+		},
+		{
+			Name:   "resume",
+			Usage:  "resume the playback",
+			Action: playerAction("/player/resume"),
+		},
+		{
+			Name:   "stop",
+			Usage:  "stop the playback",
+			Action: playerAction("/player/stop"),
+		},
+		{
+			Name:   "next",
+			Usage:  "play the next track",
+			Action: playerAction("/player/next"),
+		},
+		{
+			Name:      "seek",
+			Usage:     "seek to a time, times starting with + or - are relative to the current time",
+			ArgsUsage: "<90 | 1:30 | 2m | +30s | -10>",
+			Action:    seekAction,
+		},
+		{
+			Name:      "volume",
+			Aliases:   []string{"vol"},
+			Usage:     "print or set the volume, volumes starting with + or - are relative to the current volume",
+			ArgsUsage: "[<volume | +10 | -10>]",
+			Action:    volumeAction,
+		},
+		{
+			Name:   "status",
+			Usage:  "print what's playing",
+			Action: statusAction,
+		},
+		{
+			Name:  "history",
+			Usage: "print the recently played tracks",
+			Flags: []cli.Flag{
+				cli.IntFlag{Name: "limit, l", Usage: "number of tracks to print, 0 prints all of them", Value: 20},
+			},
+			Action: historyAction,
+		},
+		{
+			Name:   "queue",
+			Usage:  "print or change the queue",
+			Action: queueAction,
+			Subcommands: []cli.Command{
+				{
+					Name:      "add",
+					Usage:     "add a track to the end of the queue",
+					ArgsUsage: "<provider/id | url>",
+					Action:    queueAddAction,
+				},
+				{
+					Name:      "remove",
+					Aliases:   []string{"rm"},
+					Usage:     "remove a track from the queue",
+					ArgsUsage: "<position>",
+					Action:    queueRemoveAction,
+				},
+				{
+					Name:   "clear",
+					Usage:  "remove all tracks from the queue",
+					Action: queueClearAction,
+				},
+			},
+		},
+		{
+			Name:   "watch",
+			Usage:  "follow the updates of the zone and print what's playing",
+			Action: watchAction,
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		// the usage errors are empty, the help of the command is printed instead
+		if msg := err.Error(); msg != "" {
+			fmt.Fprintln(stderr, msg)
+		}
+
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+var ErrInvalidTime = errors.New("Expected a time like 90, 1:30, 2m, +30s or -1m")
+
+// parseTime parses seconds, mm:ss or a duration. The returned bool is true when the time
+// starts with + or - and is relative to the current time
+func parseTime(s string) (int, bool, error) {
+	relative, sign := false, 1
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		relative = true
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+
+	if s == "" {
+		return 0, false, ErrInvalidTime
+	}
+
+	if seconds, err := strconv.Atoi(s); err == nil && seconds >= 0 {
+		return sign * seconds, relative, nil
+	}
+
+	if parts := strings.Split(s, ":"); len(parts) == 2 {
+		minutes, err := strconv.Atoi(parts[0])
+		if err != nil || minutes < 0 {
+			return 0, false, ErrInvalidTime
+		}
+
+		seconds, err := strconv.Atoi(parts[1])
+		if err != nil || seconds < 0 || seconds >= 60 {
+			return 0, false, ErrInvalidTime
+		}
+
+		return sign * (minutes*60 + seconds), relative, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, false, ErrInvalidTime
+	}
+
+	return sign * int(d/time.Second), relative, nil
+}
+
+// formatTime formats seconds as m:ss
+func formatTime(seconds int) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseTimeSupportsRelativeTimes(t *testing.T) {
+	for _, c := range []struct {
+		in       string
+		seconds  int
+		relative bool
+	}{
+		{"90", 90, false},
+		{"1:30", 90, false},
+		{"2m", 120, false},
+		{"+30s", 30, true},
+		{"+30", 30, true},
+		{"-1m", -60, true},
+		{"-0:10", -10, true},
+	} {
+		seconds, relative, err := parseTime(c.in)
+		if err != nil || seconds != c.seconds || relative != c.relative {
+			t.Errorf("Expected %d %v for %s, got %d %v %v", c.seconds, c.relative, c.in, seconds, relative, err)
+		}
+	}
+
+	for _, in := range []string{"", "+", "1:60", "abc", "-5s5"} {
+		if _, _, err := parseTime(in); err != ErrInvalidTime {
+			t.Errorf("Expected %s to be rejected, got %v", in, err)
+		}
+	}
+}
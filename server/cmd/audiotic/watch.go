@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// sseEvent is an event of the server-sent events of the zone, the types are the types of the control protocol
+type sseEvent struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// readEvents reads server-sent events until r is closed, comments and unknown fields are skipped
+func readEvents(r io.Reader, fn func(e sseEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var e sseEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				e.Data = strings.Join(data, "\n")
+				if err := fn(e); err != nil {
+					return err
+				}
+			}
+
+			e, data = sseEvent{}, nil
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "id":
+			e.ID = value
+		case "event":
+			e.Type = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
+
+// watcher prints the now playing line whenever the track or the state of the player changes
+type watcher struct {
+	lastID string
+	last   string
+}
+
+func (w *watcher) event(e sseEvent) error {
+	if e.ID != "" {
+		w.lastID = e.ID
+	}
+
+	if jsonOutput {
+		return json.NewEncoder(stdout).Encode(map[string]interface{}{
+			"type": e.Type,
+			"data": json.RawMessage(e.Data),
+		})
+	}
+
+	switch e.Type {
+	case "status":
+		var s status
+		if err := json.Unmarshal([]byte(e.Data), &s); err != nil {
+			return err
+		}
+
+		// the time changes on every status, only the changes of the track or the state are printed
+		if line := nowPlaying(s, false); line != w.last {
+			w.last = line
+			fmt.Fprintf(stdout, "%s %s\n", time.Now().Format("15:04:05"), line)
+		}
+	case "error":
+		var apiErr apiError
+		if err := json.Unmarshal([]byte(e.Data), &apiErr); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "%s Error: %s\n", time.Now().Format("15:04:05"), apiErr.Message)
+	}
+
+	return nil
+}
+
+func (w *watcher) follow() error {
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	if w.lastID != "" {
+		header.Set("Last-Event-ID", w.lastID)
+	}
+
+	res, err := server.stream(server.zonePath("/player/events"), header)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return readEvents(res.Body, w.event)
+}
+
+// watchAction follows the events of the zone until interrupted, it reconnects when the connection drops
+// and resumes from the last event it has seen. The errors of the api, like a wrong token, stop it
+func watchAction(c *cli.Context) error {
+	w := &watcher{}
+	delay := minReconnectDelay
+
+	for {
+		started := time.Now()
+		err := w.follow()
+		if _, ok := err.(*apiError); ok {
+			return err
+		}
+
+		if time.Since(started) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+
+		fmt.Fprintf(stderr, "Disconnected: %v, reconnecting in %s\n", err, delay)
+		time.Sleep(delay)
+
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadEventsJoinsTheDataLines(t *testing.T) {
+	stream := ": keep-alive\n\n" +
+		"id: 7\nevent: status\ndata: {\"state\":\"playing\"}\n\n" +
+		"event: queue\ndata: [\ndata: ]\n\n"
+
+	var events []sseEvent
+	err := readEvents(strings.NewReader(stream), func(e sseEvent) error {
+		events = append(events, e)
+		return nil
+	})
+
+	if err != io.EOF {
+		t.Fatalf("Expected the end of the stream, got %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v", events)
+	}
+
+	if e := events[0]; e.ID != "7" || e.Type != "status" || e.Data != `{"state":"playing"}` {
+		t.Errorf("Expected the status event, got %v", e)
+	}
+
+	if e := events[1]; e.ID != "" || e.Type != "queue" || e.Data != "[\n]" {
+		t.Errorf("Expected the queue event, got %v", e)
+	}
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+const (
+	defaultPath = "config.json"
+	pathEnv     = "AUDIOTIC_CONFIG"
+)
+
+type AuthConfig struct {
+	// Enabled requires a token or a password on every route, it's off by default so a fresh install
+	// works without setup, but then everyone who can reach the api port is an admin
+	Enabled bool `json:"enabled"`
+}
+
+// ZoneConfig describes a zone, a player with its own queue, e.g. one per room
+type ZoneConfig struct {
+	Name string `json:"name"`
+	// Volume is the volume the zone starts with, the player default is used when it's 0
+	Volume int `json:"volume"`
+	// Output and OutputDevice pick the libvlc audio output, a device chosen through the api takes precedence
+	Output       string `json:"output"`
+	OutputDevice string `json:"outputDevice"`
+}
+
+// StreamingConfig enables the http stream of the zones, it's transcoded to ogg/opus by libvlc
+type StreamingConfig struct {
+	Enabled bool `json:"enabled"`
+	// Bitrate is in kbit/s, 128 when it's 0
+	Bitrate int `json:"bitrate"`
+}
+
+// MPDConfig enables the MPD protocol server, so the MPD clients can control a zone
+type MPDConfig struct {
+	Enabled bool `json:"enabled"`
+	// Address is :6600 when it's empty
+	Address string `json:"address"`
+	// Zone is the zone the MPD clients control, the default zone when it's empty
+	Zone string `json:"zone"`
+}
+
+// MQTTConfig enables the MQTT bridge, it publishes the status of the zones and takes commands for home automation
+type MQTTConfig struct {
+	Enabled bool `json:"enabled"`
+	// Broker is host:port or a url like tcp://localhost:1883, ssl:// connects with tls, localhost:1883 when it's empty
+	Broker   string `json:"broker"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// ClientID is audiotic when it's empty, it has to be unique per broker
+	ClientID string `json:"clientId"`
+	// Prefix is the root of the topics, audiotic when it's empty
+	Prefix string `json:"prefix"`
+	// DiscoveryPrefix is the Home Assistant discovery prefix, homeassistant when it's empty
+	DiscoveryPrefix string `json:"discoveryPrefix"`
+}
+
+// ScrobblerConfig enables scrobbling the played tracks, each service is used when its credentials are set
+type ScrobblerConfig struct {
+	Enabled bool `json:"enabled"`
+	// Zones are the zones whose tracks are scrobbled, all of them when it's empty
+	Zones        []string           `json:"zones"`
+	LastFM       LastFMConfig       `json:"lastfm"`
+	ListenBrainz ListenBrainzConfig `json:"listenbrainz"`
+}
+
+// LastFMConfig is a Last.fm account, the session key is requested with the username and password when it's empty
+type LastFMConfig struct {
+	// URL is the api root, Last.fm when it's empty, compatible services like Libre.fm work too
+	URL        string `json:"url"`
+	APIKey     string `json:"apiKey"`
+	Secret     string `json:"secret"`
+	SessionKey string `json:"sessionKey"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+// ListenBrainzConfig is a ListenBrainz account
+type ListenBrainzConfig struct {
+	// URL is the api root, ListenBrainz when it's empty, compatible servers like Maloja work too
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// MetadataConfig enables looking up the artist and the album of the played tracks
+type MetadataConfig struct {
+	// Lookup looks up the tracks in a MusicBrainz compatible service after their titles are parsed
+	Lookup bool `json:"lookup"`
+	// URL is the api root, MusicBrainz when it's empty
+	URL string `json:"url"`
+}
+
+// LyricsConfig configures the lyrics sources
+type LyricsConfig struct {
+	// Sources are the sources tried in order, local, lrclib and youtube, all of them in this order when it's empty
+	Sources []string `json:"sources"`
+	// Dir is searched for "Artist - Title.lrc" files, the .lrc files next to local tracks are found either way
+	Dir string `json:"dir"`
+	// LRCLIB is the api root of an LRCLIB compatible service, lrclib.net when it's empty
+	LRCLIB string `json:"lrclib"`
+	// Languages are the preferred languages of the captions, en when it's empty
+	Languages []string `json:"languages"`
+}
+
+// CacheConfig enables downloading the played tracks, so they're played from the disk when they're played again
+type CacheConfig struct {
+	Enabled bool `json:"enabled"`
+	// Dir is where the tracks are downloaded, cache when it's empty
+	Dir string `json:"dir"`
+	// MaxSize is in megabytes, the least recently played tracks which aren't pinned are evicted beyond it,
+	// 1024 when it's 0
+	MaxSize int64 `json:"maxSize"`
+}
+
+// ExportConfig configures downloading the tracks as audio files, they're transcoded by ffmpeg
+type ExportConfig struct {
+	// FFmpeg is the path of the ffmpeg executable, it's looked up in the PATH when it's empty
+	FFmpeg string `json:"ffmpeg"`
+}
+
+type Config struct {
+	// CorsOrigins are the origins allowed to call the api, the bundled web UI is always allowed
+	CorsOrigins []string   `json:"corsOrigins"`
+	Auth        AuthConfig `json:"auth"`
+	// Zones are created on start next to the default zone
+	Zones     []ZoneConfig    `json:"zones"`
+	Streaming StreamingConfig `json:"streaming"`
+	MPD       MPDConfig       `json:"mpd"`
+	MQTT      MQTTConfig      `json:"mqtt"`
+	Scrobbler ScrobblerConfig `json:"scrobbler"`
+	Metadata  MetadataConfig  `json:"metadata"`
+	Lyrics    LyricsConfig    `json:"lyrics"`
+	Cache     CacheConfig     `json:"cache"`
+	Export    ExportConfig    `json:"export"`
+}
+
+var (
+	once   sync.Once
+	config = &Config{}
+)
+
+// Load reads the config from the json file in AUDIOTIC_CONFIG or config.json,
+// a missing file leaves the defaults in place
+func Load() error {
+	var err error
+	once.Do(func() {
+		path := os.Getenv(pathEnv)
+		if path == "" {
+			path = defaultPath
+		}
+
+		f, e := os.Open(path)
+		if e != nil {
+			if !os.IsNotExist(e) {
+				err = e
+			}
+			return
+		}
+		defer f.Close()
+
+		err = json.NewDecoder(f).Decode(config)
+	})
+
+	return err
+}
+
+func Get() *Config {
+	return config
+}
@@ -0,0 +1,316 @@
+package controlProtocol
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/history"
+	"log"
+	"sort"
+)
+
+// methodFunc executes a method in the zone of the session it was sent over
+type methodFunc func(caller auth.Caller, zone string, params json.RawMessage) (interface{}, error)
+
+type method struct {
+	role string
+	call methodFunc
+}
+
+func listen(f methodFunc) method {
+	return method{role: auth.RoleListener, call: f}
+}
+
+func control(f methodFunc) method {
+	return method{role: auth.RoleController, call: f}
+}
+
+type trackParams struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+type seekParams struct {
+	Time *int `json:"time"`
+}
+
+type volumeParams struct {
+	Volume *int `json:"volume"`
+}
+
+type rateParams struct {
+	Rate     *float64 `json:"rate"`
+	Remember bool     `json:"remember"`
+}
+
+type fadeParams struct {
+	Length *int `json:"length"`
+}
+
+type normalizationParams struct {
+	Mode string `json:"mode"`
+}
+
+type outputParams struct {
+	Output string `json:"output"`
+	Device string `json:"device"`
+}
+
+type indexParams struct {
+	Index *int `json:"index"`
+}
+
+var methods = map[string]method{
+	"status": listen(func(_ auth.Caller, zone string, _ json.RawMessage) (interface{}, error) {
+		return api.Status(zone)
+	}),
+	"play": control(func(caller auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p trackParams
+		if err := parseTrackParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		return nil, api.Play(zone, p.Provider, p.ID, caller.Name)
+	}),
+	"pause":  control(action(api.Pause)),
+	"resume": control(action(api.Resume)),
+	"stop":   control(action(api.Stop)),
+	"next":   control(action(api.Next)),
+	"seek": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p seekParams
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		if p.Time == nil {
+			return nil, missingParam("time")
+		}
+
+		return nil, api.Seek(zone, *p.Time)
+	}),
+	"volume": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p volumeParams
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		if p.Volume == nil {
+			return nil, missingParam("volume")
+		}
+
+		return nil, api.Volume(zone, *p.Volume)
+	}),
+	"rate": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p rateParams
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		if p.Rate == nil {
+			return nil, missingParam("rate")
+		}
+
+		return nil, api.Rate(zone, *p.Rate, p.Remember)
+	}),
+	"fade.set": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p fadeParams
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		if p.Length == nil {
+			return nil, missingParam("length")
+		}
+
+		return nil, api.SetFadeLength(zone, *p.Length)
+	}),
+	"outputs.get": listen(func(_ auth.Caller, zone string, _ json.RawMessage) (interface{}, error) {
+		return api.AudioOutputs(zone)
+	}),
+	"output.set": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p outputParams
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		if p.Output == "" && p.Device == "" {
+			return nil, missingParam("device")
+		}
+
+		return nil, api.SetAudioOutput(zone, p.Output, p.Device)
+	}),
+	"normalization.set": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p normalizationParams
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		if p.Mode == "" {
+			return nil, missingParam("mode")
+		}
+
+		return nil, api.SetNormalization(zone, p.Mode)
+	}),
+	"equalizer.get": listen(func(_ auth.Caller, zone string, _ json.RawMessage) (interface{}, error) {
+		return api.Equalizer(zone)
+	}),
+	"equalizer.set": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p api.EqualizerChange
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		return nil, api.SetEqualizer(zone, p)
+	}),
+	"equalizer.off": control(action(api.DisableEqualizer)),
+	"modes.get": listen(func(_ auth.Caller, zone string, _ json.RawMessage) (interface{}, error) {
+		return api.GetModes(zone), nil
+	}),
+	"queue.get": listen(func(_ auth.Caller, zone string, _ json.RawMessage) (interface{}, error) {
+		return api.Queue(zone)
+	}),
+	"queue.add": control(func(caller auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p trackParams
+		if err := parseTrackParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		return api.Enqueue(zone, p.Provider, p.ID, caller.Name)
+	}),
+	"queue.remove": control(func(_ auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		var p indexParams
+		if err := parseParams(raw, &p); err != nil {
+			return nil, err
+		}
+
+		if p.Index == nil {
+			return nil, missingParam("index")
+		}
+
+		return nil, api.Dequeue(zone, *p.Index)
+	}),
+	"queue.clear": control(action(api.ClearQueue)),
+	// history.get is the room history like /history, the plays of a user are only served over http
+	"history.get": listen(func(auth.Caller, string, json.RawMessage) (interface{}, error) {
+		return history.Get()
+	}),
+}
+
+// Methods returns the names of the supported methods
+func Methods() []string {
+	res := make([]string, 0, len(methods))
+	for name := range methods {
+		res = append(res, name)
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+func action(f func(zone string) error) methodFunc {
+	return func(_ auth.Caller, zone string, _ json.RawMessage) (interface{}, error) {
+		return nil, f(zone)
+	}
+}
+
+func parseParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &api.Error{
+			Code:    api.CodeInvalidArgument,
+			Message: "Invalid params",
+			Details: map[string]interface{}{"reason": err.Error()},
+		}
+	}
+
+	return nil
+}
+
+func parseTrackParams(raw json.RawMessage, p *trackParams) error {
+	if err := parseParams(raw, p); err != nil {
+		return err
+	}
+
+	if p.Provider == "" {
+		return missingParam("provider")
+	}
+
+	if p.ID == "" {
+		return missingParam("id")
+	}
+
+	return nil
+}
+
+func missingParam(name string) *api.Error {
+	return &api.Error{
+		Code:    api.CodeInvalidArgument,
+		Message: "Missing required param " + name,
+		Details: map[string]interface{}{"param": name},
+	}
+}
+
+// Handle executes a single request sent by the caller in a zone and returns the message to send back to it.
+// Requests without an id get no response, unless they fail, in which case an error event is returned
+func Handle(message string, caller auth.Caller, zone string) string {
+	var req Request
+	if err := json.Unmarshal([]byte(message), &req); err != nil {
+		return marshalReply(NewEvent(TypeError, &api.Error{
+			Code:    api.CodeInvalidArgument,
+			Message: "Invalid message",
+			Details: map[string]interface{}{"reason": err.Error()},
+		}))
+	}
+
+	result, err := call(req, caller, zone)
+	if !req.HasID() {
+		if err != nil {
+			return marshalReply(NewEvent(TypeError, api.ToError(err)))
+		}
+
+		return ""
+	}
+
+	res := Response{
+		Type:   TypeResponse,
+		ID:     req.ID,
+		Result: result,
+	}
+
+	if err != nil {
+		res.Error = api.ToError(err)
+		res.Result = nil
+	}
+
+	return marshalReply(res)
+}
+
+func call(req Request, caller auth.Caller, zone string) (interface{}, error) {
+	m, ok := methods[req.Method]
+	if !ok {
+		return nil, &api.Error{
+			Code:    api.CodeNotFound,
+			Message: "Unknown method " + req.Method,
+			Details: map[string]interface{}{"method": req.Method},
+		}
+	}
+
+	if !auth.IsRoleAllowed(caller.Role, m.role) {
+		return nil, auth.Forbidden(caller.Role, m.role)
+	}
+
+	return m.call(caller, zone, req.Params)
+}
+
+func marshalReply(v interface{}) string {
+	s, err := Marshal(v)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+
+	return s
+}
@@ -0,0 +1,144 @@
+package controlProtocol
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"testing"
+)
+
+var (
+	listener   = auth.Caller{Name: "guest", Role: auth.RoleListener}
+	controller = auth.Caller{Name: "dj", Role: auth.RoleController}
+)
+
+// withEcho registers a method returning its params, the zone and the caller, the returned func removes it
+func withEcho() func() {
+	methods["test.echo"] = listen(func(caller auth.Caller, zone string, raw json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"caller": caller.Name, "zone": zone, "params": raw}, nil
+	})
+
+	return func() { delete(methods, "test.echo") }
+}
+
+type reply struct {
+	Type   string          `json:"type"`
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *api.Error      `json:"error"`
+	Data   *api.Error      `json:"data"`
+}
+
+func handle(t *testing.T, message string, caller auth.Caller) reply {
+	s := Handle(message, caller, "kitchen")
+	if s == "" {
+		t.Fatalf("Expected a reply to %s", message)
+	}
+
+	var r reply
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestHandleCopiesTheIDToTheResponse(t *testing.T) {
+	defer withEcho()()
+
+	for _, id := range []string{`1`, `0`, `"a"`, `{"n":[1,2]}`} {
+		r := handle(t, `{"id": `+id+`, "method": "test.echo", "params": {"x":1}}`, listener)
+		if r.Type != TypeResponse || string(r.ID) != id {
+			t.Errorf("Expected a response with the id %s, got %s %s", id, r.Type, r.ID)
+		}
+
+		if r.Error != nil {
+			t.Errorf("Expected no error, got %v", r.Error)
+		}
+
+		var result struct {
+			Caller string          `json:"caller"`
+			Zone   string          `json:"zone"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(r.Result, &result); err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Caller != listener.Name || result.Zone != "kitchen" || string(result.Params) != `{"x":1}` {
+			t.Errorf("Expected the method to get the caller, zone and params, got %+v", result)
+		}
+	}
+}
+
+func TestHandleDoesntRespondToRequestsWithoutAnID(t *testing.T) {
+	defer withEcho()()
+
+	for _, message := range []string{`{"method": "test.echo"}`, `{"id": null, "method": "test.echo"}`} {
+		if s := Handle(message, listener, "kitchen"); s != "" {
+			t.Errorf("Expected no reply to %s, got %s", message, s)
+		}
+	}
+}
+
+func TestHandleReturnsTheErrorsWithTheID(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		message string
+		caller  auth.Caller
+		code    string
+	}{
+		{"unknown method", `{"id": 7, "method": "nope"}`, controller, api.CodeNotFound},
+		{"forbidden", `{"id": 7, "method": "pause"}`, listener, api.CodeForbidden},
+		{"malformed params", `{"id": 7, "method": "seek", "params": {"time": "soon"}}`, controller, api.CodeInvalidArgument},
+		{"params of the wrong type", `{"id": 7, "method": "volume", "params": [1]}`, controller, api.CodeInvalidArgument},
+		{"missing param", `{"id": 7, "method": "seek", "params": {}}`, controller, api.CodeInvalidArgument},
+		{"missing track param", `{"id": 7, "method": "queue.add", "params": {"provider": "youtube"}}`, controller, api.CodeInvalidArgument},
+	} {
+		r := handle(t, c.message, c.caller)
+		if r.Type != TypeResponse || string(r.ID) != "7" {
+			t.Errorf("%s: expected a response with the id 7, got %s %s", c.name, r.Type, r.ID)
+		}
+
+		if r.Error == nil || r.Error.Code != c.code {
+			t.Errorf("%s: expected the error code %s, got %v", c.name, c.code, r.Error)
+		}
+
+		if len(r.Result) > 0 {
+			t.Errorf("%s: expected no result, got %s", c.name, r.Result)
+		}
+	}
+}
+
+func TestHandleChecksTheRoleBeforeTheParams(t *testing.T) {
+	r := handle(t, `{"id": 1, "method": "seek", "params": "bad"}`, listener)
+	if r.Error == nil || r.Error.Code != api.CodeForbidden {
+		t.Fatalf("Expected the forbidden error, got %v", r.Error)
+	}
+
+	if r.Error.Details["role"] != auth.RoleListener || r.Error.Details["required"] != auth.RoleController {
+		t.Errorf("Expected the roles in the details, got %v", r.Error.Details)
+	}
+}
+
+func TestHandleReturnsATypedErrorEvent(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		message string
+		code    string
+	}{
+		{"invalid json", `{"method": `, api.CodeInvalidArgument},
+		{"unknown method without an id", `{"method": "nope"}`, api.CodeNotFound},
+		{"forbidden without an id", `{"method": "stop"}`, api.CodeForbidden},
+	} {
+		r := handle(t, c.message, listener)
+		if r.Type != TypeError {
+			t.Errorf("%s: expected an error event, got %s", c.name, r.Type)
+			continue
+		}
+
+		if r.Data == nil || r.Data.Code != c.code || r.Data.Message == "" {
+			t.Errorf("%s: expected an error with the code %s, got %v", c.name, c.code, r.Data)
+		}
+	}
+}
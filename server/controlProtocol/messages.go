@@ -0,0 +1,62 @@
+package controlProtocol
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/api"
+)
+
+const (
+	TypeResponse  = "response"
+	TypeStatus    = "status"
+	TypeQueue     = "queue"
+	TypeHistory   = "history"
+	TypeError     = "error"
+	TypeModes     = "modes"
+	TypeHeartbeat = "heartbeat"
+)
+
+type Heartbeat struct {
+	Time    int64 `json:"time"`
+	Clients int   `json:"clients"`
+}
+
+// Request is sent by the clients, the id is opaque to the server and is copied to the response
+type Request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// HasID tells whether the request expects a response, a null id is the same as a missing one
+func (r Request) HasID() bool {
+	return len(r.ID) > 0 && string(r.ID) != "null"
+}
+
+type Response struct {
+	Type   string          `json:"type"`
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  *api.Error      `json:"error,omitempty"`
+}
+
+// Event is pushed to every client, Data depends on the Type
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+func NewEvent(t string, data interface{}) Event {
+	return Event{
+		Type: t,
+		Data: data,
+	}
+}
+
+func Marshal(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,27 @@
+package controlProtocol
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestHasID(t *testing.T) {
+	cases := map[string]bool{
+		`{"method": "status"}`:             false,
+		`{"id": null, "method": "status"}`: false,
+		`{"id": 1, "method": "status"}`:    true,
+		`{"id": "a", "method": "status"}`:  true,
+		`{"id": 0, "method": "status"}`:    true,
+	}
+
+	for message, expected := range cases {
+		var req Request
+		if err := json.Unmarshal([]byte(message), &req); err != nil {
+			t.Fatal(err)
+		}
+
+		if req.HasID() != expected {
+			t.Errorf("Expected %s to have an id %t", message, expected)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"sync"
+
+	"github.com/asdine/storm"
+)
+
+var (
+	once sync.Once
+	db   *storm.DB
+)
+
+func Init() error {
+	return Open("db.db")
+}
+
+// Open opens the database at path, only the first call opens a database
+func Open(path string) error {
+	var err error
+	once.Do(func() {
+		d, e := storm.Open(path)
+		if e != nil {
+			err = e
+			return
+		}
+
+		db = d
+	})
+
+	return err
+}
+
+func Get() *storm.DB {
+	return db
+}
+
+func Release() error {
+	return db.Close()
+}
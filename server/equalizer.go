@@ -0,0 +1,116 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/equalizer"
+	"net/http"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+// registerEqualizerRoutes registers the presets and the profiles, which are shared by all zones
+func registerEqualizerRoutes(g *gin.RouterGroup) {
+	listener := auth.Require(auth.RoleListener)
+	controller := auth.Require(auth.RoleController)
+
+	e := g.Group("/player/equalizer")
+	{
+		e.GET("/presets", listener, v2EqualizerPresetsHandler())
+		e.GET("/profiles", listener, v2EqualizerProfilesHandler())
+		e.PUT("/profiles/:name", controller, v2SaveEqualizerProfileHandler())
+		e.DELETE("/profiles/:name", controller, v2RemoveEqualizerProfileHandler())
+	}
+}
+
+func v2GetEqualizerHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		e, err := api.Equalizer(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, e)
+	}
+}
+
+func v2SetEqualizerHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req api.EqualizerChange
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if err := api.SetEqualizer(zoneName(c), req); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2DisableEqualizerHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.DisableEqualizer(zoneName(c)); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2EqualizerPresetsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presets, err := api.GetEqualizerPresets()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, presets)
+	}
+}
+
+func v2EqualizerProfilesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profiles, err := api.EqualizerProfiles()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, profiles)
+	}
+}
+
+func v2SaveEqualizerProfileHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req equalizer.Profile
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		req.Name = c.Param("name")
+		p, err := api.SaveEqualizerProfile(req)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func v2RemoveEqualizerProfileHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.RemoveEqualizerProfile(c.Param("name")); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
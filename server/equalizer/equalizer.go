@@ -0,0 +1,62 @@
+package equalizer
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+var (
+	db *storm.DB
+
+	// ErrNotFound is returned for unknown profiles
+	ErrNotFound = storm.ErrNotFound
+)
+
+// Profile is a named set of custom equalizer gains, shared by all zones
+type Profile struct {
+	Name      string    `json:"name" storm:"id"`
+	Preamp    float64   `json:"preamp"`
+	Bands     []float64 `json:"bands"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func Init() error {
+	db = database.Get()
+	return db.Init(Profile{})
+}
+
+func Profiles() ([]Profile, error) {
+	var res []Profile
+	if err := db.All(&res); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if res == nil {
+		res = make([]Profile, 0)
+	}
+
+	return res, nil
+}
+
+func Get(name string) (Profile, error) {
+	var p Profile
+	err := db.One("Name", name, &p)
+	return p, err
+}
+
+// Save creates the profile or replaces the gains of an existing one
+func Save(p Profile) (Profile, error) {
+	p.UpdatedAt = time.Now()
+	return p, db.Save(&p)
+}
+
+func Remove(name string) error {
+	p, err := Get(name)
+	if err != nil {
+		return err
+	}
+
+	return db.Remove(&p)
+}
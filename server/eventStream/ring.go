@@ -0,0 +1,50 @@
+package eventStream
+
+// ring keeps the last events so reconnecting clients can resume where they left off
+type ring struct {
+	events []Event
+	start  int
+	size   int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{
+		events: make([]Event, capacity),
+	}
+}
+
+func (r *ring) push(e Event) {
+	capacity := len(r.events)
+	if r.size < capacity {
+		r.events[(r.start+r.size)%capacity] = e
+		r.size++
+		return
+	}
+
+	r.events[r.start] = e
+	r.start = (r.start + 1) % capacity
+}
+
+// since returns the events after id, false is returned when id is no longer in the buffer or it's newer
+// than the newest event, e.g. an id of a stream before a restart
+func (r *ring) since(id uint64) ([]Event, bool) {
+	res := make([]Event, 0)
+	if r.size == 0 {
+		return res, id == 0
+	}
+
+	oldest := r.events[r.start].ID
+	newest := r.events[(r.start+r.size-1)%len(r.events)].ID
+	if id+1 < oldest || id > newest {
+		return nil, false
+	}
+
+	for i := 0; i < r.size; i++ {
+		e := r.events[(r.start+i)%len(r.events)]
+		if e.ID > id {
+			res = append(res, e)
+		}
+	}
+
+	return res, true
+}
@@ -0,0 +1,47 @@
+package eventStream
+
+import "testing"
+
+func pushEvents(r *ring, from, to uint64) {
+	for id := from; id <= to; id++ {
+		r.push(Event{ID: id})
+	}
+}
+
+func TestRingReturnsEventsAfterId(t *testing.T) {
+	r := newRing(4)
+	pushEvents(r, 1, 3)
+
+	events, ok := r.since(1)
+	if !ok || len(events) != 2 || events[0].ID != 2 || events[1].ID != 3 {
+		t.Fatalf("Expected events 2 and 3, got %+v %t", events, ok)
+	}
+}
+
+func TestRingOverwritesOldest(t *testing.T) {
+	r := newRing(4)
+	pushEvents(r, 1, 10)
+
+	if _, ok := r.since(2); ok {
+		t.Fatal("Expected event 3 to be overwritten")
+	}
+
+	events, ok := r.since(6)
+	if !ok || len(events) != 4 || events[0].ID != 7 || events[3].ID != 10 {
+		t.Fatalf("Expected events 7 to 10, got %+v %t", events, ok)
+	}
+}
+
+func TestRingRejectsIdsNewerThanNewest(t *testing.T) {
+	r := newRing(4)
+	pushEvents(r, 1, 3)
+
+	if _, ok := r.since(10); ok {
+		t.Fatal("Expected an id from before a restart to be rejected")
+	}
+
+	events, ok := r.since(3)
+	if !ok || len(events) != 0 {
+		t.Fatalf("Expected no events after the newest one, got %+v %t", events, ok)
+	}
+}
@@ -0,0 +1,175 @@
+package eventStream
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/controlProtocol"
+	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/zones"
+	"sync"
+)
+
+// Event is a control protocol event numbered in the order it was published
+type Event struct {
+	ID uint64 `json:"-"`
+	controlProtocol.Event
+}
+
+// Stream is the stream of events of a single zone
+type Stream struct {
+	zone *zones.Zone
+
+	mutex  sync.Mutex
+	lastID uint64
+	events *hub.Hub
+	buffer *ring
+}
+
+var (
+	streamsMutex sync.Mutex
+	streams      = make(map[*zones.Zone]*Stream)
+
+	ringSize = 256
+)
+
+// For returns the stream of a zone, started on first use and stopped once the zone is removed
+func For(zone string) (*Stream, error) {
+	z, err := api.Zone(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	streamsMutex.Lock()
+	defer streamsMutex.Unlock()
+
+	if s, ok := streams[z]; ok {
+		return s, nil
+	}
+
+	s := &Stream{
+		zone:   z,
+		events: hub.New(z.Name + " events"),
+		buffer: newRing(ringSize),
+	}
+	streams[z] = s
+	s.start()
+
+	return s, nil
+}
+
+// Metrics returns the metrics of the streams of all zones
+func Metrics() []hub.Metrics {
+	streamsMutex.Lock()
+	defer streamsMutex.Unlock()
+
+	res := make([]hub.Metrics, 0, len(streams))
+	for _, s := range streams {
+		res = append(res, s.events.Metrics())
+	}
+
+	return res
+}
+
+// start merges the player, queue, history, modes and error notifications of the zone into a single stream of events
+func (s *Stream) start() {
+	statusCh, unsubscribeStatus := s.zone.Player.Subscribe(hub.Options{Name: "events", BufferSize: 1, Policy: hub.Coalesce})
+	queueCh, unsubscribeQueue := s.zone.Queue.Subscribe(hub.Options{Name: "events", BufferSize: 1, Policy: hub.Coalesce})
+	historyCh, unsubscribeHistory := history.Subscribe(hub.Options{Name: s.zone.Name + " events", Policy: hub.DropOldest})
+	errorsCh, unsubscribeErrors := api.SubscribeErrors(hub.Options{Name: s.zone.Name + " events", Policy: hub.DropOldest})
+	modesCh, unsubscribeModes := api.SubscribeModes(hub.Options{Name: s.zone.Name + " events", Policy: hub.DropOldest})
+
+	go func() {
+		defer func() {
+			unsubscribeStatus()
+			unsubscribeQueue()
+			unsubscribeHistory()
+			unsubscribeErrors()
+			unsubscribeModes()
+
+			streamsMutex.Lock()
+			delete(streams, s.zone)
+			streamsMutex.Unlock()
+		}()
+
+		for {
+			select {
+			case status := <-statusCh:
+				s.Publish(controlProtocol.TypeStatus, status)
+			case q := <-queueCh:
+				s.Publish(controlProtocol.TypeQueue, q)
+			case t := <-historyCh:
+				s.Publish(controlProtocol.TypeHistory, t)
+			case msg := <-errorsCh:
+				if e := msg.(api.ZoneError); e.Zone == s.zone.Name {
+					s.Publish(controlProtocol.TypeError, e.Error)
+				}
+			case msg := <-modesCh:
+				if m := msg.(api.ZoneModes); m.Zone == s.zone.Name {
+					s.Publish(controlProtocol.TypeModes, m.Modes)
+				}
+			case <-s.zone.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Done is closed once the zone of the stream is removed, no more events are published then
+func (s *Stream) Done() <-chan struct{} {
+	return s.zone.Done()
+}
+
+// Publish numbers and buffers an event and sends it to the subscribers
+func (s *Stream) Publish(t string, data interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastID++
+	e := Event{
+		ID:    s.lastID,
+		Event: controlProtocol.NewEvent(t, data),
+	}
+
+	s.buffer.push(e)
+	s.events.Publish(e)
+}
+
+// Subscribe returns a channel receiving every published Event
+func (s *Stream) Subscribe(o hub.Options) (<-chan interface{}, func()) {
+	return s.events.Subscribe(o)
+}
+
+// Since returns the buffered events published after id,
+// false is returned when some of them are no longer buffered and the client needs a Snapshot
+func (s *Stream) Since(id uint64) ([]Event, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.buffer.since(id)
+}
+
+func (s *Stream) LastID() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lastID
+}
+
+// Snapshot returns the events describing the current state of the zone: the modes, the queue and the player status
+func (s *Stream) Snapshot() ([]controlProtocol.Event, error) {
+	status, err := s.zone.Player.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []controlProtocol.Event{
+		controlProtocol.NewEvent(controlProtocol.TypeModes, api.GetModes(s.zone.Name)),
+		controlProtocol.NewEvent(controlProtocol.TypeQueue, s.zone.Queue.Get()),
+	}
+
+	if status != nil {
+		res = append(res, controlProtocol.NewEvent(controlProtocol.TypeStatus, status))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/export"
+	"gngeorgiev/audiotic/server/models"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+// attachment returns the Content-Disposition of a download, the names which can't be encoded fall back to the id
+func attachment(name, fallback string) string {
+	if d := mime.FormatMediaType("attachment", map[string]string{"filename": name}); d != "" {
+		return d
+	}
+
+	return mime.FormatMediaType("attachment", map[string]string{"filename": fallback})
+}
+
+// downloadWriter writes the headers of a download with the first output of ffmpeg, so the failures of ffmpeg
+// before its output are still sent as errors
+type downloadWriter struct {
+	c       *gin.Context
+	track   models.Track
+	format  export.Format
+	started bool
+}
+
+func (w *downloadWriter) start() {
+	w.started = true
+	w.c.Header("Content-Type", w.format.ContentType)
+	w.c.Header("Content-Disposition", attachment(export.FileName(w.track, w.format.Extension), w.track.ID+w.format.Extension))
+	w.c.Status(http.StatusOK)
+}
+
+func (w *downloadWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.start()
+	}
+
+	return w.c.Writer.Write(b)
+}
+
+// finish reports the error of ffmpeg, it's only logged once the download started as it can only be cut short then
+func (w *downloadWriter) finish(err error) {
+	if err == nil && !w.started {
+		w.start()
+	}
+
+	if err == nil {
+		return
+	}
+
+	if w.started {
+		log.Println(err)
+		return
+	}
+
+	abortWithApiError(w.c, err)
+}
+
+// v2DownloadTrackHandler sends a track as a file, it's transcoded and tagged when the format query param is set.
+// Without it the track keeps its format and it's only tagged when ffmpeg is available and knows the container,
+// otherwise it's sent as the provider serves it
+func v2DownloadTrackHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var format export.Format
+		if name := c.Query("format"); name != "" {
+			f, err := api.ExportFormat(name)
+			if err != nil {
+				abortWithApiError(c, err)
+				return
+			}
+
+			format = f
+		}
+
+		t, err := api.ExportTrack(c.Param("provider"), c.Param("id"))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		if format.Name != "" {
+			w := &downloadWriter{c: c, track: t, format: format}
+			w.finish(export.Transcode(t, format, w, c.Writer.CloseNotify()))
+			return
+		}
+
+		s, err := export.Open(t)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+		defer s.Close()
+
+		if f, ok := export.Remuxer(s.Extension); ok && export.Available() {
+			w := &downloadWriter{c: c, track: t, format: f}
+			w.finish(export.Remux(t, f, s, w, c.Writer.CloseNotify()))
+			return
+		}
+
+		c.Header("Content-Type", s.ContentType)
+		c.Header("Content-Disposition", attachment(export.FileName(t, s.Extension), t.ID+s.Extension))
+		if s.Size >= 0 {
+			c.Header("Content-Length", strconv.FormatInt(s.Size, 10))
+		}
+		c.Status(http.StatusOK)
+
+		io.Copy(c.Writer, s)
+	}
+}
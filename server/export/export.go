@@ -0,0 +1,335 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gngeorgiev/audiotic/server/cache"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/models"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	FormatMP3  = "mp3"
+	FormatOpus = "opus"
+	FormatFLAC = "flac"
+)
+
+// Formats are the formats the tracks can be transcoded to
+var Formats = []string{FormatMP3, FormatOpus, FormatFLAC}
+
+// Format is an audio format with the ffmpeg args of its codec and muxer
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	// Cover tells whether the thumbnail is embedded as the cover art, the ogg muxer of ffmpeg can't do it
+	Cover bool
+	args  []string
+}
+
+var (
+	formats = map[string]Format{
+		FormatMP3: {
+			Name: FormatMP3, Extension: ".mp3", ContentType: "audio/mpeg", Cover: true,
+			args: []string{"-c:a", "libmp3lame", "-q:a", "2", "-id3v2_version", "3", "-f", "mp3"},
+		},
+		FormatOpus: {
+			Name: FormatOpus, Extension: ".opus", ContentType: "audio/ogg",
+			args: []string{"-c:a", "libopus", "-b:a", "160k", "-f", "ogg"},
+		},
+		FormatFLAC: {
+			Name: FormatFLAC, Extension: ".flac", ContentType: "audio/flac", Cover: true,
+			args: []string{"-c:a", "flac", "-f", "flac"},
+		},
+	}
+
+	// remuxers tag the streams sent in their own format, by the extensions of the streams. The audio is copied
+	// as it is, the fragmented mp4 written to a pipe and the webm and ogg muxers of ffmpeg can't embed the cover art
+	remuxers = map[string]Format{
+		".mp3": {
+			Name: "mp3", Extension: ".mp3", ContentType: "audio/mpeg", Cover: true,
+			args: []string{"-c:a", "copy", "-id3v2_version", "3", "-f", "mp3"},
+		},
+		".m4a": {
+			Name: "m4a", Extension: ".m4a", ContentType: "audio/mp4",
+			args: []string{"-c:a", "copy", "-movflags", "frag_keyframe+empty_moov", "-f", "ipod"},
+		},
+		".mp4": {
+			Name: "m4a", Extension: ".m4a", ContentType: "audio/mp4",
+			args: []string{"-c:a", "copy", "-movflags", "frag_keyframe+empty_moov", "-f", "ipod"},
+		},
+		".webm": {
+			Name: "webm", Extension: ".webm", ContentType: "audio/webm",
+			args: []string{"-c:a", "copy", "-f", "webm"},
+		},
+		".ogg": {
+			Name: "ogg", Extension: ".ogg", ContentType: "audio/ogg",
+			args: []string{"-c:a", "copy", "-f", "ogg"},
+		},
+		".flac": {
+			Name: "flac", Extension: ".flac", ContentType: "audio/flac", Cover: true,
+			args: []string{"-c:a", "copy", "-f", "flac"},
+		},
+	}
+
+	ffmpeg = "ffmpeg"
+	client = &http.Client{Timeout: 10 * time.Second}
+)
+
+func Init(c config.ExportConfig) {
+	if c.FFmpeg != "" {
+		ffmpeg = c.FFmpeg
+	}
+}
+
+func Get(name string) (Format, bool) {
+	f, ok := formats[strings.ToLower(name)]
+	return f, ok
+}
+
+// Remuxer returns the format which tags a stream of the extension without transcoding it,
+// the streams of the other extensions are only sent as they are
+func Remuxer(ext string) (Format, bool) {
+	f, ok := remuxers[strings.ToLower(ext)]
+	return f, ok
+}
+
+// Available tells whether ffmpeg is found, the tracks are only sent as they are without it
+func Available() bool {
+	_, err := exec.LookPath(ffmpeg)
+	return err == nil
+}
+
+// FileName returns the name of the file of a track, "Artist - Title" when the artist is known
+func FileName(t models.Track, ext string) string {
+	name := t.Title
+	if t.Artist != "" && t.CleanTitle != "" {
+		name = t.Artist + " - " + t.CleanTitle
+	}
+
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name))
+	if name == "" {
+		name = t.ID
+	}
+
+	return name + ext
+}
+
+// path returns the path of the file of a file:// stream url, false for the other urls
+func path(streamUrl string) (string, bool) {
+	u, err := url.Parse(streamUrl)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+
+	return filepath.FromSlash(u.Path), true
+}
+
+// Stream is the stream of a track as the provider serves it
+type Stream struct {
+	io.ReadCloser
+	ContentType string
+	Extension   string
+	// Size is -1 when it's unknown
+	Size int64
+
+	// path is the path of the cached file, empty for the streams of the providers
+	path string
+}
+
+// Open opens the stream of a track without transcoding it, the track needs its stream url
+func Open(t models.Track) (*Stream, error) {
+	if p, ok := path(t.StreamUrl); ok {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		ext := filepath.Ext(p)
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		return &Stream{ReadCloser: f, ContentType: contentType, Extension: ext, Size: info.Size(), path: p}, nil
+	}
+
+	res, err := http.Get(t.StreamUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("The stream responded with %s", res.Status)
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &Stream{
+		ReadCloser:  res.Body,
+		ContentType: contentType,
+		Extension:   cache.Extension(contentType),
+		Size:        res.ContentLength,
+	}, nil
+}
+
+// tags returns the ffmpeg args of the metadata of a track, they're written as ID3 frames or Vorbis comments
+// depending on the format. The metadata of the source is dropped
+func tags(t models.Track) []string {
+	title := t.CleanTitle
+	if title == "" {
+		title = t.Title
+	}
+
+	args := []string{"-map_metadata", "-1", "-metadata", "title=" + title}
+	if t.Artist != "" {
+		args = append(args, "-metadata", "artist="+t.Artist)
+	}
+
+	if t.Album != "" {
+		args = append(args, "-metadata", "album="+t.Album)
+	}
+
+	return args
+}
+
+// args returns the ffmpeg args writing input in f, cover is the path of the cover art or empty
+func args(t models.Track, f Format, input, cover string) []string {
+	res := []string{"-hide_banner", "-loglevel", "error", "-nostdin", "-i", input}
+	if cover != "" {
+		res = append(res, "-i", cover)
+	}
+
+	res = append(res, "-map", "0:a:0")
+	if cover != "" {
+		res = append(res, "-map", "1:v:0", "-c:v", "mjpeg", "-disposition:v", "attached_pic",
+			"-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)")
+	}
+
+	res = append(res, tags(t)...)
+	res = append(res, f.args...)
+	return append(res, "pipe:1")
+}
+
+// fetchCover downloads the thumbnail of a track to a temp file
+func fetchCover(thumbnail string) (string, error) {
+	res, err := client.Get(thumbnail)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("The thumbnail responded with %s", res.Status)
+	}
+
+	f, err := ioutil.TempFile("", "audiotic-cover")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(f, res.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// Transcode writes a track in a format to w, the track needs its stream url. The tracks are exported without
+// the cover art when their thumbnail can't be downloaded. ffmpeg is killed once stop receives, e.g. when the client
+// of the download disconnects
+func Transcode(t models.Track, f Format, w io.Writer, stop <-chan bool) error {
+	if t.StreamUrl == "" {
+		return errors.New("The track has no stream url")
+	}
+
+	input := t.StreamUrl
+	if p, ok := path(input); ok {
+		input = p
+	}
+
+	return run(t, f, input, nil, w, stop)
+}
+
+// Remux writes a stream tagged with the metadata of its track to w, f is the remuxer of the stream.
+// The cached files are read by ffmpeg, the streams of the providers are piped to it
+func Remux(t models.Track, f Format, s *Stream, w io.Writer, stop <-chan bool) error {
+	if s.path != "" {
+		return run(t, f, s.path, nil, w, stop)
+	}
+
+	return run(t, f, "pipe:0", s, w, stop)
+}
+
+func run(t models.Track, f Format, input string, stdin io.Reader, w io.Writer, stop <-chan bool) error {
+	var cover string
+	if f.Cover && t.Thumbnail != "" {
+		p, err := fetchCover(t.Thumbnail)
+		if err != nil {
+			log.Println(err)
+		} else {
+			cover = p
+			defer os.Remove(p)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(ffmpeg, args(t, f, input, cover)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, w, &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %s", msg)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package export
+
+import (
+	"bytes"
+	"gngeorgiev/audiotic/server/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	cases := []struct {
+		track models.Track
+		name  string
+	}{
+		{models.Track{ID: "abc", Title: "Artist - Song (Official Video)", Artist: "Artist", CleanTitle: "Song"}, "Artist - Song.mp3"},
+		{models.Track{ID: "abc", Title: "AC/DC: Back in Black?"}, "AC_DC_ Back in Black_.mp3"},
+		{models.Track{ID: "abc", Title: "  "}, "abc.mp3"},
+	}
+
+	for _, c := range cases {
+		if name := FileName(c.track, ".mp3"); name != c.name {
+			t.Errorf("Expected %q, got %q", c.name, name)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	track := models.Track{Title: "Artist - Song", Artist: "Artist", CleanTitle: "Song", Album: "Album"}
+	f, _ := Get("MP3")
+
+	got := strings.Join(args(track, f, "in.webm", "cover.jpg"), " ")
+	for _, want := range []string{
+		"-i in.webm -i cover.jpg -map 0:a:0 -map 1:v:0",
+		"-disposition:v attached_pic",
+		"-map_metadata -1 -metadata title=Song -metadata artist=Artist -metadata album=Album",
+		"-c:a libmp3lame",
+		"-f mp3 pipe:1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q is missing %q", got, want)
+		}
+	}
+
+	f, _ = Get("opus")
+	got = strings.Join(args(models.Track{Title: "Song"}, f, "in.webm", ""), " ")
+	if strings.Contains(got, "1:v:0") || strings.Contains(got, "artist=") || !strings.Contains(got, "title=Song") {
+		t.Errorf("Unexpected args %q", got)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "youtube_abc.mp3")
+	if err := ioutil.WriteFile(p, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	u := (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+	s, err := Open(models.Track{StreamUrl: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	body, _ := ioutil.ReadAll(s)
+	if string(body) != "audio" || s.Size != 5 || s.Extension != ".mp3" {
+		t.Errorf("Unexpected stream %+v %q", s, body)
+	}
+}
+
+func TestOpenURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/webm; codecs=opus")
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	s, err := Open(models.Track{StreamUrl: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if s.Extension != ".webm" || s.ContentType != "audio/webm; codecs=opus" {
+		t.Errorf("Unexpected stream %+v", s)
+	}
+}
+
+// fakeFFmpeg replaces ffmpeg with a script writing its args and then its input instead of the audio
+func fakeFFmpeg(t *testing.T, dir string) func() {
+	fake := filepath.Join(dir, "ffmpeg")
+	if err := ioutil.WriteFile(fake, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\"\ncat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := ffmpeg
+	ffmpeg = fake
+	return func() { ffmpeg = previous }
+}
+
+func TestTranscode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer fakeFFmpeg(t, dir)()
+
+	f, _ := Get(FormatFLAC)
+	track := models.Track{Title: "Song", StreamUrl: "http://example.com/stream"}
+	var out bytes.Buffer
+	if err := Transcode(track, f, &out, make(chan bool)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if want := args(track, f, track.StreamUrl, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRemux(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer fakeFFmpeg(t, dir)()
+
+	if _, ok := Remuxer(".audio"); ok {
+		t.Error("Unknown containers can't be remuxed")
+	}
+
+	f, ok := Remuxer(".WEBM")
+	if !ok {
+		t.Fatal("Expected the webm remuxer")
+	}
+
+	// the streams of the providers are piped to ffmpeg
+	track := models.Track{Title: "Song", Thumbnail: "http://example.com/cover.jpg"}
+	var out bytes.Buffer
+	s := &Stream{ReadCloser: ioutil.NopCloser(strings.NewReader("audio")), Extension: ".webm"}
+	if err := Remux(track, f, s, &out, make(chan bool)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join(args(track, f, "pipe:0", ""), "\n") + "\naudio"
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if !strings.Contains(want, "-c:a\ncopy") || !strings.Contains(want, "title=Song") {
+		t.Errorf("Expected the audio to be copied with the tags, got %q", want)
+	}
+
+	// the cached files are read by ffmpeg
+	out.Reset()
+	s = &Stream{ReadCloser: ioutil.NopCloser(strings.NewReader("")), Extension: ".webm", path: "/cache/youtube_abc.webm"}
+	if err := Remux(models.Track{Title: "Song"}, f, s, &out, make(chan bool)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "-i\n/cache/youtube_abc.webm\n") {
+		t.Errorf("Expected the cached file to be the input, got %q", out.String())
+	}
+}
@@ -1,40 +1,60 @@
 package history
 
 import (
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/hub"
 	"gngeorgiev/audiotic/server/models"
-	"sync"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/asdine/storm"
 )
 
 var (
-	once sync.Once
-	db   *storm.DB
+	db *storm.DB
+
+	ErrNotFound = storm.ErrNotFound
+
+	added = hub.New("history")
 )
 
-func Init() error {
-	var err error
-	once.Do(func() {
-		d, e := storm.Open("db.db")
-		if e != nil {
-			err = e
-			return
-		}
+// Subscribe returns a channel receiving every models.Track added to the history
+func Subscribe(o hub.Options) (<-chan interface{}, func()) {
+	return added.Subscribe(o)
+}
 
-		db = d
-	})
+func Metrics() hub.Metrics {
+	return added.Metrics()
+}
 
-	if db != nil {
-		if err := db.Init(models.Track{}); err != nil {
-			return err
-		}
-	}
+// Play counts how many times a user played a track, the plain Track bucket is the history of the whole room
+type Play struct {
+	// ID isn't encoded, it's lost when a play is loaded and has to be set again before saving it
+	ID         string       `json:"-" storm:"id"`
+	User       string       `json:"user" storm:"index"`
+	Track      models.Track `json:"track"`
+	PlayCount  int          `json:"playCount"`
+	LastPlayed time.Time    `json:"lastPlayed" storm:"index"`
+}
 
-	return err
+type playsByLastPlayed []Play
+
+func (p playsByLastPlayed) Len() int           { return len(p) }
+func (p playsByLastPlayed) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p playsByLastPlayed) Less(i, j int) bool { return p[i].LastPlayed.After(p[j].LastPlayed) }
+
+func playID(user string, t models.Track) string {
+	return strings.Join([]string{user, strings.ToLower(t.Provider), t.ID}, "/")
 }
 
-func Release() error {
-	return db.Close()
+func Init() error {
+	db = database.Get()
+	if err := db.Init(models.Track{}); err != nil {
+		return err
+	}
+
+	return db.Init(Play{})
 }
 
 func Add(t *models.Track) error {
@@ -45,9 +65,15 @@ func Add(t *models.Track) error {
 		}
 	}
 
-	return db.Save(t)
+	if err := db.Save(t); err != nil {
+		return err
+	}
+
+	added.Publish(*t)
+	return nil
 }
 
+// Get returns the room history, every track played by anyone, most recent first
 func Get() ([]models.Track, error) {
 	var res []models.Track
 	if err := db.AllByIndex("LastPlayed", &res, storm.Reverse()); err != nil {
@@ -56,3 +82,86 @@ func Get() ([]models.Track, error) {
 
 	return res, nil
 }
+
+func Remove(id string) error {
+	var t models.Track
+	if err := db.One("ID", id, &t); err != nil {
+		return err
+	}
+
+	return db.Remove(&t)
+}
+
+// Update replaces a track in the history, e.g. with its looked up metadata, and in the play of the user who
+// queued it. Unlike Add it isn't published as another play
+func Update(user string, t models.Track) error {
+	t.StreamUrl = ""
+	if err := db.Save(&t); err != nil {
+		return err
+	}
+
+	if t.Autoplayed {
+		return nil
+	}
+
+	var p Play
+	if err := db.One("ID", playID(user, t), &p); err != nil {
+		if err == storm.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	p.ID = playID(user, t)
+	p.Track = t
+	return db.Save(&p)
+}
+
+// AddPlay records that the user played the track
+func AddPlay(user string, t models.Track) error {
+	t.StreamUrl = ""
+
+	var p Play
+	if err := db.One("ID", playID(user, t), &p); err != nil {
+		if err != storm.ErrNotFound {
+			return err
+		}
+
+		p = Play{User: user}
+	}
+
+	p.ID = playID(user, t)
+	p.Track = t
+	p.PlayCount++
+	p.LastPlayed = t.LastPlayed
+	return db.Save(&p)
+}
+
+// UserPlays returns the tracks played by the user, most recent first
+func UserPlays(user string) ([]Play, error) {
+	var res []Play
+	if err := db.Find("User", user, &res); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return sortPlays(res), nil
+}
+
+// Plays returns the tracks played by everyone, most recent first
+func Plays() ([]Play, error) {
+	var res []Play
+	if err := db.All(&res); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return sortPlays(res), nil
+}
+
+func sortPlays(p []Play) []Play {
+	if p == nil {
+		return make([]Play, 0)
+	}
+
+	sort.Sort(playsByLastPlayed(p))
+	return p
+}
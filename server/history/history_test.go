@@ -0,0 +1,177 @@
+package history
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/models"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Open(filepath.Join(dir, "db.db")); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	database.Release()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var epoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func track(id string, minute int) models.Track {
+	return models.Track{
+		ID:         id,
+		Provider:   "YouTube",
+		Title:      "Track " + id,
+		StreamUrl:  "http://stream/" + id,
+		LastPlayed: epoch.Add(time.Duration(minute) * time.Minute),
+	}
+}
+
+func TestAddPlayCountsThePlaysOfEveryUser(t *testing.T) {
+	user, other := t.Name()+"-user", t.Name()+"-other"
+	for _, p := range []struct {
+		user  string
+		track models.Track
+	}{
+		{user, track("play-a", 1)},
+		{user, track("play-b", 2)},
+		{user, track("play-a", 3)},
+		{other, track("play-a", 4)},
+	} {
+		if err := AddPlay(p.user, p.track); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plays, err := UserPlays(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plays) != 2 {
+		t.Fatalf("Expected the 2 tracks of the user, got %v", plays)
+	}
+
+	if plays[0].Track.ID != "play-a" || plays[0].PlayCount != 2 || !plays[0].LastPlayed.Equal(epoch.Add(3*time.Minute)) {
+		t.Errorf("Expected play-a played twice to be the most recent, got %+v", plays[0])
+	}
+
+	if plays[1].Track.ID != "play-b" || plays[1].PlayCount != 1 {
+		t.Errorf("Expected play-b played once, got %+v", plays[1])
+	}
+
+	for _, p := range plays {
+		if p.User != user || p.Track.StreamUrl != "" {
+			t.Errorf("Expected the plays of the user without the stream url, got %+v", p)
+		}
+	}
+
+	plays, err = UserPlays(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plays) != 1 || plays[0].PlayCount != 1 {
+		t.Errorf("Expected the other user to have played play-a once, got %v", plays)
+	}
+}
+
+func TestUserPlaysOfAnUnknownUserIsEmpty(t *testing.T) {
+	plays, err := UserPlays(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plays == nil || len(plays) != 0 {
+		t.Errorf("Expected no plays, got %v", plays)
+	}
+}
+
+func TestUpdateReplacesTheTrackInThePlayOfTheUser(t *testing.T) {
+	user := t.Name()
+	tr := track("update-a", 1)
+	if err := AddPlay(user, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.Artist = "Artist"
+	if err := Update(user, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	plays, err := UserPlays(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plays) != 1 || plays[0].Track.Artist != "Artist" || plays[0].PlayCount != 1 {
+		t.Errorf("Expected the updated track without another play, got %v", plays)
+	}
+
+	var stored models.Track
+	if err := db.One("ID", tr.ID, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Artist != "Artist" || stored.StreamUrl != "" {
+		t.Errorf("Expected the room history to have the updated track, got %+v", stored)
+	}
+}
+
+func TestAddPublishesTheTrackToTheRoomHistory(t *testing.T) {
+	events, unsubscribe := Subscribe(hub.Options{Name: "test", BufferSize: 1, Policy: hub.DropOldest})
+	defer unsubscribe()
+
+	tr := track("add-a", 100)
+	if err := Add(&tr); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-events:
+		if e.(models.Track).ID != tr.ID {
+			t.Errorf("Expected %s to be published, got %v", tr.ID, e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the track to be published")
+	}
+
+	later := track("add-b", 101)
+	if err := Add(&later); err != nil {
+		t.Fatal(err)
+	}
+
+	tracks, err := Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracks) < 2 || tracks[0].ID != later.ID || tracks[1].ID != tr.ID {
+		t.Fatalf("Expected the most recent tracks first, got %v", tracks)
+	}
+
+	if err := Remove(later.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Remove(later.ID); err != ErrNotFound {
+		t.Errorf("Expected the removed track not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+type Policy int
+
+const (
+	// DropOldest discards the oldest pending message of a full subscriber to make room for the new one
+	DropOldest Policy = iota
+	// Coalesce replaces all pending messages of a full subscriber with the new one,
+	// useful for state updates where only the latest matters
+	Coalesce
+)
+
+const defaultBufferSize = 16
+
+func (p Policy) String() string {
+	switch p {
+	case DropOldest:
+		return "dropOldest"
+	case Coalesce:
+		return "coalesce"
+	default:
+		return "unknown"
+	}
+}
+
+type Options struct {
+	Name       string
+	BufferSize int
+	Policy     Policy
+}
+
+type SubscriberMetrics struct {
+	Name        string    `json:"name"`
+	Policy      string    `json:"policy"`
+	BufferSize  int       `json:"bufferSize"`
+	Pending     int       `json:"pending"`
+	Delivered   uint64    `json:"delivered"`
+	Dropped     uint64    `json:"dropped"`
+	Coalesced   uint64    `json:"coalesced"`
+	LastDropped time.Time `json:"lastDropped"`
+	Slow        bool      `json:"slow"`
+}
+
+type Metrics struct {
+	Name        string              `json:"name"`
+	Published   uint64              `json:"published"`
+	Subscribers []SubscriberMetrics `json:"subscribers"`
+}
+
+type subscriber struct {
+	options Options
+	ch      chan interface{}
+
+	delivered, dropped, coalesced uint64
+	lastDropped                   time.Time
+}
+
+// Hub fans out published messages to its subscribers without ever blocking the publisher,
+// every subscriber has its own bounded buffer and a policy deciding what happens when it fills up
+type Hub struct {
+	mu          sync.Mutex
+	name        string
+	published   uint64
+	subscribers map[*subscriber]struct{}
+}
+
+func New(name string) *Hub {
+	return &Hub{
+		name:        name,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe returns the channel receiving the published messages and a func removing the subscription.
+// The channel is closed once unsubscribed, calling the func more than once is safe
+func (h *Hub) Subscribe(o Options) (<-chan interface{}, func()) {
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaultBufferSize
+	}
+
+	s := &subscriber{
+		options: o,
+		ch:      make(chan interface{}, o.BufferSize),
+	}
+
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+
+	once := sync.Once{}
+	return s.ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subscribers, s)
+			close(s.ch)
+		})
+	}
+}
+
+func (h *Hub) Publish(msg interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.published++
+	for s := range h.subscribers {
+		s.deliver(msg)
+	}
+}
+
+func (h *Hub) Metrics() Metrics {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	m := Metrics{
+		Name:        h.name,
+		Published:   h.published,
+		Subscribers: make([]SubscriberMetrics, 0, len(h.subscribers)),
+	}
+
+	for s := range h.subscribers {
+		m.Subscribers = append(m.Subscribers, SubscriberMetrics{
+			Name:        s.options.Name,
+			Policy:      s.options.Policy.String(),
+			BufferSize:  s.options.BufferSize,
+			Pending:     len(s.ch),
+			Delivered:   s.delivered,
+			Dropped:     s.dropped,
+			Coalesced:   s.coalesced,
+			LastDropped: s.lastDropped,
+			Slow:        s.dropped > 0 || s.coalesced > 0,
+		})
+	}
+
+	return m
+}
+
+func (s *subscriber) deliver(msg interface{}) {
+	for {
+		select {
+		case s.ch <- msg:
+			s.delivered++
+			return
+		default:
+		}
+
+		// the buffer is full, the subscriber can read concurrently so every step is non-blocking
+		switch s.options.Policy {
+		case Coalesce:
+			for drained := false; !drained; {
+				select {
+				case <-s.ch:
+					s.coalesced++
+				default:
+					drained = true
+				}
+			}
+		default:
+			select {
+			case <-s.ch:
+				s.dropped++
+			default:
+			}
+		}
+
+		s.lastDropped = time.Now()
+	}
+}
@@ -0,0 +1,78 @@
+package hub
+
+import "testing"
+
+func TestSubscriberReceivesPublishedMessages(t *testing.T) {
+	h := New("test")
+	ch, unsubscribe := h.Subscribe(Options{Name: "sub", BufferSize: 2})
+	defer unsubscribe()
+
+	h.Publish(1)
+	h.Publish(2)
+
+	if v := <-ch; v != 1 {
+		t.Fatalf("Expected 1, got %v", v)
+	}
+
+	if v := <-ch; v != 2 {
+		t.Fatalf("Expected 2, got %v", v)
+	}
+}
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	h := New("test")
+	ch, unsubscribe := h.Subscribe(Options{Name: "slow", BufferSize: 2, Policy: DropOldest})
+	defer unsubscribe()
+
+	for i := 0; i < 5; i++ {
+		h.Publish(i)
+	}
+
+	if v := <-ch; v != 3 {
+		t.Fatalf("Expected the oldest messages to be dropped, got %v", v)
+	}
+
+	if v := <-ch; v != 4 {
+		t.Fatalf("Expected 4, got %v", v)
+	}
+
+	m := h.Metrics().Subscribers[0]
+	if m.Dropped != 3 || !m.Slow {
+		t.Fatalf("Expected 3 dropped messages and a slow subscriber, got %+v", m)
+	}
+}
+
+func TestCoalesceKeepsLatest(t *testing.T) {
+	h := New("test")
+	ch, unsubscribe := h.Subscribe(Options{Name: "latest", BufferSize: 1, Policy: Coalesce})
+	defer unsubscribe()
+
+	for i := 0; i < 5; i++ {
+		h.Publish(i)
+	}
+
+	if v := <-ch; v != 4 {
+		t.Fatalf("Expected only the latest message, got %v", v)
+	}
+
+	if m := h.Metrics().Subscribers[0]; m.Coalesced != 4 {
+		t.Fatalf("Expected 4 coalesced messages, got %+v", m)
+	}
+}
+
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	h := New("test")
+	ch, unsubscribe := h.Subscribe(Options{Name: "sub"})
+
+	unsubscribe()
+	unsubscribe()
+	h.Publish(1)
+
+	if _, ok := <-ch; ok {
+		t.Fatal("Expected the channel to be closed")
+	}
+
+	if len(h.Metrics().Subscribers) != 0 {
+		t.Fatal("Expected no subscribers")
+	}
+}
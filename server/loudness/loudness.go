@@ -0,0 +1,29 @@
+package loudness
+
+import (
+	"gngeorgiev/audiotic/server/trackStore"
+	"math"
+)
+
+const (
+	// maxBoost limits the gain of quiet tracks, boosting them further mostly boosts their noise
+	maxBoost = 6.0
+	maxCut   = 12.0
+)
+
+// measurements are the loudness of the tracks in dB relative to the reference loudness, positive means louder
+var measurements = trackStore.New("loudness")
+
+// Get returns the cached loudness of a track, false is returned when it was never measured
+func Get(provider, id string) (float64, bool, error) {
+	return measurements.Get(provider, id)
+}
+
+func Save(provider, id string, loudness float64) error {
+	return measurements.Save(provider, id, loudness)
+}
+
+// Gain returns the gain in dB bringing a track of the given loudness to the reference loudness
+func Gain(loudness float64) float64 {
+	return math.Max(-maxCut, math.Min(maxBoost, -loudness))
+}
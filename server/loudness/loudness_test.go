@@ -0,0 +1,20 @@
+package loudness
+
+import "testing"
+
+func TestGainIsClamped(t *testing.T) {
+	for loudness, expected := range map[float64]float64{
+		0:     0,
+		4.5:   -4.5,
+		-3:    3,
+		-6:    maxBoost,
+		-20:   maxBoost,
+		12:    -maxCut,
+		30:    -maxCut,
+		-0.25: 0.25,
+	} {
+		if g := Gain(loudness); g != expected {
+			t.Errorf("Expected the gain %v for %v dB, got %v", expected, loudness, g)
+		}
+	}
+}
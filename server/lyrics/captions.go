@@ -0,0 +1,117 @@
+package lyrics
+
+import (
+	"encoding/xml"
+	"fmt"
+	"gngeorgiev/audiotic/server/models"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const captionsURL = "https://www.youtube.com/api/timedtext"
+
+// captions reads the captions of the YouTube videos, the ones of the music videos are usually the lyrics
+type captions struct {
+	url       string
+	languages []string
+	client    *http.Client
+}
+
+type transcript struct {
+	Texts []struct {
+		Start float64 `xml:"start,attr"`
+		Text  string  `xml:",chardata"`
+	} `xml:"text"`
+}
+
+// soundTag matches the captions describing the sound, like [Music]
+var soundTag = regexp.MustCompile(`^[\[(][^\])]*[\])]$`)
+
+func newCaptions(languages []string) *captions {
+	if len(languages) == 0 {
+		languages = []string{"en"}
+	}
+
+	return &captions{
+		url:       captionsURL,
+		languages: languages,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *captions) Name() string {
+	return SourceYouTube
+}
+
+func (c *captions) Find(t models.Track) (*Lyrics, error) {
+	if strings.ToLower(t.Provider) != SourceYouTube {
+		return nil, nil
+	}
+
+	for _, lang := range c.languages {
+		lyrics, err := c.find(t.ID, lang)
+		if err != nil || lyrics != nil {
+			return lyrics, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (c *captions) find(id, lang string) (*Lyrics, error) {
+	q := url.Values{"v": {id}, "lang": {lang}}
+	res, err := c.client.Get(c.url + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("YouTube captions responded with %s", res.Status)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// the videos without captions in the language get an empty response
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return nil, nil
+	}
+
+	var tr transcript
+	if err := xml.Unmarshal(b, &tr); err != nil {
+		return nil, err
+	}
+
+	lyrics := &Lyrics{Synced: true}
+	var texts []string
+	for _, t := range tr.Texts {
+		// the captions are html escaped inside the xml
+		text := strings.Replace(html.UnescapeString(t.Text), "\n", " ", -1)
+		text = strings.TrimSpace(strings.Replace(text, "♪", "", -1))
+		if text == "" || soundTag.MatchString(text) {
+			continue
+		}
+
+		lyrics.Lines = append(lyrics.Lines, Line{Time: int(t.Start * 1000), Text: text})
+		texts = append(texts, text)
+	}
+
+	if len(lyrics.Lines) == 0 {
+		return nil, nil
+	}
+
+	lyrics.Text = strings.Join(texts, "\n")
+	return lyrics, nil
+}
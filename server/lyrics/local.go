@@ -0,0 +1,84 @@
+package lyrics
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// local reads the .lrc files next to the local tracks and in the lyrics dir
+type local struct {
+	dir string
+}
+
+var invalidFileChars = strings.NewReplacer("/", "_", `\`, "_", ":", "_", "*", "_", "?", "_", `"`, "_", "<", "_", ">", "_", "|", "_")
+
+func newLocal(dir string) *local {
+	return &local{dir: dir}
+}
+
+func (l *local) Name() string {
+	return SourceLocal
+}
+
+// localPath returns the path of a track played from a local file, it's empty for the other tracks
+func localPath(source string) string {
+	if strings.HasPrefix(source, "file://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return ""
+		}
+
+		return filepath.FromSlash(u.Path)
+	}
+
+	if filepath.IsAbs(source) {
+		return source
+	}
+
+	return ""
+}
+
+// candidates returns the files which may have the lyrics of a track, the best ones first
+func (l *local) candidates(t models.Track) []string {
+	var res []string
+	if p := localPath(t.StreamUrl); p != "" {
+		res = append(res, strings.TrimSuffix(p, filepath.Ext(p))+".lrc")
+	}
+
+	if l.dir == "" {
+		return res
+	}
+
+	if t.Artist != "" && t.CleanTitle != "" {
+		res = append(res, filepath.Join(l.dir, invalidFileChars.Replace(t.Artist+" - "+t.CleanTitle)+".lrc"))
+	}
+
+	if t.Title != "" {
+		res = append(res, filepath.Join(l.dir, invalidFileChars.Replace(t.Title)+".lrc"))
+	}
+
+	return res
+}
+
+func (l *local) Find(t models.Track) (*Lyrics, error) {
+	for _, path := range l.candidates(t) {
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if res := ParseLRC(string(b)); res.Text != "" {
+			return &res, nil
+		}
+	}
+
+	return nil, nil
+}
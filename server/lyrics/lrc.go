@@ -0,0 +1,97 @@
+package lyrics
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	timeTag = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	idTag   = regexp.MustCompile(`^\[([a-zA-Z]+):(.*)\]$`)
+	// wordTag is a time tag of the enhanced LRC format, the words aren't synced separately
+	wordTag = regexp.MustCompile(`<\d+:\d{1,2}(?:[.:]\d{1,3})?>`)
+)
+
+type byTime []Line
+
+func (l byTime) Len() int           { return len(l) }
+func (l byTime) Less(i, j int) bool { return l[i].Time < l[j].Time }
+func (l byTime) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// tagTime returns the time of a time tag in milliseconds
+func tagTime(m []string) int {
+	minutes, _ := strconv.Atoi(m[1])
+	seconds, _ := strconv.Atoi(m[2])
+	res := (minutes*60 + seconds) * 1000
+
+	if m[3] != "" {
+		fraction, _ := strconv.Atoi(m[3])
+		for i := len(m[3]); i < 3; i++ {
+			fraction *= 10
+		}
+		res += fraction
+	}
+
+	return res
+}
+
+// ParseLRC parses LRC lyrics, the lyrics without time tags are returned as plain lyrics
+func ParseLRC(s string) Lyrics {
+	var res Lyrics
+	var plain []string
+	offset := 0
+
+	for _, raw := range strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n") {
+		line := strings.TrimSpace(raw)
+
+		var times []int
+		for {
+			m := timeTag.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+
+			times = append(times, tagTime(m))
+			line = strings.TrimSpace(line[len(m[0]):])
+		}
+
+		if len(times) == 0 {
+			if m := idTag.FindStringSubmatch(line); m != nil {
+				// a positive offset shows the lines sooner
+				if strings.ToLower(m[1]) == "offset" {
+					offset, _ = strconv.Atoi(strings.TrimSpace(m[2]))
+				}
+				continue
+			}
+
+			plain = append(plain, line)
+			continue
+		}
+
+		text := strings.TrimSpace(wordTag.ReplaceAllString(line, ""))
+		for _, t := range times {
+			res.Lines = append(res.Lines, Line{Time: t, Text: text})
+		}
+	}
+
+	if len(res.Lines) == 0 {
+		res.Text = strings.TrimSpace(strings.Join(plain, "\n"))
+		return res
+	}
+
+	res.Synced = true
+	sort.Stable(byTime(res.Lines))
+
+	texts := make([]string, len(res.Lines))
+	for i := range res.Lines {
+		if res.Lines[i].Time -= offset; res.Lines[i].Time < 0 {
+			res.Lines[i].Time = 0
+		}
+		texts[i] = res.Lines[i].Text
+	}
+	res.Text = strings.TrimSpace(strings.Join(texts, "\n"))
+
+	return res
+}
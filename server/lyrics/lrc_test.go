@@ -0,0 +1,40 @@
+package lyrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLRC(t *testing.T) {
+	l := ParseLRC("[ar:Daft Punk]\r\n[ti:Around the World]\r\n[00:01.50]Around the world\n[00:03.5][00:10.25]Around <00:04.00>the world\n\n")
+	if !l.Synced {
+		t.Fatal("Expected synced lyrics")
+	}
+
+	expected := []Line{{1500, "Around the world"}, {3500, "Around the world"}, {10250, "Around the world"}}
+	if !reflect.DeepEqual(l.Lines, expected) {
+		t.Errorf("Expected %v, got %v", expected, l.Lines)
+	}
+
+	if !reflect.DeepEqual(l.Times(), []int{1500, 3500, 10250}) {
+		t.Errorf("Unexpected times %v", l.Times())
+	}
+}
+
+func TestParseLRCOffset(t *testing.T) {
+	l := ParseLRC("[offset:+500]\n[00:00.20]First\n[00:02.00]Second")
+	if !reflect.DeepEqual(l.Times(), []int{0, 1500}) {
+		t.Errorf("Expected the offset applied, got %v", l.Times())
+	}
+}
+
+func TestParseLRCPlain(t *testing.T) {
+	l := ParseLRC("[ar:Daft Punk]\nAround the world\nAround the world\n")
+	if l.Synced || l.Lines != nil || l.Text != "Around the world\nAround the world" {
+		t.Errorf("Expected plain lyrics, got %+v", l)
+	}
+
+	if l.Times() != nil {
+		t.Error("Expected no times for plain lyrics")
+	}
+}
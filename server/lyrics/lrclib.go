@@ -0,0 +1,113 @@
+package lyrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"gngeorgiev/audiotic/server/models"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	lrclibURL = "https://lrclib.net"
+	userAgent = "audiotic (https://github.com/gngeorgiev/audiotic)"
+
+	// maxDurationDiff is the largest difference between the durations of a track and its lyrics, in seconds
+	maxDurationDiff = 3
+)
+
+// lrclib searches the lyrics in the LRCLIB api or a compatible one
+type lrclib struct {
+	url    string
+	client *http.Client
+}
+
+type lrclibTrack struct {
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	Duration     float64 `json:"duration"`
+	Instrumental bool    `json:"instrumental"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+func newLRCLIB(u string) *lrclib {
+	if u == "" {
+		u = lrclibURL
+	}
+
+	return &lrclib{
+		url:    strings.TrimRight(u, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (l *lrclib) Name() string {
+	return SourceLRCLIB
+}
+
+// best picks the synced lyrics of a track of the same duration over the plain ones, nil when there's none
+func best(found []lrclibTrack, duration int) *lrclibTrack {
+	var res *lrclibTrack
+	for i := range found {
+		f := &found[i]
+		if duration > 0 && f.Duration > 0 && math.Abs(f.Duration-float64(duration)) > maxDurationDiff {
+			continue
+		}
+
+		if f.SyncedLyrics != "" {
+			return f
+		}
+
+		if res == nil && (f.PlainLyrics != "" || f.Instrumental) {
+			res = f
+		}
+	}
+
+	return res
+}
+
+func (l *lrclib) Find(t models.Track) (*Lyrics, error) {
+	if t.Artist == "" || t.CleanTitle == "" {
+		return nil, nil
+	}
+
+	q := url.Values{"track_name": {t.CleanTitle}, "artist_name": {t.Artist}}
+	req, err := http.NewRequest(http.MethodGet, l.url+"/api/search?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LRCLIB responded with %s", res.Status)
+	}
+
+	var found []lrclibTrack
+	if err := json.NewDecoder(res.Body).Decode(&found); err != nil {
+		return nil, err
+	}
+
+	f := best(found, t.Duration)
+	switch {
+	case f == nil:
+		return nil, nil
+	case f.SyncedLyrics != "":
+		lyrics := ParseLRC(f.SyncedLyrics)
+		return &lyrics, nil
+	case f.Instrumental:
+		return &Lyrics{Instrumental: true}, nil
+	default:
+		return &Lyrics{Text: strings.TrimSpace(f.PlainLyrics)}, nil
+	}
+}
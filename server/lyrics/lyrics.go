@@ -0,0 +1,162 @@
+package lyrics
+
+import (
+	"errors"
+	"gngeorgiev/audiotic/server/componentContainer"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/models"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	SourceLocal   = "local"
+	SourceLRCLIB  = "lrclib"
+	SourceYouTube = "youtube"
+
+	// missTTL is how long the tracks without lyrics aren't looked up again
+	missTTL = 24 * time.Hour
+)
+
+var (
+	db *storm.DB
+
+	sources = componentContainer.NewComponentContainer()
+
+	// ErrNotFound is returned for the tracks without lyrics
+	ErrNotFound = errors.New("No lyrics found")
+)
+
+// Line is a line of synced lyrics
+type Line struct {
+	// Time is when the line starts, in milliseconds
+	Time int    `json:"time"`
+	Text string `json:"text"`
+}
+
+// Lyrics are the lyrics of a track, they're cached in the database including the tracks without lyrics
+type Lyrics struct {
+	// ID is provider/id of the track
+	ID string `json:"id" storm:"id"`
+	// Source is the name of the source which found the lyrics, it's empty for the cached misses
+	Source string `json:"source"`
+	// Synced tells whether Lines have times, Text has the plain lyrics either way
+	Synced       bool      `json:"synced"`
+	Instrumental bool      `json:"instrumental"`
+	Lines        []Line    `json:"lines"`
+	Text         string    `json:"text"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Source is a source of lyrics, Find returns nil when it has no lyrics for a track
+type Source interface {
+	Name() string
+	Find(t models.Track) (*Lyrics, error)
+}
+
+// Sources holds the sources, they're tried in the order they are registered
+func Sources() componentContainer.ComponentContainer {
+	return sources
+}
+
+// Init registers the configured sources
+func Init(c config.LyricsConfig) error {
+	db = database.Get()
+	if err := db.Init(Lyrics{}); err != nil {
+		return err
+	}
+
+	names := c.Sources
+	if len(names) == 0 {
+		names = []string{SourceLocal, SourceLRCLIB, SourceYouTube}
+	}
+
+	for _, name := range names {
+		switch name {
+		case SourceLocal:
+			Sources().RegisterComponent(newLocal(c.Dir))
+		case SourceLRCLIB:
+			Sources().RegisterComponent(newLRCLIB(c.LRCLIB))
+		case SourceYouTube:
+			Sources().RegisterComponent(newCaptions(c.Languages))
+		default:
+			return errors.New("Unknown lyrics source " + name)
+		}
+	}
+
+	return nil
+}
+
+func key(t models.Track) string {
+	return strings.ToLower(t.Provider) + "/" + t.ID
+}
+
+// Times returns the start times of the lines, nil for the lyrics which aren't synced
+func (l Lyrics) Times() []int {
+	if !l.Synced {
+		return nil
+	}
+
+	res := make([]int, len(l.Lines))
+	for i, line := range l.Lines {
+		res[i] = line.Time
+	}
+
+	return res
+}
+
+// Get returns the cached lyrics of a track or finds them in the sources
+func Get(t models.Track) (Lyrics, error) {
+	var cached Lyrics
+	err := db.One("ID", key(t), &cached)
+	if err != nil && err != storm.ErrNotFound {
+		return Lyrics{}, err
+	}
+
+	if err == nil && (cached.Source != "" || time.Since(cached.UpdatedAt) < missTTL) {
+		if cached.Source == "" {
+			return Lyrics{}, ErrNotFound
+		}
+
+		return cached, nil
+	}
+
+	l, complete := find(t, Sources().GetComponents())
+	// a miss isn't cached when a source failed, it may have the lyrics later
+	if l.Source != "" || complete {
+		if err := db.Save(&l); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if l.Source == "" {
+		return Lyrics{}, ErrNotFound
+	}
+
+	return l, nil
+}
+
+// find asks the sources in order, complete is false when a source failed
+func find(t models.Track, sources []interface{}) (Lyrics, bool) {
+	complete := true
+	for _, s := range sources {
+		source := s.(Source)
+		l, err := source.Find(t)
+		if err != nil {
+			log.Println(err)
+			complete = false
+			continue
+		}
+
+		if l != nil {
+			l.ID, l.Source, l.UpdatedAt = key(t), source.Name(), time.Now()
+			return *l, complete
+		}
+	}
+
+	return Lyrics{ID: key(t), UpdatedAt: time.Now()}, complete
+}
@@ -0,0 +1,138 @@
+package lyrics
+
+import (
+	"errors"
+	"gngeorgiev/audiotic/server/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var track = models.Track{Provider: "YouTube", ID: "abc", Title: "Daft Punk - Around the World (Official Video)",
+	Artist: "Daft Punk", CleanTitle: "Around the World", Duration: 429}
+
+func TestLocalFindsFilesNextToTracksAndInDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lyrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "Daft Punk - Around the World.lrc"), []byte("[00:01.00]From the dir"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "song.lrc"), []byte("Next to the track"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := newLocal(dir)
+	found, err := l.Find(track)
+	if err != nil || found == nil || !found.Synced || found.Text != "From the dir" {
+		t.Errorf("Expected the lyrics in the dir, got %+v %v", found, err)
+	}
+
+	local := track
+	local.StreamUrl = "file://" + filepath.ToSlash(filepath.Join(dir, "song.mp3"))
+	found, err = l.Find(local)
+	if err != nil || found == nil || found.Synced || found.Text != "Next to the track" {
+		t.Errorf("Expected the lyrics next to the track, got %+v %v", found, err)
+	}
+
+	found, err = newLocal("").Find(models.Track{Title: "Unknown"})
+	if err != nil || found != nil {
+		t.Errorf("Expected no lyrics, got %+v %v", found, err)
+	}
+}
+
+func TestLRCLIBPrefersSyncedLyricsOfTheSameDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/search" || q.Get("track_name") != "Around the World" || q.Get("artist_name") != "Daft Punk" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+
+		w.Write([]byte(`[
+			{"duration":240,"syncedLyrics":"[00:01.00]Radio edit"},
+			{"duration":429,"plainLyrics":"Plain"},
+			{"duration":430,"syncedLyrics":"[00:02.00]Album version"}
+		]`))
+	}))
+	defer server.Close()
+
+	found, err := newLRCLIB(server.URL).Find(track)
+	if err != nil || found == nil || !found.Synced || found.Lines[0].Text != "Album version" {
+		t.Errorf("Expected the synced album version, got %+v %v", found, err)
+	}
+}
+
+func TestCaptionsAreSynced(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("v") != "abc" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+
+		// the videos have no german captions
+		if r.URL.Query().Get("lang") == "de" {
+			return
+		}
+
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8" ?><transcript>` +
+			`<text start="0.5" dur="2">[Music]</text>` +
+			`<text start="12.25" dur="2">♪ Around the world &amp;#39;round ♪</text>` +
+			`</transcript>`))
+	}))
+	defer server.Close()
+
+	c := newCaptions([]string{"de", "en"})
+	c.url = server.URL
+
+	found, err := c.Find(track)
+	if err != nil || found == nil || len(found.Lines) != 1 {
+		t.Fatalf("Expected a line of captions, got %+v %v", found, err)
+	}
+
+	if l := found.Lines[0]; l.Time != 12250 || l.Text != "Around the world 'round" {
+		t.Errorf("Unexpected line %+v", l)
+	}
+
+	found, err = c.Find(models.Track{Provider: "other", ID: "abc"})
+	if err != nil || found != nil {
+		t.Errorf("Expected no captions for other providers, got %+v %v", found, err)
+	}
+}
+
+type fakeSource struct {
+	name   string
+	lyrics *Lyrics
+	err    error
+}
+
+func (f fakeSource) Name() string {
+	return f.name
+}
+
+func (f fakeSource) Find(models.Track) (*Lyrics, error) {
+	return f.lyrics, f.err
+}
+
+func TestFindAsksTheSourcesInOrder(t *testing.T) {
+	l, complete := find(track, []interface{}{
+		fakeSource{name: "failing", err: errors.New("offline")},
+		fakeSource{name: "empty"},
+		fakeSource{name: "found", lyrics: &Lyrics{Text: "Around the world"}},
+		fakeSource{name: "later", lyrics: &Lyrics{Text: "Never asked"}},
+	})
+
+	if l.Source != "found" || l.ID != "youtube/abc" || l.Text != "Around the world" || complete {
+		t.Errorf("Unexpected lyrics %+v, complete %v", l, complete)
+	}
+
+	l, complete = find(track, []interface{}{fakeSource{name: "empty"}})
+	if l.Source != "" || !complete {
+		t.Errorf("Expected a complete miss, got %+v", l)
+	}
+}
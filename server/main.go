@@ -1,29 +1,54 @@
 package main
 
 import (
-	"encoding/json"
 	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/audioStream"
+	"gngeorgiev/audiotic/server/cache"
+	"gngeorgiev/audiotic/server/export"
 	"net/http"
 
 	"log"
 
-	"gngeorgiev/audiotic/server/player"
 	"strings"
 
 	"os"
 	"os/signal"
 
-	"gngeorgiev/audiotic/server/socketSessionsPool"
-
+	"net"
+	"net/url"
+	"sort"
 	"strconv"
 
 	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/lyrics"
+	"gngeorgiev/audiotic/server/metadata"
+	"gngeorgiev/audiotic/server/mpd"
+	"gngeorgiev/audiotic/server/mqtt"
+	"gngeorgiev/audiotic/server/scheduler"
+	"gngeorgiev/audiotic/server/scrobbler"
+	"gngeorgiev/audiotic/server/trackStore"
+	"gngeorgiev/audiotic/server/webhooks"
+
+	"gngeorgiev/audiotic/server/openapi"
+
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/equalizer"
+	"gngeorgiev/audiotic/server/profiles"
+	"gngeorgiev/audiotic/server/settings"
+	"gngeorgiev/audiotic/server/zones"
 
 	"gopkg.in/gin-contrib/cors.v1"
 	"gopkg.in/gin-gonic/gin.v1"
 	"gopkg.in/igm/sockjs-go.v2/sockjs"
 )
 
+const (
+	apiPort = "8090"
+	webPort = "8091"
+)
+
 func main() {
 	//var isHelp bool
 
@@ -65,68 +90,291 @@ func main() {
 	signal.Notify(stopCh, os.Interrupt)
 	<-stopCh
 
-	if player.Get() != nil {
-		if err := player.Get().Release(); err != nil {
-			log.Fatal(err)
-		}
-	}
+	zones.Release()
 
-	if err := history.Release(); err != nil {
+	if err := database.Release(); err != nil {
 		log.Fatal(err)
 	}
 }
 
 func initApp() {
-	if err := player.Init(); err != nil {
+	if err := config.Load(); err != nil {
 		log.Fatal(err)
 	}
 
-	api.Autoplay(true)
+	if err := database.Init(); err != nil {
+		log.Fatal(err)
+	}
 
 	if err := history.Init(); err != nil {
 		log.Fatal(err)
 	}
 
+	if err := auth.Init(config.Get().Auth.Enabled); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := profiles.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := settings.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := trackStore.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := equalizer.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := scheduler.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := webhooks.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := scrobbler.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	metadata.Init(config.Get().Metadata)
+
+	if err := lyrics.Init(config.Get().Lyrics); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := cache.Init(config.Get().Cache, api.CachedInUse); err != nil {
+		log.Fatal(err)
+	}
+
+	export.Init(config.Get().Export)
+
+	if err := zones.Init(config.Get().Zones); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, z := range zones.All() {
+		if err := api.Autoplay(z.Name, true); err != nil {
+			log.Fatal(err)
+		}
+
+		if s := config.Get().Streaming; s.Enabled {
+			if err := audioStream.Start(z, s); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if err := api.StartScheduler(); err != nil {
+		log.Fatal(err)
+	}
+
+	api.StartWebhooks()
+
+	if c := config.Get().Scrobbler; c.Enabled {
+		s := scrobbler.New(c)
+		go s.Run()
+		api.StartScrobbler(s)
+	}
+
+	if c := config.Get().MPD; c.Enabled {
+		startMPD(c)
+	}
+
+	if c := config.Get().MQTT; c.Enabled {
+		go mqtt.NewBridge(mqtt.ApiBackend(), c).Run()
+	}
+
 	r := gin.Default()
 	r.RedirectTrailingSlash = true
+	origins := allowedOrigins()
+	warnIfUnauthenticated(origins)
+	r.Use(cors.New(corsConfig(origins)))
+
+	registerRoutes(r)
+
+	s := gin.Default()
+	s.Static("/", "./www")
 
+	go func() {
+		log.Fatal(r.Run(":" + apiPort))
+	}()
+
+	go func() {
+		log.Fatal(s.Run(":" + webPort))
+	}()
+}
+
+// allowedOrigins returns the configured origins and the origins the bundled web UI is served from
+func allowedOrigins() map[string]bool {
+	allowed := uiOrigins()
+	for _, o := range config.Get().CorsOrigins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+
+	return allowed
+}
+
+// corsConfig allows the allowed origins, * allows every origin
+func corsConfig(allowed map[string]bool) cors.Config {
 	c := cors.DefaultConfig()
-	c.AllowAllOrigins = true
-	r.Use(cors.New(c))
+	c.AllowHeaders = append(c.AllowHeaders, "Authorization")
+	c.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+
+	c.AllowOriginFunc = func(origin string) bool {
+		return allowed[origin] || allowed["*"]
+	}
+
+	return c
+}
+
+// warnIfUnauthenticated warns when auth is disabled while pages from other machines may call the api,
+// every one of them is an admin then
+func warnIfUnauthenticated(allowed map[string]bool) {
+	if config.Get().Auth.Enabled {
+		return
+	}
+
+	remote := remoteOrigins(allowed)
+	if len(remote) == 0 {
+		return
+	}
+
+	log.Printf("WARNING: auth is disabled and the api accepts requests from %s, "+
+		"everyone who can reach port %s can control the player and manage the users as an admin. "+
+		"Set auth.enabled in the config to require tokens", strings.Join(remote, ", "), apiPort)
+}
+
+// remoteOrigins returns the sorted origins which aren't served from the loopback interface
+func remoteOrigins(allowed map[string]bool) []string {
+	res := make([]string, 0)
+	for o := range allowed {
+		if !isLoopbackOrigin(o) {
+			res = append(res, o)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
 
-	m := r.Group("/meta")
+func isLoopbackOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// uiOrigins returns the origins of the web UI served on webPort, one per name and address of this machine
+func uiOrigins() map[string]bool {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name, name+".local")
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Println(err)
+	}
+
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok {
+			hosts = append(hosts, n.IP.String())
+		}
+	}
+
+	res := make(map[string]bool)
+	for _, h := range hosts {
+		res["http://"+net.JoinHostPort(h, webPort)] = true
+	}
+
+	return res
+}
+
+// startMPD serves the MPD clients in the background, they control a single zone
+func startMPD(c config.MPDConfig) {
+	addr, zone := c.Address, c.Zone
+	if addr == "" {
+		addr = ":6600"
+	}
+
+	if zone == "" {
+		zone = zones.Default
+	}
+
+	if _, err := api.Zone(zone); err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		if err := mpd.ListenAndServe(addr, mpd.ZoneBackend(zone)); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+func registerRoutes(r *gin.Engine) {
+	r.GET("/openapi.json", openApiHandler())
+	r.GET("/stream.ogg", auth.Require(auth.RoleListener), audioStreamHandler())
+
+	registerV1Routes(&r.RouterGroup)
+	registerV1Routes(r.Group("/api/v1"))
+	registerV2Routes(r.Group("/api/v2"))
+}
+
+// registerV1Routes registers the legacy GET based routes. They are served from the root for the
+// existing web UI and from /api/v1 as a compatibility shim, new clients should use /api/v2
+func registerV1Routes(g *gin.RouterGroup) {
+	m := g.Group("/meta")
 	{
 		m.GET("/autocomplete/*query", autocompleteHandler())
 		m.GET("/search/*query", searchHandler())
 	}
 
-	p := r.Group("/player")
+	listener := auth.Require(auth.RoleListener)
+	controller := auth.Require(auth.RoleController)
+
+	p := g.Group("/player")
 	{
-		p.GET("/play/:provider/:id", playHandler())
-		p.GET("/pause", pauseHandler())
-		p.GET("/resume", resumeHandler())
-		p.GET("/stop", stopHandler())
-		p.GET("/status", playerStatusHandler())
-		p.GET("/seek/:time", seekHandler())
-		p.GET("/volume/:volume", volumeHandler())
-		p.GET("/updates/*info", playerUpdatesHandler())
+		p.GET("/play/:provider/:id", controller, playHandler())
+		p.GET("/pause", controller, pauseHandler())
+		p.GET("/resume", controller, resumeHandler())
+		p.GET("/stop", controller, stopHandler())
+		p.GET("/status", listener, playerStatusHandler())
+		p.GET("/seek/:time", controller, seekHandler())
+		p.GET("/volume/:volume", controller, volumeHandler())
+		p.GET("/updates/*info", listener, playerUpdatesHandler(routePath(g, "/player/updates")))
+		p.GET("/events", listener, playerEventsHandler())
 	}
 
-	h := r.Group("/history")
+	h := g.Group("/history")
 	{
-		h.GET("/get", getHistoryHandler())
+		h.GET("/get", listener, getHistoryHandler())
 	}
+}
 
-	s := gin.Default()
-	s.Static("/", "./www")
-
-	go func() {
-		log.Fatal(r.Run(":8090"))
-	}()
+func routePath(g *gin.RouterGroup, relativePath string) string {
+	return strings.TrimSuffix(g.BasePath(), "/") + relativePath
+}
 
-	go func() {
-		log.Fatal(s.Run(":8091"))
-	}()
+func openApiHandler() gin.HandlerFunc {
+	spec := openapi.Spec()
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	}
 }
 
 func autocompleteHandler() gin.HandlerFunc {
@@ -159,7 +407,7 @@ func playHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		provider := c.Param("provider")
 		id := c.Param("id")
-		err := api.Play(provider, id)
+		err := api.Play(zoneName(c), provider, id, auth.CallerOf(c).Name)
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -171,7 +419,7 @@ func playHandler() gin.HandlerFunc {
 
 func pauseHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := api.Pause()
+		err := api.Pause(zoneName(c))
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -183,7 +431,7 @@ func pauseHandler() gin.HandlerFunc {
 
 func resumeHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := api.Resume()
+		err := api.Resume(zoneName(c))
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -195,7 +443,7 @@ func resumeHandler() gin.HandlerFunc {
 
 func playerStatusHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		status, err := api.Status()
+		status, err := api.Status(zoneName(c))
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -207,7 +455,7 @@ func playerStatusHandler() gin.HandlerFunc {
 
 func stopHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := api.Stop()
+		err := api.Stop(zoneName(c))
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -217,35 +465,10 @@ func stopHandler() gin.HandlerFunc {
 	}
 }
 
-var playerUpdatesSessionPool = socketSessionsPool.New()
-
-func playerUpdatesHandler() gin.HandlerFunc {
-	go func() {
-		pl := player.Get()
-		updatedCh := make(chan *player.VlcStatus)
-		pl.OnUpdated(updatedCh)
-
-		for {
-			select {
-			case status := <-updatedCh:
-				b, err := json.Marshal(status)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				playerUpdatesSessionPool.Send(string(b))
-			}
-		}
-	}()
-
-	return func(c *gin.Context) {
-		handler := sockjs.NewHandler("/player/updates", sockjs.DefaultOptions, func(s sockjs.Session) {
-			playerUpdatesSessionPool.Add(s)
-		})
-
-		handler.ServeHTTP(c.Writer, c.Request)
-	}
+func playerUpdatesHandler(prefix string) gin.HandlerFunc {
+	return zoneSockJSHandler(prefix, func(sessions *zoneSessions, _ string, s sockjs.Session) {
+		sessions.updates.Handle(s, nil)
+	})
 }
 
 func seekHandler() gin.HandlerFunc {
@@ -257,7 +480,7 @@ func seekHandler() gin.HandlerFunc {
 			return
 		}
 
-		if err := api.Seek(t); err != nil {
+		if err := api.Seek(zoneName(c), t); err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
@@ -275,7 +498,7 @@ func volumeHandler() gin.HandlerFunc {
 			return
 		}
 
-		if err := api.Volume(v); err != nil {
+		if err := api.Volume(zoneName(c), v); err != nil {
 			c.AbortWithError(http.StatusBadRequest, err)
 			return
 		}
@@ -284,6 +507,7 @@ func volumeHandler() gin.HandlerFunc {
 	}
 }
 
+// getHistoryHandler serves the room history, the tracks played by everyone
 func getHistoryHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h, err := history.Get()
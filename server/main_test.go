@@ -0,0 +1,57 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/openapi"
+	"strings"
+	"testing"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+func TestEveryRouteIsInTheOpenApiSpec(t *testing.T) {
+	r := gin.New()
+	registerRoutes(r)
+
+	spec := openapi.Spec()
+	for _, route := range r.Routes() {
+		if spec.Operation(route.Method, route.Path) == nil {
+			t.Errorf("Route %s %s is not documented in the openapi spec", route.Method, route.Path)
+		}
+	}
+}
+
+func TestEverySpecPathIsARoute(t *testing.T) {
+	r := gin.New()
+	registerRoutes(r)
+
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+openapi.FromGinPath(route.Path)] = true
+	}
+
+	for path, item := range openapi.Spec().Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			if !registered[key] {
+				t.Errorf("Spec documents %s which is not registered", key)
+			}
+		}
+	}
+}
+
+func TestRemoteOriginsSkipTheLoopbackOrigins(t *testing.T) {
+	allowed := map[string]bool{
+		"http://localhost:8080":    true,
+		"http://127.0.0.1:8080":    true,
+		"http://[::1]:8080":        true,
+		"http://192.168.1.10:8080": true,
+		"http://music.local:8080":  true,
+		"*":                        true,
+	}
+
+	remote := remoteOrigins(allowed)
+	expected := []string{"*", "http://192.168.1.10:8080", "http://music.local:8080"}
+	if strings.Join(remote, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected the remote origins %v, got %v", expected, remote)
+	}
+}
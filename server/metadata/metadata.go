@@ -0,0 +1,107 @@
+package metadata
+
+import (
+	"gngeorgiev/audiotic/server/componentContainer"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/models"
+	"log"
+)
+
+// Metadata is what's known about the song of a track
+type Metadata struct {
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	Title  string `json:"title"`
+	// Duration is in seconds, 0 when it's unknown
+	Duration int `json:"duration"`
+}
+
+// Rule refines the metadata parsed from a title so far, the rules run in the order they are registered
+type Rule interface {
+	Apply(m *Metadata)
+}
+
+// RuleFunc adapts a function to a Rule
+type RuleFunc func(m *Metadata)
+
+func (f RuleFunc) Apply(m *Metadata) {
+	f(m)
+}
+
+// Lookup finds the song of the parsed metadata in a music database, ok is false when there's no match
+type Lookup interface {
+	Lookup(m Metadata) (res Metadata, ok bool, err error)
+}
+
+var (
+	rules = componentContainer.NewComponentContainer()
+
+	// lookup is nil when the lookup is disabled
+	lookup Lookup
+)
+
+// Rules holds the parsing rules, the built in ones run first
+func Rules() componentContainer.ComponentContainer {
+	return rules
+}
+
+func Init(c config.MetadataConfig) {
+	if c.Lookup {
+		lookup = newMusicBrainz(c.URL)
+	}
+}
+
+func parse(m *Metadata) {
+	for _, r := range Rules().GetComponents() {
+		r.(Rule).Apply(m)
+	}
+}
+
+// Parse runs the rules on a title, e.g. "Artist - Title (Official Video)"
+func Parse(title string) Metadata {
+	m := Metadata{Title: title}
+	parse(&m)
+	return m
+}
+
+// Fill parses the title of a track into the fields which are still empty, an artist set by the provider is kept
+func Fill(t *models.Track) {
+	if t.CleanTitle != "" {
+		return
+	}
+
+	m := Metadata{Artist: t.Artist, Album: t.Album, Title: t.Title, Duration: t.Duration}
+	parse(&m)
+
+	t.Artist, t.Album, t.CleanTitle = m.Artist, m.Album, m.Title
+	if t.Duration == 0 {
+		t.Duration = m.Duration
+	}
+}
+
+// Enrich fills the track and looks it up when the lookup is enabled, the lookup failures leave the parsed metadata
+func Enrich(t *models.Track) {
+	Fill(t)
+	if lookup == nil || t.Artist == "" || t.CleanTitle == "" {
+		return
+	}
+
+	m, ok, err := lookup.Lookup(Metadata{Artist: t.Artist, Album: t.Album, Title: t.CleanTitle, Duration: t.Duration})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	t.Artist, t.CleanTitle = m.Artist, m.Title
+	if t.Album == "" {
+		t.Album = m.Album
+	}
+
+	if t.Duration == 0 {
+		t.Duration = m.Duration
+	}
+}
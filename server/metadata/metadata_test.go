@@ -0,0 +1,35 @@
+package metadata
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		in, artist, title string
+	}{
+		{"Daft Punk - Around the World", "Daft Punk", "Around the World"},
+		{"Rick Astley - Never Gonna Give You Up (Official Music Video)", "Rick Astley", "Never Gonna Give You Up"},
+		{"Queen – Bohemian Rhapsody [Official Video Remastered]", "Queen", "Bohemian Rhapsody"},
+		{`Nirvana - "Smells Like Teen Spirit" (Lyrics) [HD]`, "Nirvana", "Smells Like Teen Spirit"},
+		{"Artist - Song (feat. Someone) | Napalm Records", "Artist", "Song (feat. Someone)"},
+		{"Artist - Song - Official Video", "Artist", "Song"},
+		{"Dj Set - Part 1 - Live", "Dj Set", "Part 1 - Live"},
+		{"Around the World (Official Audio)", "", "Around the World"},
+	} {
+		m := Parse(c.in)
+		if m.Artist != c.artist || m.Title != c.title {
+			t.Errorf("Expected %q by %q for %q, got %q by %q", c.title, c.artist, c.in, m.Title, m.Artist)
+		}
+	}
+}
+
+func TestFillKeepsTheKnownArtist(t *testing.T) {
+	track := models.Track{Title: "Around the World - Radio Edit", Artist: "Daft Punk", Duration: 240}
+	Fill(&track)
+
+	if track.Artist != "Daft Punk" || track.CleanTitle != "Around the World - Radio Edit" || track.Duration != 240 {
+		t.Errorf("Unexpected metadata %+v", track)
+	}
+}
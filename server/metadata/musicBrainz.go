@@ -0,0 +1,175 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	musicBrainzURL = "https://musicbrainz.org"
+	userAgent      = "audiotic/1.0 ( https://github.com/gngeorgiev/audiotic )"
+
+	// minScore is the lowest search score of a recording taken as a match
+	minScore = 90
+	// maxCached bounds the cached lookups, the cache is dropped once it's full
+	maxCached = 1000
+)
+
+// musicBrainz looks up the recordings in the MusicBrainz api or a compatible one, the results,
+// including the missing ones, are cached
+type musicBrainz struct {
+	url    string
+	client *http.Client
+	// interval is the least time between two requests, MusicBrainz allows one request per second
+	interval time.Duration
+
+	mutex sync.Mutex
+	next  time.Time
+	cache map[string]lookupResult
+}
+
+type lookupResult struct {
+	metadata Metadata
+	ok       bool
+}
+
+type musicBrainzResponse struct {
+	Recordings []struct {
+		Score        int    `json:"score"`
+		Title        string `json:"title"`
+		Length       int    `json:"length"`
+		ArtistCredit []struct {
+			Name       string `json:"name"`
+			JoinPhrase string `json:"joinphrase"`
+		} `json:"artist-credit"`
+		Releases []struct {
+			Title        string `json:"title"`
+			Status       string `json:"status"`
+			ReleaseGroup struct {
+				PrimaryType string `json:"primary-type"`
+			} `json:"release-group"`
+		} `json:"releases"`
+	} `json:"recordings"`
+}
+
+func newMusicBrainz(u string) *musicBrainz {
+	if u == "" {
+		u = musicBrainzURL
+	}
+
+	return &musicBrainz{
+		url:      strings.TrimRight(u, "/"),
+		client:   &http.Client{Timeout: 5 * time.Second},
+		interval: time.Second,
+		cache:    make(map[string]lookupResult),
+	}
+}
+
+// quote quotes a phrase of a lucene query
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// wait blocks until the next request is allowed
+func (mb *musicBrainz) wait() {
+	mb.mutex.Lock()
+	now := time.Now()
+	at := mb.next
+	if at.Before(now) {
+		at = now
+	}
+	mb.next = at.Add(mb.interval)
+	mb.mutex.Unlock()
+
+	time.Sleep(at.Sub(now))
+}
+
+func (mb *musicBrainz) Lookup(m Metadata) (Metadata, bool, error) {
+	key := strings.ToLower(m.Artist) + "\x00" + strings.ToLower(m.Title)
+
+	mb.mutex.Lock()
+	cached, found := mb.cache[key]
+	mb.mutex.Unlock()
+	if found {
+		return cached.metadata, cached.ok, nil
+	}
+
+	res, ok, err := mb.search(m)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	mb.mutex.Lock()
+	if len(mb.cache) >= maxCached {
+		mb.cache = make(map[string]lookupResult)
+	}
+	mb.cache[key] = lookupResult{res, ok}
+	mb.mutex.Unlock()
+
+	return res, ok, nil
+}
+
+func (mb *musicBrainz) search(m Metadata) (Metadata, bool, error) {
+	q := url.Values{
+		"query": {"recording:" + quote(m.Title) + " AND artist:" + quote(m.Artist)},
+		"fmt":   {"json"},
+		"limit": {"5"},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, mb.url+"/ws/2/recording?"+q.Encode(), nil)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	mb.wait()
+	res, err := mb.client.Do(req)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Metadata{}, false, fmt.Errorf("MusicBrainz responded with %s", res.Status)
+	}
+
+	var body musicBrainzResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return Metadata{}, false, err
+	}
+
+	for _, r := range body.Recordings {
+		if r.Score < minScore || len(r.ArtistCredit) == 0 {
+			continue
+		}
+
+		var artist string
+		for _, c := range r.ArtistCredit {
+			artist += c.Name + c.JoinPhrase
+		}
+
+		found := Metadata{Artist: artist, Title: r.Title, Duration: r.Length / 1000}
+		// the official albums are preferred over the singles and the compilations
+		for _, release := range r.Releases {
+			if found.Album == "" {
+				found.Album = release.Title
+			}
+
+			if release.Status == "Official" && release.ReleaseGroup.PrimaryType == "Album" {
+				found.Album = release.Title
+				break
+			}
+		}
+
+		return found, true, nil
+	}
+
+	return Metadata{}, false, nil
+}
@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const recordings = `{"recordings":[
+	{"score":70,"title":"Other","artist-credit":[{"name":"Other"}]},
+	{"score":100,"title":"Around the World","length":429533,
+	 "artist-credit":[{"name":"Daft Punk","joinphrase":" feat. "},{"name":"Someone"}],
+	 "releases":[
+		{"title":"Around the World","status":"Official","release-group":{"primary-type":"Single"}},
+		{"title":"Homework","status":"Official","release-group":{"primary-type":"Album"}}
+	 ]}
+]}`
+
+func TestMusicBrainzLookup(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/ws/2/recording" || r.URL.Query().Get("fmt") != "json" || r.Header.Get("User-Agent") == "" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+
+		if q := r.URL.Query().Get("query"); q != `recording:"Around the World" AND artist:"Daft \"Punk\""` {
+			t.Errorf("Unexpected query %s", q)
+		}
+
+		w.Write([]byte(recordings))
+	}))
+	defer server.Close()
+
+	mb := newMusicBrainz(server.URL + "/")
+	mb.interval = 0
+
+	for i := 0; i < 2; i++ {
+		m, ok, err := mb.Lookup(Metadata{Artist: `Daft "Punk"`, Title: "Around the World"})
+		if err != nil || !ok {
+			t.Fatalf("Expected a match, got %v %v", ok, err)
+		}
+
+		if m.Artist != "Daft Punk feat. Someone" || m.Title != "Around the World" || m.Album != "Homework" || m.Duration != 429 {
+			t.Errorf("Unexpected match %+v", m)
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected the second lookup to be cached, got %d requests", requests)
+	}
+}
+
+func TestEnrichKeepsTheParsedMetadataWithoutMatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"recordings":[]}`))
+	}))
+	defer server.Close()
+
+	defer func(l Lookup) { lookup = l }(lookup)
+	lookup = newMusicBrainz(server.URL)
+
+	track := models.Track{Title: "Daft Punk - Around the World (Official Video)"}
+	Enrich(&track)
+
+	if track.Artist != "Daft Punk" || track.CleanTitle != "Around the World" || track.Album != "" {
+		t.Errorf("Unexpected metadata %+v", track)
+	}
+}
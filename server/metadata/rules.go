@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// separators split the artist from the title, the first one found is used
+	separators = []string{" - ", " – ", " — ", " -- ", " ~ "}
+
+	// noise matches the bracketed parts of the video titles which aren't part of the song title
+	noise = regexp.MustCompile(`(?i)\s*[(\[【](?:[^)\]】]*\b(?:official|video|audio|lyrics?|visuali[sz]er|hd|hq|4k|mv|m/v|clip|explicit)\b[^)\]】]*)[)\]】]`)
+	// noiseSuffix matches the same without brackets at the end of a title
+	noiseSuffix = regexp.MustCompile(`(?i)\s*(?:[-–—|]\s*)?\bofficial\s+(?:music\s+|lyrics?\s+)?(?:video|audio)\s*$`)
+	spaces      = regexp.MustCompile(`\s+`)
+
+	quotes = []string{`"`, `'`, "“”", "‘’", "«»"}
+)
+
+func init() {
+	for _, r := range []Rule{
+		RuleFunc(stripNoise),
+		RuleFunc(stripSuffix),
+		RuleFunc(splitArtist),
+		RuleFunc(unquote),
+		RuleFunc(trim),
+	} {
+		Rules().RegisterComponent(r)
+	}
+}
+
+// stripNoise drops "(Official Video)", "[Lyrics]", "(HD)" and the like
+func stripNoise(m *Metadata) {
+	m.Title = noiseSuffix.ReplaceAllString(noise.ReplaceAllString(m.Title, ""), "")
+}
+
+// stripSuffix drops the channel names, labels and the like after a |
+func stripSuffix(m *Metadata) {
+	if i := strings.Index(m.Title, " | "); i > 0 {
+		m.Title = m.Title[:i]
+	}
+}
+
+// splitArtist splits "Artist - Title", unless the artist is already known
+func splitArtist(m *Metadata) {
+	if m.Artist != "" {
+		return
+	}
+
+	at, sep := -1, ""
+	for _, separator := range separators {
+		if i := strings.Index(m.Title, separator); i > 0 && (at < 0 || i < at) {
+			at, sep = i, separator
+		}
+	}
+
+	if at < 0 {
+		return
+	}
+
+	artist, title := strings.TrimSpace(m.Title[:at]), strings.TrimSpace(m.Title[at+len(sep):])
+	if artist != "" && title != "" {
+		m.Artist, m.Title = artist, title
+	}
+}
+
+// unquote drops the quotes around a title
+func unquote(m *Metadata) {
+	s := strings.TrimSpace(m.Title)
+	for _, q := range quotes {
+		r := []rune(q)
+		opening, closing := string(r[0]), string(r[len(r)-1])
+		if len(s) > len(opening)+len(closing) && strings.HasPrefix(s, opening) && strings.HasSuffix(s, closing) {
+			m.Title = s[len(opening) : len(s)-len(closing)]
+			return
+		}
+	}
+}
+
+func trim(m *Metadata) {
+	m.Artist = strings.TrimSpace(spaces.ReplaceAllString(m.Artist, " "))
+	m.Title = strings.TrimSpace(spaces.ReplaceAllString(m.Title, " "))
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+var ErrInvalidTrack = errors.New("Expected provider/id or the url of a track")
+
+// ParseTrack accepts provider/id or the url of a track of a known provider and returns its provider and id
+func ParseTrack(s string) (string, string, error) {
+	if !strings.Contains(s, "://") {
+		parts := strings.SplitN(s, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return "", "", ErrInvalidTrack
+		}
+
+		return parts[0], parts[1], nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", ErrInvalidTrack
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	switch host {
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		if id := u.Query().Get("v"); id != "" {
+			return "youtube", id, nil
+		}
+	case "youtu.be":
+		if id := strings.Trim(u.Path, "/"); id != "" {
+			return "youtube", id, nil
+		}
+	}
+
+	return "", "", ErrInvalidTrack
+}
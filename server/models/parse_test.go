@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestParseTrackAcceptsIdsAndUrls(t *testing.T) {
+	for _, c := range []struct {
+		in, provider, id string
+	}{
+		{"youtube/dQw4w9WgXcQ", "youtube", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42", "youtube", "dQw4w9WgXcQ"},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ", "youtube", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ", "youtube", "dQw4w9WgXcQ"},
+	} {
+		provider, id, err := ParseTrack(c.in)
+		if err != nil || provider != c.provider || id != c.id {
+			t.Errorf("Expected %s/%s for %s, got %s/%s %v", c.provider, c.id, c.in, provider, id, err)
+		}
+	}
+
+	for _, in := range []string{"dQw4w9WgXcQ", "youtube/", "https://example.com/watch?v=x", "https://youtu.be/"} {
+		if _, _, err := ParseTrack(in); err != ErrInvalidTrack {
+			t.Errorf("Expected %s to be rejected, got %v", in, err)
+		}
+	}
+}
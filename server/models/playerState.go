@@ -0,0 +1,34 @@
+package models
+
+// the states of the player in its status, they're here rather than in the player as the clients use them too
+// and the player needs libvlc
+const (
+	StatePlaying   = "playing"
+	StateBuffering = "buffering"
+	StateOpening   = "openning"
+	StatePaused    = "paused"
+	StateStopped   = "stopped"
+	StateEnded     = "ended"
+	StateIdle      = "idle"
+	StateError     = "error"
+)
+
+// IsActiveState tells whether a track plays in a state, it plays while it's opened and buffered too
+func IsActiveState(state string) bool {
+	switch state {
+	case StatePlaying, StateBuffering, StateOpening:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsStoppedState tells whether nothing plays in a state, the paused and the unknown states aren't stopped
+func IsStoppedState(state string) bool {
+	switch state {
+	case StateEnded, StateStopped, StateIdle, StateError:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestPlayerStates(t *testing.T) {
+	cases := []struct {
+		state           string
+		active, stopped bool
+	}{
+		{StatePlaying, true, false},
+		{StateBuffering, true, false},
+		{StateOpening, true, false},
+		{StatePaused, false, false},
+		{StateStopped, false, true},
+		{StateEnded, false, true},
+		{StateIdle, false, true},
+		{StateError, false, true},
+		{"unknown", false, false},
+	}
+
+	for _, c := range cases {
+		if IsActiveState(c.state) != c.active || IsStoppedState(c.state) != c.stopped {
+			t.Errorf("Expected %s to be active %t and stopped %t", c.state, c.active, c.stopped)
+		}
+	}
+}
@@ -11,4 +11,14 @@ type Track struct {
 	Next       string    `json:"next"`
 	Previous   string    `json:"previous"`
 	LastPlayed time.Time `json:"lastPlayed" storm:"index"`
+	QueuedBy   string    `json:"queuedBy,omitempty"`
+	Autoplayed bool      `json:"autoplayed,omitempty"`
+	// Loudness is the loudness in dB relative to the reference loudness of the provider, when the provider knows it
+	Loudness *float64 `json:"loudness,omitempty"`
+	// Artist, Album and CleanTitle are parsed from the title or looked up, they're empty when they're unknown
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+	CleanTitle string `json:"cleanTitle,omitempty"`
+	// Duration is in seconds, 0 when it's unknown
+	Duration int `json:"duration,omitempty"`
 }
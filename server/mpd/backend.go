@@ -0,0 +1,259 @@
+package mpd
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/controlProtocol"
+	"gngeorgiev/audiotic/server/eventStream"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+)
+
+// the MPD subsystems reported by idle
+const (
+	subsystemPlayer   = "player"
+	subsystemMixer    = "mixer"
+	subsystemPlaylist = "playlist"
+	subsystemOptions  = "options"
+	subsystemOutput   = "output"
+)
+
+// Backend is the zone the MPD clients control, the api of a zone in production and a fake in the tests
+type Backend interface {
+	Status() (*player.VlcStatus, error)
+	Current() (models.Track, error)
+	Queue() ([]models.Track, error)
+	Play(provider, id, queuedBy string) error
+	Enqueue(provider, id, queuedBy string) error
+	Dequeue(index int) error
+	ClearQueue() error
+	Pause() error
+	Resume() error
+	Stop() error
+	Next() error
+	Seek(time int) error
+	Volume(volume int) error
+	Search(query string) ([]models.Track, error)
+	// Subscribe returns a channel receiving the names of the changed subsystems and a func to unsubscribe
+	Subscribe() (<-chan string, func())
+}
+
+// events is the event stream of a zone, an eventStream.Stream in production
+type events interface {
+	Subscribe(o hub.Options) (<-chan interface{}, func())
+	// Done is closed once the zone is removed
+	Done() <-chan struct{}
+}
+
+type zoneBackend struct {
+	zone string
+	// stream returns the event stream of the zone, subscribeZones the names of the zones created at runtime
+	stream         func(zone string) (events, error)
+	subscribeZones func(o hub.Options) (<-chan interface{}, func())
+}
+
+// ZoneBackend controls a zone through the api
+func ZoneBackend(zone string) Backend {
+	return zoneBackend{
+		zone: zone,
+		stream: func(zone string) (events, error) {
+			s, err := eventStream.For(zone)
+			if err != nil {
+				return nil, err
+			}
+
+			return s, nil
+		},
+		subscribeZones: api.SubscribeZones,
+	}
+}
+
+func (b zoneBackend) Status() (*player.VlcStatus, error) {
+	return api.Status(b.zone)
+}
+
+func (b zoneBackend) Current() (models.Track, error) {
+	return api.CurrentTrack(b.zone)
+}
+
+func (b zoneBackend) Queue() ([]models.Track, error) {
+	return api.Queue(b.zone)
+}
+
+func (b zoneBackend) Play(provider, id, queuedBy string) error {
+	return api.Play(b.zone, provider, id, queuedBy)
+}
+
+func (b zoneBackend) Enqueue(provider, id, queuedBy string) error {
+	_, err := api.Enqueue(b.zone, provider, id, queuedBy)
+	return err
+}
+
+func (b zoneBackend) Dequeue(index int) error {
+	return api.Dequeue(b.zone, index)
+}
+
+func (b zoneBackend) ClearQueue() error {
+	return api.ClearQueue(b.zone)
+}
+
+func (b zoneBackend) Pause() error {
+	return api.Pause(b.zone)
+}
+
+func (b zoneBackend) Resume() error {
+	return api.Resume(b.zone)
+}
+
+func (b zoneBackend) Stop() error {
+	return api.Stop(b.zone)
+}
+
+func (b zoneBackend) Next() error {
+	return api.Next(b.zone)
+}
+
+func (b zoneBackend) Seek(time int) error {
+	return api.Seek(b.zone, time)
+}
+
+func (b zoneBackend) Volume(volume int) error {
+	return api.Volume(b.zone, volume)
+}
+
+func (b zoneBackend) Search(query string) ([]models.Track, error) {
+	found, err := api.Search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]models.Track, 0, len(found))
+	for _, t := range found {
+		if track, ok := t.(models.Track); ok {
+			res = append(res, track)
+		}
+	}
+
+	return res, nil
+}
+
+// Subscribe follows the event stream of the zone, the status is pushed every second while playing
+// so it's compared with the previous one to tell what actually changed. A removed zone is followed again
+// once it's created again
+func (b zoneBackend) Subscribe() (<-chan string, func()) {
+	res := make(chan string, 16)
+	created, unsubscribeZones := b.subscribeZones(hub.Options{Name: "mpd", Policy: hub.DropOldest})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(res)
+
+		for {
+			if stream, err := b.stream(b.zone); err == nil {
+				if !b.follow(stream, res, done) {
+					return
+				}
+			}
+
+			// the zone was removed or isn't created yet
+			if !b.waitForZone(created, done) {
+				return
+			}
+		}
+	}()
+
+	return res, func() {
+		unsubscribeZones()
+		close(done)
+	}
+}
+
+// follow sends the changes published by the stream of the zone until the zone is removed,
+// false is returned once the subscription is done
+func (b zoneBackend) follow(stream events, res chan<- string, done <-chan struct{}) bool {
+	published, unsubscribe := stream.Subscribe(hub.Options{Name: "mpd", BufferSize: 64, Policy: hub.DropOldest})
+	defer unsubscribe()
+
+	var last *player.VlcStatus
+	for {
+		var changed []string
+		select {
+		case msg, ok := <-published:
+			if !ok {
+				return false
+			}
+
+			e := msg.(eventStream.Event)
+			switch e.Type {
+			case controlProtocol.TypeStatus:
+				status, _ := e.Data.(*player.VlcStatus)
+				changed = statusChanges(last, status)
+				last = status
+			case controlProtocol.TypeQueue:
+				changed = []string{subsystemPlaylist}
+			case controlProtocol.TypeModes:
+				changed = []string{subsystemOptions}
+			}
+		case <-stream.Done():
+			return true
+		case <-done:
+			return false
+		}
+
+		for _, s := range changed {
+			select {
+			case res <- s:
+			case <-done:
+				return false
+			}
+		}
+	}
+}
+
+// waitForZone waits until the zone is created, false is returned once the subscription is done
+func (b zoneBackend) waitForZone(created <-chan interface{}, done <-chan struct{}) bool {
+	for {
+		select {
+		case name, ok := <-created:
+			if !ok {
+				return false
+			}
+
+			if name.(string) == b.zone {
+				return true
+			}
+		case <-done:
+			return false
+		}
+	}
+}
+
+func statusChanges(last, status *player.VlcStatus) []string {
+	if status == nil {
+		return nil
+	}
+
+	if last == nil {
+		return []string{subsystemPlayer, subsystemPlaylist, subsystemMixer}
+	}
+
+	var res []string
+	if last.State != status.State || last.Source != status.Source {
+		res = append(res, subsystemPlayer)
+	}
+
+	// the current track is the first song of the playlist
+	if last.Source != status.Source {
+		res = append(res, subsystemPlaylist)
+	}
+
+	if last.Volume != status.Volume {
+		res = append(res, subsystemMixer)
+	}
+
+	if last.Output != status.Output || last.OutputDevice != status.OutputDevice {
+		res = append(res, subsystemOutput)
+	}
+
+	return res
+}
@@ -0,0 +1,180 @@
+package mpd
+
+import (
+	"errors"
+	"gngeorgiev/audiotic/server/controlProtocol"
+	"gngeorgiev/audiotic/server/eventStream"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/player"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStream is the event stream of a zone, it's done once the zone is removed
+type fakeStream struct {
+	*hub.Hub
+	done chan struct{}
+}
+
+func (s *fakeStream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *fakeStream) publish(t string, data interface{}) {
+	s.Publish(eventStream.Event{Event: controlProtocol.NewEvent(t, data)})
+}
+
+// fakeZones hands out a new stream every time the zone is created
+type fakeZones struct {
+	mutex   sync.Mutex
+	stream  *fakeStream
+	created *hub.Hub
+}
+
+func (z *fakeZones) create() *fakeStream {
+	z.mutex.Lock()
+	z.stream = &fakeStream{Hub: hub.New("events"), done: make(chan struct{})}
+	s := z.stream
+	z.mutex.Unlock()
+
+	z.created.Publish("kitchen")
+	return s
+}
+
+func (z *fakeZones) remove() {
+	z.mutex.Lock()
+	close(z.stream.done)
+	z.stream = nil
+	z.mutex.Unlock()
+}
+
+func (z *fakeZones) backend() zoneBackend {
+	return zoneBackend{
+		zone: "kitchen",
+		stream: func(zone string) (events, error) {
+			z.mutex.Lock()
+			defer z.mutex.Unlock()
+
+			if z.stream == nil {
+				return nil, errors.New("Zone not found")
+			}
+			return z.stream, nil
+		},
+		subscribeZones: z.created.Subscribe,
+	}
+}
+
+func expectChanges(t *testing.T, changes <-chan string, expected ...string) {
+	var got []string
+	for len(got) < len(expected) {
+		select {
+		case s, ok := <-changes:
+			if !ok {
+				t.Fatalf("The changes were closed after %v", got)
+			}
+			got = append(got, s)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestStatusChanges(t *testing.T) {
+	playing := &player.VlcStatus{State: "playing", Source: "a", Volume: 100}
+	cases := []struct {
+		name         string
+		last, status *player.VlcStatus
+		expected     []string
+	}{
+		{"no status", playing, nil, nil},
+		{"first status", nil, playing, []string{subsystemPlayer, subsystemPlaylist, subsystemMixer}},
+		{"unchanged", playing, &player.VlcStatus{State: "playing", Source: "a", Volume: 100, Time: 10}, nil},
+		{"paused", playing, &player.VlcStatus{State: "paused", Source: "a", Volume: 100}, []string{subsystemPlayer}},
+		{"next track", playing, &player.VlcStatus{State: "playing", Source: "b", Volume: 100}, []string{subsystemPlayer, subsystemPlaylist}},
+		{"volume", playing, &player.VlcStatus{State: "playing", Source: "a", Volume: 50}, []string{subsystemMixer}},
+		{"output", playing, &player.VlcStatus{State: "playing", Source: "a", Volume: 100, Output: "pulse"}, []string{subsystemOutput}},
+	}
+
+	for _, c := range cases {
+		if got := statusChanges(c.last, c.status); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestZoneBackendReportsTheChanges(t *testing.T) {
+	z := &fakeZones{created: hub.New("zones")}
+	s := z.create()
+
+	changes, unsubscribe := z.backend().Subscribe()
+	defer unsubscribe()
+
+	// the subscription to the stream isn't synchronous, the first status is published until it's received
+	status := &player.VlcStatus{State: "playing", Source: "a", Volume: 100}
+	waitForFirstChange(t, s, status, changes)
+	expectChanges(t, changes, subsystemPlaylist, subsystemMixer)
+
+	s.publish(controlProtocol.TypeStatus, &player.VlcStatus{State: "paused", Source: "a", Volume: 100})
+	expectChanges(t, changes, subsystemPlayer)
+
+	s.publish(controlProtocol.TypeQueue, nil)
+	s.publish(controlProtocol.TypeModes, nil)
+	expectChanges(t, changes, subsystemPlaylist, subsystemOptions)
+}
+
+func TestZoneBackendFollowsARecreatedZone(t *testing.T) {
+	z := &fakeZones{created: hub.New("zones")}
+	s := z.create()
+
+	changes, unsubscribe := z.backend().Subscribe()
+	defer unsubscribe()
+
+	status := &player.VlcStatus{State: "playing", Source: "a", Volume: 100}
+	waitForFirstChange(t, s, status, changes)
+	expectChanges(t, changes, subsystemPlaylist, subsystemMixer)
+
+	z.remove()
+	s = z.create()
+
+	// the new stream reports everything again as the status is new
+	waitForFirstChange(t, s, status, changes)
+	expectChanges(t, changes, subsystemPlaylist, subsystemMixer)
+}
+
+func TestZoneBackendStopsOnUnsubscribe(t *testing.T) {
+	z := &fakeZones{created: hub.New("zones")}
+	changes, unsubscribe := z.backend().Subscribe()
+	unsubscribe()
+
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Fatal("Expected no changes")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("The changes weren't closed")
+	}
+}
+
+// waitForFirstChange publishes the status until the player change is reported
+func waitForFirstChange(t *testing.T, s *fakeStream, status *player.VlcStatus, changes <-chan string) {
+	for i := 0; i < 100; i++ {
+		s.publish(controlProtocol.TypeStatus, status)
+		select {
+		case c := <-changes:
+			if c != subsystemPlayer {
+				t.Fatalf("Expected the player to change first, got %s", c)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	t.Fatal("The stream wasn't followed")
+}
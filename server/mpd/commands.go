@@ -0,0 +1,835 @@
+package mpd
+
+import (
+	"fmt"
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxMpdVolume = 100
+
+type handler func(s *session, args []string, r *response) error
+
+type command struct {
+	role string
+	// maxArgs is -1 for commands taking any number of arguments
+	minArgs, maxArgs int
+	handle           handler
+}
+
+func always(minArgs, maxArgs int, h handler) command {
+	return command{minArgs: minArgs, maxArgs: maxArgs, handle: h}
+}
+
+func listen(minArgs, maxArgs int, h handler) command {
+	return command{role: auth.RoleListener, minArgs: minArgs, maxArgs: maxArgs, handle: h}
+}
+
+func control(minArgs, maxArgs int, h handler) command {
+	return command{role: auth.RoleController, minArgs: minArgs, maxArgs: maxArgs, handle: h}
+}
+
+// nothing answers the commands of the features the zones don't have, e.g. the music database
+func nothing(*session, []string, *response) error {
+	return nil
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":     always(0, 0, nothing),
+		"password": always(1, 1, password),
+		"commands": always(0, 0, listCommands),
+		"notcommands": always(0, 0, func(s *session, _ []string, r *response) error {
+			for _, name := range commandNames() {
+				if !auth.IsRoleAllowed(s.caller.Role, commands[name].role) {
+					r.add("command", name)
+				}
+			}
+
+			return nil
+		}),
+		"tagtypes": always(0, -1, func(_ *session, args []string, r *response) error {
+			if len(args) == 0 {
+				r.add("tagtype", "Artist")
+				r.add("tagtype", "Album")
+				r.add("tagtype", "Title")
+			}
+
+			return nil
+		}),
+		"urlhandlers":        always(0, 0, nothing),
+		"decoders":           always(0, 0, nothing),
+		"status":             listen(0, 0, status),
+		"currentsong":        listen(0, 0, currentSong),
+		"stats":              listen(0, 0, stats),
+		"outputs":            listen(0, 0, outputs),
+		"replay_gain_status": listen(0, 0, replayGainStatus),
+		"playlist":           listen(0, 0, playlist),
+		"playlistinfo":       listen(0, 1, playlistInfo),
+		"playlistid":         listen(0, 1, playlistID),
+		"plchanges":          listen(1, 2, playlistChanges),
+		"plchangesposid":     listen(1, 2, playlistChangesPosID),
+		"search":             listen(1, -1, search),
+		"find":               listen(1, -1, search),
+		"list":               listen(1, -1, nothing),
+		"lsinfo":             listen(0, 1, nothing),
+		"listplaylists":      listen(0, 0, nothing),
+		"channels":           listen(0, 0, nothing),
+		"readmessages":       listen(0, 0, nothing),
+		"play":               control(0, 1, play),
+		"playid":             control(0, 1, playID),
+		"pause":              control(0, 1, pause),
+		"stop":               control(0, 0, action(Backend.Stop)),
+		"next":               control(0, 0, action(Backend.Next)),
+		"previous":           control(0, 0, func(s *session, _ []string, _ *response) error { return s.server.backend.Seek(0) }),
+		"seek":               control(2, 2, seek),
+		"seekid":             control(2, 2, seekID),
+		"seekcur":            control(1, 1, seekCurrent),
+		"setvol":             control(1, 1, setVolume),
+		"volume":             control(1, 1, changeVolume),
+		"add":                control(1, 1, add),
+		"addid":              control(1, 2, addID),
+		"delete":             control(1, 1, deleteSong),
+		"deleteid":           control(1, 1, deleteID),
+		"clear":              control(0, 0, clear),
+		// the queue is consumed as it plays and there is no shuffle or repeat
+		"consume":   control(1, 1, fixedOption("1")),
+		"random":    control(1, 1, fixedOption("0")),
+		"repeat":    control(1, 1, fixedOption("0")),
+		"single":    control(1, 1, fixedOption("0")),
+		"crossfade": control(1, 1, fixedOption("0")),
+	}
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func listCommands(s *session, _ []string, r *response) error {
+	for _, name := range commandNames() {
+		if auth.IsRoleAllowed(s.caller.Role, commands[name].role) {
+			r.add("command", name)
+		}
+	}
+
+	return nil
+}
+
+// password authenticates the session with an api token, when auth is enabled nothing but this works without one
+func password(s *session, args []string, _ *response) error {
+	if !s.server.requireAuth {
+		return nil
+	}
+
+	u, err := auth.AuthenticateToken(args[0])
+	if err != nil {
+		return newAck(ackErrorPassword, "incorrect password")
+	}
+
+	s.caller = auth.Caller{Name: u.Name, Role: u.Role}
+	return nil
+}
+
+func action(f func(Backend) error) handler {
+	return func(s *session, _ []string, _ *response) error {
+		return f(s.server.backend)
+	}
+}
+
+func fixedOption(value string) handler {
+	return func(_ *session, args []string, _ *response) error {
+		if args[0] != value {
+			return newAck(ackErrorArg, "only %s is supported", value)
+		}
+
+		return nil
+	}
+}
+
+func parseInt(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, newAck(ackErrorArg, "Integer expected: %s", arg)
+	}
+
+	return n, nil
+}
+
+func parseSeconds(arg string) (float64, error) {
+	f, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, newAck(ackErrorArg, "Number expected: %s", arg)
+	}
+
+	return f, nil
+}
+
+// state maps the state of the player to play, pause or stop
+func state(status *player.VlcStatus) string {
+	switch {
+	case status == nil:
+		return "stop"
+	case models.IsActiveState(status.State):
+		return "play"
+	case status.State == models.StatePaused:
+		return "pause"
+	default:
+		return "stop"
+	}
+}
+
+// songFile is the uri of a track in the playlist and the search results, add takes the same uri
+func songFile(t models.Track) string {
+	return t.Provider + "/" + t.ID
+}
+
+func parseSongFile(uri string) (string, string, error) {
+	parts := strings.SplitN(uri, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", newAck(ackErrorNoExist, "Expected a provider/id uri: %s", uri)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// playlistState is the playlist seen by the clients, the loaded track followed by the queue
+type playlistState struct {
+	status *player.VlcStatus
+	tracks []models.Track
+	// loaded tells whether the first track is the one in the player, the rest is the queue
+	loaded bool
+}
+
+func (s *session) playlist() (playlistState, error) {
+	b := s.server.backend
+	status, err := b.Status()
+	if err != nil {
+		return playlistState{}, err
+	}
+
+	queue, err := b.Queue()
+	if err != nil {
+		return playlistState{}, err
+	}
+
+	p := playlistState{status: status}
+	if state(status) != "stop" {
+		current, err := b.Current()
+		if err != nil {
+			return playlistState{}, err
+		}
+
+		if current.Provider != "" {
+			p.tracks = append(p.tracks, current)
+			p.loaded = true
+		}
+	}
+
+	p.tracks = append(p.tracks, queue...)
+	return p, nil
+}
+
+// queueIndex returns the index in the queue of a song of the playlist
+func (p playlistState) queueIndex(pos int) int {
+	if p.loaded {
+		return pos - 1
+	}
+
+	return pos
+}
+
+func (p playlistState) song(pos int) (models.Track, error) {
+	if pos < 0 || pos >= len(p.tracks) {
+		return models.Track{}, newAck(ackErrorNoExist, "Bad song index")
+	}
+
+	return p.tracks[pos], nil
+}
+
+// songs are identified by their position, the id is the position + 1 since MPD ids start from 1
+func songID(pos int) int {
+	return pos + 1
+}
+
+func songPos(id int) int {
+	return id - 1
+}
+
+// writeTags writes the metadata of a track, the tracks without metadata have their raw title
+func writeTags(r *response, t models.Track) {
+	if t.Artist != "" {
+		r.add("Artist", t.Artist)
+	}
+
+	if t.Album != "" {
+		r.add("Album", t.Album)
+	}
+
+	title := t.CleanTitle
+	if title == "" {
+		title = t.Title
+	}
+	r.add("Title", title)
+}
+
+func (p playlistState) writeSong(r *response, pos int) {
+	t := p.tracks[pos]
+	r.add("file", songFile(t))
+	writeTags(r, t)
+
+	duration := t.Duration
+	if pos == 0 && p.loaded && p.status.Duration > 0 {
+		duration = p.status.Duration
+	}
+
+	if duration > 0 {
+		r.add("Time", duration)
+		r.add("duration", fmt.Sprintf("%d.000", duration))
+	}
+	r.add("Pos", pos)
+	r.add("Id", songID(pos))
+}
+
+func status(s *session, _ []string, r *response) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	volume := 0
+	if p.status != nil {
+		volume = p.status.Volume
+	}
+
+	if volume > maxMpdVolume {
+		volume = maxMpdVolume
+	}
+
+	r.add("volume", volume)
+	r.add("repeat", 0)
+	r.add("random", 0)
+	r.add("single", 0)
+	r.add("consume", 1)
+	r.add("playlist", s.server.playlistVersion())
+	r.add("playlistlength", len(p.tracks))
+	r.add("state", state(p.status))
+
+	if p.loaded {
+		r.add("song", 0)
+		r.add("songid", songID(0))
+		r.add("time", fmt.Sprintf("%d:%d", p.status.Time, p.status.Duration))
+		r.add("elapsed", fmt.Sprintf("%d.000", p.status.Time))
+		r.add("duration", fmt.Sprintf("%d.000", p.status.Duration))
+	}
+
+	next := 0
+	if p.loaded {
+		next = 1
+	}
+
+	if next < len(p.tracks) {
+		r.add("nextsong", next)
+		r.add("nextsongid", songID(next))
+	}
+
+	return nil
+}
+
+func currentSong(s *session, _ []string, r *response) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	if p.loaded {
+		p.writeSong(r, 0)
+	}
+
+	return nil
+}
+
+func stats(s *session, _ []string, r *response) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	playtime := 0
+	if p.loaded {
+		playtime = p.status.Time
+	}
+
+	r.add("artists", 0)
+	r.add("albums", 0)
+	r.add("songs", 0)
+	r.add("uptime", 0)
+	r.add("playtime", playtime)
+	r.add("db_playtime", 0)
+	r.add("db_update", 0)
+	return nil
+}
+
+func outputs(s *session, _ []string, r *response) error {
+	status, err := s.server.backend.Status()
+	if err != nil {
+		return err
+	}
+
+	name := "default"
+	if status != nil && status.Output != "" {
+		name = status.Output
+	}
+
+	r.add("outputid", 0)
+	r.add("outputname", name)
+	r.add("outputenabled", 1)
+	return nil
+}
+
+func replayGainStatus(_ *session, _ []string, r *response) error {
+	r.add("replay_gain_mode", "off")
+	return nil
+}
+
+func playlist(s *session, _ []string, r *response) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	for i, t := range p.tracks {
+		fmt.Fprintf(r, "%d:file: %s\n", i, songFile(t))
+	}
+
+	return nil
+}
+
+// parseRange parses a position or a start:end range of positions, the end is exclusive and can be omitted
+func parseRange(arg string, length int) (int, int, error) {
+	parts := strings.SplitN(arg, ":", 2)
+	start, err := parseInt(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end := start + 1
+	if len(parts) == 2 {
+		end = length
+		if parts[1] != "" {
+			if end, err = parseInt(parts[1]); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	if start < 0 || start > end || end > length || (len(parts) == 1 && start >= length) {
+		return 0, 0, newAck(ackErrorArg, "Bad song index")
+	}
+
+	return start, end, nil
+}
+
+func playlistInfo(s *session, args []string, r *response) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	start, end := 0, len(p.tracks)
+	if len(args) == 1 {
+		if start, end, err = parseRange(args[0], len(p.tracks)); err != nil {
+			return err
+		}
+	}
+
+	for i := start; i < end; i++ {
+		p.writeSong(r, i)
+	}
+
+	return nil
+}
+
+func playlistID(s *session, args []string, r *response) error {
+	if len(args) == 0 {
+		return playlistInfo(s, nil, r)
+	}
+
+	id, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.song(songPos(id)); err != nil {
+		return newAck(ackErrorNoExist, "No such song")
+	}
+
+	p.writeSong(r, songPos(id))
+	return nil
+}
+
+// playlistChanges returns the whole playlist when it changed since the version, the ids follow the positions
+// so every song changes with the playlist
+func playlistChanges(s *session, args []string, r *response) error {
+	version, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	if version == s.server.playlistVersion() {
+		return nil
+	}
+
+	return playlistInfo(s, nil, r)
+}
+
+func playlistChangesPosID(s *session, args []string, r *response) error {
+	version, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	if version == s.server.playlistVersion() {
+		return nil
+	}
+
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	for i := range p.tracks {
+		r.add("cpos", i)
+		r.add("Id", songID(i))
+	}
+
+	return nil
+}
+
+// search takes type/what pairs, or a single filter expression, and searches the providers for all the values
+func search(s *session, args []string, r *response) error {
+	var terms []string
+	if len(args) == 1 {
+		terms = append(terms, strings.Trim(args[0], "()"))
+	} else {
+		if len(args)%2 != 0 {
+			return newAck(ackErrorArg, "incorrect arguments")
+		}
+
+		for i := 1; i < len(args); i += 2 {
+			terms = append(terms, args[i])
+		}
+	}
+
+	tracks, err := s.server.backend.Search(strings.Join(terms, " "))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tracks {
+		r.add("file", songFile(t))
+		writeTags(r, t)
+	}
+
+	return nil
+}
+
+func play(s *session, args []string, _ *response) error {
+	if len(args) == 0 {
+		return resumeOrNext(s)
+	}
+
+	pos, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	return playPosition(s, pos)
+}
+
+func playID(s *session, args []string, _ *response) error {
+	if len(args) == 0 {
+		return resumeOrNext(s)
+	}
+
+	id, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	return playPosition(s, songPos(id))
+}
+
+// resumeOrNext resumes a paused track or plays the first song of the queue when nothing plays
+func resumeOrNext(s *session) error {
+	b := s.server.backend
+	status, err := b.Status()
+	if err != nil {
+		return err
+	}
+
+	switch state(status) {
+	case "pause":
+		return b.Resume()
+	case "play":
+		return nil
+	default:
+		return b.Next()
+	}
+}
+
+func playPosition(s *session, pos int) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	t, err := p.song(pos)
+	if err != nil {
+		return err
+	}
+
+	b := s.server.backend
+	if p.loaded && pos == 0 {
+		if state(p.status) == "pause" {
+			return b.Resume()
+		}
+
+		return b.Play(t.Provider, t.ID, s.caller.Name)
+	}
+
+	if err := b.Dequeue(p.queueIndex(pos)); err != nil {
+		return err
+	}
+
+	return b.Play(t.Provider, t.ID, s.caller.Name)
+}
+
+func pause(s *session, args []string, _ *response) error {
+	b := s.server.backend
+	if len(args) == 1 {
+		switch args[0] {
+		case "1":
+			return b.Pause()
+		case "0":
+			return b.Resume()
+		default:
+			return newAck(ackErrorArg, "Boolean (0/1) expected: %s", args[0])
+		}
+	}
+
+	status, err := b.Status()
+	if err != nil {
+		return err
+	}
+
+	if state(status) == "pause" {
+		return b.Resume()
+	}
+
+	return b.Pause()
+}
+
+// seekTo seeks in the loaded song, the only one of the playlist which can be seeked
+func seekTo(s *session, pos int, seconds float64) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	if !p.loaded || pos != 0 {
+		return newAck(ackErrorNoExist, "Only the current song can be seeked")
+	}
+
+	return s.server.backend.Seek(int(seconds))
+}
+
+func seek(s *session, args []string, _ *response) error {
+	pos, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	seconds, err := parseSeconds(args[1])
+	if err != nil {
+		return err
+	}
+
+	return seekTo(s, pos, seconds)
+}
+
+func seekID(s *session, args []string, _ *response) error {
+	id, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	seconds, err := parseSeconds(args[1])
+	if err != nil {
+		return err
+	}
+
+	return seekTo(s, songPos(id), seconds)
+}
+
+// seekCurrent seeks to a time or, when it's prefixed with + or -, relative to the elapsed time
+func seekCurrent(s *session, args []string, _ *response) error {
+	seconds, err := parseSeconds(args[0])
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+		status, err := s.server.backend.Status()
+		if err != nil {
+			return err
+		}
+
+		if status != nil {
+			seconds += float64(status.Time)
+		}
+	}
+
+	return seekTo(s, 0, seconds)
+}
+
+func setVolume(s *session, args []string, _ *response) error {
+	volume, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	if volume < 0 || volume > maxMpdVolume {
+		return newAck(ackErrorArg, "Invalid volume value")
+	}
+
+	return s.server.backend.Volume(volume)
+}
+
+func changeVolume(s *session, args []string, _ *response) error {
+	change, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	status, err := s.server.backend.Status()
+	if err != nil {
+		return err
+	}
+
+	volume := change
+	if status != nil {
+		volume += status.Volume
+	}
+
+	if volume < 0 {
+		volume = 0
+	}
+
+	if volume > maxMpdVolume {
+		volume = maxMpdVolume
+	}
+
+	return s.server.backend.Volume(volume)
+}
+
+func add(s *session, args []string, _ *response) error {
+	provider, id, err := parseSongFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	return s.server.backend.Enqueue(provider, id, s.caller.Name)
+}
+
+// addID adds a song to the end of the queue, adding it at a position isn't supported
+func addID(s *session, args []string, r *response) error {
+	// the position is checked first, so a rejected song isn't queued anyway
+	if len(args) == 2 {
+		pos, err := parseInt(args[1])
+		if err != nil {
+			return err
+		}
+
+		p, err := s.playlist()
+		if err != nil {
+			return err
+		}
+
+		if pos != len(p.tracks) {
+			return newAck(ackErrorArg, "Songs can only be added to the end of the playlist")
+		}
+	}
+
+	if err := add(s, args[:1], r); err != nil {
+		return err
+	}
+
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	r.add("Id", songID(len(p.tracks)-1))
+	return nil
+}
+
+func deletePosition(s *session, pos int) error {
+	p, err := s.playlist()
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.song(pos); err != nil {
+		return err
+	}
+
+	if p.loaded && pos == 0 {
+		return s.server.backend.Stop()
+	}
+
+	return s.server.backend.Dequeue(p.queueIndex(pos))
+}
+
+func deleteSong(s *session, args []string, _ *response) error {
+	pos, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	return deletePosition(s, pos)
+}
+
+func deleteID(s *session, args []string, _ *response) error {
+	id, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+
+	return deletePosition(s, songPos(id))
+}
+
+// clear empties the queue and stops the playback, like clearing the playlist does in MPD
+func clear(s *session, _ []string, _ *response) error {
+	if err := s.server.backend.ClearQueue(); err != nil {
+		return err
+	}
+
+	return s.server.backend.Stop()
+}
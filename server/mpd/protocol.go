@@ -0,0 +1,124 @@
+package mpd
+
+import (
+	"bytes"
+	"fmt"
+	"gngeorgiev/audiotic/server/api"
+	"strings"
+)
+
+// the ACK error codes of the MPD protocol
+const (
+	ackErrorNotList    = 1
+	ackErrorArg        = 2
+	ackErrorPassword   = 3
+	ackErrorPermission = 4
+	ackErrorUnknown    = 5
+	ackErrorNoExist    = 50
+	ackErrorSystem     = 52
+	ackErrorPlayerSync = 55
+)
+
+// ackError is a failure reported to the client as an ACK line
+type ackError struct {
+	code    int
+	message string
+}
+
+func (e *ackError) Error() string {
+	return e.message
+}
+
+func newAck(code int, format string, args ...interface{}) *ackError {
+	return &ackError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// toAck maps the errors of the api to the closest ACK codes
+func toAck(err error) *ackError {
+	if e, ok := err.(*ackError); ok {
+		return e
+	}
+
+	e := api.ToError(err)
+	code := ackErrorSystem
+	switch e.Code {
+	case api.CodeInvalidArgument:
+		code = ackErrorArg
+	case api.CodeNotFound:
+		code = ackErrorNoExist
+	case api.CodeInvalidState:
+		code = ackErrorPlayerSync
+	case api.CodeUnauthorized:
+		code = ackErrorPassword
+	case api.CodeForbidden:
+		code = ackErrorPermission
+	}
+
+	return &ackError{code: code, message: e.Message}
+}
+
+func ackLine(err *ackError, listNum int, command string) string {
+	return fmt.Sprintf("ACK [%d@%d] {%s} %s\n", err.code, listNum, command, err.message)
+}
+
+// parseLine splits a command line into the command and its arguments, arguments with spaces are quoted
+// and quotes and backslashes in them are escaped with a backslash
+func parseLine(line string) (string, []string, error) {
+	var (
+		args    []string
+		current bytes.Buffer
+		quoted  bool
+		inArg   bool
+	)
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+		case quoted && c == '"':
+			quoted = false
+			args = append(args, current.String())
+			current.Reset()
+			inArg = false
+		case quoted:
+			current.WriteByte(c)
+		case c == '"' && !inArg:
+			quoted = true
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if quoted {
+		return "", nil, newAck(ackErrorArg, "Invalid unquoted argument")
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	if len(args) == 0 {
+		return "", nil, newAck(ackErrorUnknown, "No command given")
+	}
+
+	return strings.ToLower(args[0]), args[1:], nil
+}
+
+// response collects the key: value lines of a command
+type response struct {
+	bytes.Buffer
+}
+
+func (r *response) add(key string, value interface{}) {
+	fmt.Fprintf(r, "%s: %v\n", key, value)
+}
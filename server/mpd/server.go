@@ -0,0 +1,368 @@
+package mpd
+
+import (
+	"bufio"
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/hub"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// protocolVersion is the version of the MPD protocol announced to the clients
+const protocolVersion = "0.19.0"
+
+// Server speaks the MPD protocol, so the MPD clients can control a zone. The playlist of the clients
+// is the current track followed by the queue
+type Server struct {
+	backend     Backend
+	requireAuth bool
+
+	mutex    sync.Mutex
+	version  int
+	changes  *hub.Hub
+	listener net.Listener
+	done     chan struct{}
+}
+
+func New(b Backend) *Server {
+	return &Server{
+		backend:     b,
+		requireAuth: auth.Enabled(),
+		version:     1,
+		changes:     hub.New("mpd"),
+		done:        make(chan struct{}),
+	}
+}
+
+// ListenAndServe serves the MPD clients of a zone on addr, e.g. :6600
+func ListenAndServe(addr string, b Backend) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return New(b).Serve(l)
+}
+
+// Serve accepts the clients until the listener is closed
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	s.listener = l
+	s.mutex.Unlock()
+
+	go s.watch()
+	defer s.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		go newSession(s, conn).serve()
+	}
+}
+
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+		close(s.done)
+	}
+
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+
+	return nil
+}
+
+// Metrics returns the metrics of the changes sent to the idle clients
+func (s *Server) Metrics() hub.Metrics {
+	return s.changes.Metrics()
+}
+
+// watch follows the changes of the backend, the playlist version is bumped on every playlist change
+func (s *Server) watch() {
+	changes, unsubscribe := s.backend.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case subsystem, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			if subsystem == subsystemPlaylist {
+				s.mutex.Lock()
+				s.version++
+				s.mutex.Unlock()
+			}
+
+			s.changes.Publish(subsystem)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Server) playlistVersion() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.version
+}
+
+// session is the connection of a single client
+type session struct {
+	server *Server
+	conn   net.Conn
+	w      *bufio.Writer
+	lines  chan string
+	caller auth.Caller
+	// done is closed when serve returns, so the reader doesn't wait for it to take another line
+	done chan struct{}
+
+	changes     <-chan interface{}
+	unsubscribe func()
+	pending     map[string]bool
+}
+
+func newSession(s *Server, conn net.Conn) *session {
+	changes, unsubscribe := s.changes.Subscribe(hub.Options{Name: conn.RemoteAddr().String(), BufferSize: 16, Policy: hub.DropOldest})
+	caller := auth.Caller{Role: auth.RoleAdmin}
+	if s.requireAuth {
+		caller = auth.Caller{}
+	}
+
+	return &session{
+		server:      s,
+		conn:        conn,
+		w:           bufio.NewWriter(conn),
+		lines:       make(chan string),
+		caller:      caller,
+		done:        make(chan struct{}),
+		changes:     changes,
+		unsubscribe: unsubscribe,
+		pending:     make(map[string]bool),
+	}
+}
+
+func (s *session) serve() {
+	defer func() {
+		close(s.done)
+		s.unsubscribe()
+		s.conn.Close()
+	}()
+
+	go s.read()
+
+	s.w.WriteString("OK MPD " + protocolVersion + "\n")
+	if s.w.Flush() != nil {
+		return
+	}
+
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok || !s.handle(line) {
+				return
+			}
+		case msg, ok := <-s.changes:
+			if !ok {
+				return
+			}
+
+			s.pending[msg.(string)] = true
+		case <-s.server.done:
+			return
+		}
+
+		if s.w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *session) read() {
+	defer close(s.lines)
+
+	scanner := bufio.NewScanner(s.conn)
+	for scanner.Scan() {
+		select {
+		case s.lines <- strings.TrimRight(scanner.Text(), "\r"):
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// handle runs a command or a command list started by line, false is returned when the connection should be closed
+func (s *session) handle(line string) bool {
+	name, args, err := parseLine(line)
+	if err != nil {
+		s.w.WriteString(ackLine(toAck(err), 0, ""))
+		return true
+	}
+
+	switch name {
+	case "close":
+		return false
+	case "idle":
+		return s.idle(args)
+	case "noidle":
+		// a noidle which arrived after idle returned is ignored
+		return true
+	case "command_list_begin", "command_list_ok_begin":
+		return s.commandList(name == "command_list_ok_begin")
+	}
+
+	res, ack := s.run(name, args)
+	if ack != nil {
+		s.w.WriteString(ackLine(ack, 0, name))
+		return true
+	}
+
+	s.w.Write(res.Bytes())
+	s.w.WriteString("OK\n")
+	return true
+}
+
+// commandList collects the commands until command_list_end and runs them in order, stopping at the first failure
+func (s *session) commandList(listOK bool) bool {
+	var lines []string
+	for {
+		line, ok := <-s.lines
+		if !ok {
+			return false
+		}
+
+		if strings.TrimSpace(line) == "command_list_end" {
+			break
+		}
+
+		lines = append(lines, line)
+	}
+
+	for i, line := range lines {
+		name, args, err := parseLine(line)
+		if err != nil {
+			s.w.WriteString(ackLine(toAck(err), i, ""))
+			return true
+		}
+
+		if name == "close" {
+			return false
+		}
+
+		res, ack := s.run(name, args)
+		if ack != nil {
+			s.w.WriteString(ackLine(ack, i, name))
+			return true
+		}
+
+		s.w.Write(res.Bytes())
+		if listOK {
+			s.w.WriteString("list_OK\n")
+		}
+	}
+
+	s.w.WriteString("OK\n")
+	return true
+}
+
+func (s *session) run(name string, args []string) (*response, *ackError) {
+	c, ok := commands[name]
+	if !ok {
+		return nil, newAck(ackErrorUnknown, "unknown command \"%s\"", name)
+	}
+
+	if !auth.IsRoleAllowed(s.caller.Role, c.role) {
+		return nil, newAck(ackErrorPermission, "you don't have permission for \"%s\"", name)
+	}
+
+	if len(args) < c.minArgs || (c.maxArgs >= 0 && len(args) > c.maxArgs) {
+		return nil, newAck(ackErrorArg, "wrong number of arguments for \"%s\"", name)
+	}
+
+	res := &response{}
+	if err := c.handle(s, args, res); err != nil {
+		return nil, toAck(err)
+	}
+
+	return res, nil
+}
+
+// idle waits for a change of one of the subsystems, or of any subsystem when none is given,
+// the changes which happened since the last idle are reported right away
+func (s *session) idle(subsystems []string) bool {
+	for {
+		if changed := s.takePending(subsystems); len(changed) > 0 {
+			for _, c := range changed {
+				s.w.WriteString("changed: " + c + "\n")
+			}
+
+			s.w.WriteString("OK\n")
+			return true
+		}
+
+		select {
+		case msg, ok := <-s.changes:
+			if !ok {
+				return false
+			}
+
+			s.pending[msg.(string)] = true
+		case line, ok := <-s.lines:
+			if !ok {
+				return false
+			}
+
+			if strings.TrimSpace(line) != "noidle" {
+				log.Println("mpd: closing a client which sent", line, "while idle")
+				return false
+			}
+
+			s.w.WriteString("OK\n")
+			return true
+		case <-s.server.done:
+			return false
+		}
+	}
+}
+
+func (s *session) takePending(subsystems []string) []string {
+	var res []string
+	for subsystem := range s.pending {
+		if len(subsystems) > 0 && !contains(subsystems, subsystem) {
+			continue
+		}
+
+		res = append(res, subsystem)
+		delete(s.pending, subsystem)
+	}
+
+	return res
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
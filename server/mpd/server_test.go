@@ -0,0 +1,525 @@
+package mpd
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"net"
+	"net/textproto"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend is a zone without libvlc, it plays whatever it's told right away
+type fakeBackend struct {
+	mutex   sync.Mutex
+	status  player.VlcStatus
+	current models.Track
+	queue   []models.Track
+	found   []models.Track
+	query   string
+	changes chan string
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		status:  player.VlcStatus{State: "stopped", Volume: 100},
+		changes: make(chan string, 64),
+	}
+}
+
+func (f *fakeBackend) changed(subsystems ...string) {
+	for _, s := range subsystems {
+		f.changes <- s
+	}
+}
+
+func (f *fakeBackend) Status() (*player.VlcStatus, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	status := f.status
+	return &status, nil
+}
+
+func (f *fakeBackend) Current() (models.Track, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.current, nil
+}
+
+func (f *fakeBackend) Queue() ([]models.Track, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]models.Track{}, f.queue...), nil
+}
+
+func (f *fakeBackend) Play(provider, id, _ string) error {
+	f.mutex.Lock()
+	f.current = models.Track{Provider: provider, ID: id, Title: "title of " + id}
+	f.status.State = "playing"
+	f.status.Name = f.current.Title
+	f.status.Source = provider + "/" + id
+	f.status.Duration = 200
+	f.status.Time = 0
+	f.mutex.Unlock()
+
+	f.changed(subsystemPlayer, subsystemPlaylist)
+	return nil
+}
+
+func (f *fakeBackend) Enqueue(provider, id, _ string) error {
+	f.mutex.Lock()
+	f.queue = append(f.queue, models.Track{Provider: provider, ID: id, Title: "title of " + id})
+	f.mutex.Unlock()
+
+	f.changed(subsystemPlaylist)
+	return nil
+}
+
+func (f *fakeBackend) Dequeue(index int) error {
+	f.mutex.Lock()
+	f.queue = append(f.queue[:index], f.queue[index+1:]...)
+	f.mutex.Unlock()
+
+	f.changed(subsystemPlaylist)
+	return nil
+}
+
+func (f *fakeBackend) ClearQueue() error {
+	f.mutex.Lock()
+	f.queue = nil
+	f.mutex.Unlock()
+
+	f.changed(subsystemPlaylist)
+	return nil
+}
+
+func (f *fakeBackend) setState(state string) error {
+	f.mutex.Lock()
+	f.status.State = state
+	f.mutex.Unlock()
+
+	f.changed(subsystemPlayer)
+	return nil
+}
+
+func (f *fakeBackend) Pause() error  { return f.setState("paused") }
+func (f *fakeBackend) Resume() error { return f.setState("playing") }
+func (f *fakeBackend) Stop() error   { return f.setState("stopped") }
+
+func (f *fakeBackend) Next() error {
+	f.mutex.Lock()
+	if len(f.queue) == 0 {
+		f.mutex.Unlock()
+		return f.Stop()
+	}
+
+	t := f.queue[0]
+	f.queue = f.queue[1:]
+	f.mutex.Unlock()
+
+	return f.Play(t.Provider, t.ID, "")
+}
+
+func (f *fakeBackend) Seek(time int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.status.Time = time
+	return nil
+}
+
+func (f *fakeBackend) Volume(volume int) error {
+	f.mutex.Lock()
+	f.status.Volume = volume
+	f.mutex.Unlock()
+
+	f.changed(subsystemMixer)
+	return nil
+}
+
+func (f *fakeBackend) Search(query string) ([]models.Track, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.query = query
+	return f.found, nil
+}
+
+func (f *fakeBackend) Subscribe() (<-chan string, func()) {
+	return f.changes, func() {}
+}
+
+func startServer(t *testing.T) (*fakeBackend, string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newFakeBackend()
+	s := New(b)
+	go s.Serve(l)
+
+	return b, l.Addr().String(), func() { s.Close() }
+}
+
+// client is a minimal MPD client, it returns the lines of a response without the final OK
+type client struct {
+	*textproto.Conn
+}
+
+func dial(t *testing.T, addr string) *client {
+	conn, err := textproto.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	greeting, err := conn.ReadLine()
+	if err != nil || !strings.HasPrefix(greeting, "OK MPD ") {
+		t.Fatalf("Expected the MPD greeting, got %s %v", greeting, err)
+	}
+
+	return &client{conn}
+}
+
+func (c *client) send(t *testing.T, lines ...string) []string {
+	for _, line := range lines {
+		if err := c.PrintfLine("%s", line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return c.response(t)
+}
+
+// pipeline writes the lines at once, the way libmpdclient based clients like mpc and ncmpcpp send command lists
+func (c *client) pipeline(t *testing.T, lines ...string) {
+	c.W.WriteString(strings.Join(lines, "\n") + "\n")
+	if err := c.W.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *client) response(t *testing.T) []string {
+	var res []string
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if line == "OK" {
+			return res
+		}
+
+		if strings.HasPrefix(line, "ACK ") {
+			return append(res, line)
+		}
+
+		res = append(res, line)
+	}
+}
+
+func values(lines []string) map[string]string {
+	res := make(map[string]string)
+	for _, line := range lines {
+		if parts := strings.SplitN(line, ": ", 2); len(parts) == 2 {
+			res[parts[0]] = parts[1]
+		}
+	}
+
+	return res
+}
+
+func TestStatusAndCurrentSongFollowThePlayer(t *testing.T) {
+	b, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	if s := values(c.send(t, "status")); s["state"] != "stop" || s["playlistlength"] != "0" {
+		t.Fatalf("Expected a stopped player with an empty playlist, got %v", s)
+	}
+
+	b.Play("youtube", "abc", "")
+	b.Seek(42)
+
+	s := values(c.send(t, "status"))
+	if s["state"] != "play" || s["song"] != "0" || s["time"] != "42:200" || s["volume"] != "100" {
+		t.Fatalf("Expected the song to play, got %v", s)
+	}
+
+	song := values(c.send(t, "currentsong"))
+	if song["file"] != "youtube/abc" || song["Title"] != "title of abc" || song["Pos"] != "0" {
+		t.Fatalf("Expected the current song, got %v", song)
+	}
+}
+
+func TestPlaybackCommandsControlThePlayer(t *testing.T) {
+	b, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	b.Play("youtube", "abc", "")
+
+	for _, step := range []struct {
+		command, state string
+	}{
+		{"pause", "paused"},
+		{"play", "playing"},
+		{"pause 1", "paused"},
+		{"pause 0", "playing"},
+		{"stop", "stopped"},
+	} {
+		if res := c.send(t, step.command); len(res) != 0 {
+			t.Fatalf("Expected %s to succeed, got %v", step.command, res)
+		}
+
+		if status, _ := b.Status(); status.State != step.state {
+			t.Fatalf("Expected %s after %s, got %s", step.state, step.command, status.State)
+		}
+	}
+
+	b.Play("youtube", "abc", "")
+	c.send(t, "setvol 30")
+	c.send(t, "seekcur 60")
+	c.send(t, "seekcur -10")
+
+	if status, _ := b.Status(); status.Volume != 30 || status.Time != 50 {
+		t.Fatalf("Expected the volume 30 at 50s, got %d at %ds", status.Volume, status.Time)
+	}
+
+	if res := c.send(t, "setvol 130"); len(res) != 1 || !strings.HasPrefix(res[0], "ACK [2@0] {setvol}") {
+		t.Fatalf("Expected an out of range volume to be rejected, got %v", res)
+	}
+}
+
+func TestAddPlaylistAndPlayFromThePlaylist(t *testing.T) {
+	b, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	b.Play("youtube", "abc", "")
+	c.send(t, "add youtube/def")
+	if res := c.send(t, "addid youtube/xyz 0"); len(res) != 1 || !strings.HasPrefix(res[0], "ACK [2@0] {addid}") {
+		t.Fatalf("Expected a song in the middle of the playlist to be rejected, got %v", res)
+	}
+
+	if res := values(c.send(t, "addid \"youtube/ghi\"")); res["Id"] != "3" {
+		t.Fatalf("Expected the id of the third song, got %v", res)
+	}
+
+	lines := c.send(t, "playlistinfo")
+	files := []string{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "file: ") {
+			files = append(files, strings.TrimPrefix(line, "file: "))
+		}
+	}
+
+	if strings.Join(files, ",") != "youtube/abc,youtube/def,youtube/ghi" {
+		t.Fatalf("Expected the current song followed by the queue, got %v", lines)
+	}
+
+	c.send(t, "play 2")
+	if current, _ := b.Current(); current.ID != "ghi" {
+		t.Fatalf("Expected ghi to play, got %s", current.ID)
+	}
+
+	c.send(t, "delete 1")
+	if queue, _ := b.Queue(); len(queue) != 0 {
+		t.Fatalf("Expected the queue to be empty, got %v", queue)
+	}
+}
+
+func TestSearchGoesThroughTheBackend(t *testing.T) {
+	b, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	b.found = []models.Track{{Provider: "youtube", ID: "abc", Title: "Song"}}
+	s := values(c.send(t, "search any \"daft punk\""))
+	if b.query != "daft punk" || s["file"] != "youtube/abc" || s["Title"] != "Song" {
+		t.Fatalf("Expected the search results of daft punk, got %v for %s", s, b.query)
+	}
+}
+
+func TestIdleReportsTheChangedSubsystems(t *testing.T) {
+	b, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	if err := c.PrintfLine("idle mixer"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	b.Play("youtube", "abc", "")
+	b.Volume(20)
+
+	if res := c.response(t); len(res) != 1 || res[0] != "changed: mixer" {
+		t.Fatalf("Expected the mixer to change, got %v", res)
+	}
+
+	// the player changed while the client wasn't waiting for it, so it's reported right away
+	if res := c.send(t, "idle player"); len(res) != 1 || res[0] != "changed: player" {
+		t.Fatalf("Expected the pending player change, got %v", res)
+	}
+
+	if res := c.send(t, "idle", "noidle"); len(res) != 1 || res[0] != "changed: playlist" {
+		t.Fatalf("Expected the pending playlist change, got %v", res)
+	}
+}
+
+func TestCommandListsStopAtTheFirstFailure(t *testing.T) {
+	_, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	res := c.send(t, "command_list_ok_begin", "ping", "ping", "command_list_end")
+	if len(res) != 2 || res[0] != "list_OK" || res[1] != "list_OK" {
+		t.Fatalf("Expected two list_OK, got %v", res)
+	}
+
+	res = c.send(t, "command_list_begin", "ping", "bogus", "ping", "command_list_end")
+	if len(res) != 1 || res[0] != `ACK [5@1] {bogus} unknown command "bogus"` {
+		t.Fatalf("Expected the second command to fail, got %v", res)
+	}
+}
+
+func TestNoidleRightAfterIdleReturnsNothing(t *testing.T) {
+	_, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	// ncmpcpp idles between the commands and sends noidle before each of them
+	c.pipeline(t, "idle")
+	time.Sleep(50 * time.Millisecond)
+	c.pipeline(t, "noidle")
+	if res := c.response(t); len(res) != 0 {
+		t.Fatalf("Expected an empty response to noidle, got %v", res)
+	}
+
+	if res := c.send(t, "ping"); len(res) != 0 {
+		t.Fatalf("Expected ping to succeed, got %v", res)
+	}
+}
+
+func TestNoidleAfterAChangeIsIgnored(t *testing.T) {
+	b, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	c.pipeline(t, "idle player")
+	time.Sleep(50 * time.Millisecond)
+	b.Pause()
+	if res := c.response(t); len(res) != 1 || res[0] != "changed: player" {
+		t.Fatalf("Expected the player to change, got %v", res)
+	}
+
+	// the client didn't see the change yet and cancels the idle which already returned, it's sent no response
+	c.pipeline(t, "noidle", "ping")
+	if res := c.response(t); len(res) != 0 {
+		t.Fatalf("Expected only the OK of ping, got %v", res)
+	}
+
+	if res := c.send(t, "ping"); len(res) != 0 {
+		t.Fatalf("Expected the responses not to be shifted, got %v", res)
+	}
+}
+
+func TestCommandListsAsSentByMpc(t *testing.T) {
+	b, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	b.Play("youtube", "abc", "")
+
+	// mpc prints the status by sending the current song and the status in one list
+	c.pipeline(t, "command_list_ok_begin", "currentsong", "status", "command_list_end")
+	res := c.response(t)
+	if len(res) == 0 || res[len(res)-1] != "list_OK" {
+		t.Fatalf("Expected the list to end with list_OK, got %v", res)
+	}
+
+	oks, v := 0, values(res)
+	for _, line := range res {
+		if line == "list_OK" {
+			oks++
+		}
+	}
+
+	if oks != 2 || v["file"] != "youtube/abc" || v["state"] != "play" {
+		t.Fatalf("Expected the song and the status, got %v", res)
+	}
+
+	// the idle after the list of ncmpcpp is pipelined with it and reports the changes the list made
+	c.pipeline(t, "noidle", "command_list_begin", "pause 1", "setvol 40", "command_list_end", "idle player mixer")
+	if res := c.response(t); len(res) != 0 {
+		t.Fatalf("Expected the list to succeed, got %v", res)
+	}
+
+	changed := c.response(t)
+	if len(changed) == 0 {
+		t.Fatal("Expected the idle to report the changes")
+	}
+
+	for _, line := range changed {
+		if line != "changed: player" && line != "changed: mixer" {
+			t.Fatalf("Expected the player and the mixer to change, got %v", changed)
+		}
+	}
+
+	if status, _ := b.Status(); status.State != "paused" || status.Volume != 40 {
+		t.Fatalf("Expected the player to be paused at 40, got %s at %d", status.State, status.Volume)
+	}
+}
+
+func TestSessionReadersStopWithTheirSession(t *testing.T) {
+	_, addr, stop := startServer(t)
+	defer stop()
+	c := dial(t, addr)
+	defer c.Close()
+
+	// the lines after close are read but never handled, the reader mustn't wait for them to be taken
+	c.pipeline(t, "close", "ping", "ping")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for readers() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the reader of the closed session to stop, running readers: %d", readers())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readers counts the goroutines reading the lines of a session
+func readers() int {
+	buf := make([]byte, 1<<20)
+	return strings.Count(string(buf[:runtime.Stack(buf, true)]), "mpd.(*session).read(")
+}
+
+func TestParseLineUnquotesArguments(t *testing.T) {
+	name, args, err := parseLine(`Search any "say \"hi\" \\ there" title x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "search" || len(args) != 4 || args[1] != `say "hi" \ there` || args[3] != "x" {
+		t.Fatalf("Expected the quoted argument, got %s %q", name, args)
+	}
+}
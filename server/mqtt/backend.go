@@ -0,0 +1,147 @@
+package mqtt
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/zones"
+)
+
+// queuedBy is the user shown for the tracks played through MQTT
+const queuedBy = "mqtt"
+
+// Backend is what the bridge controls, the api of the zones in production and a fake in the tests
+type Backend interface {
+	Zones() []string
+	// SubscribeZones returns a channel receiving the names of the zones created later
+	SubscribeZones() (<-chan string, func())
+	// Follow returns a channel receiving the status of a zone when it changes, it's closed once the zone is removed
+	Follow(zone string) (<-chan *player.VlcStatus, func())
+	Status(zone string) (*player.VlcStatus, error)
+	Play(zone, provider, id string) error
+	Pause(zone string) error
+	Resume(zone string) error
+	Stop(zone string) error
+	Next(zone string) error
+	Seek(zone string, time int) error
+	Volume(zone string, volume int) error
+}
+
+type apiBackend struct{}
+
+// ApiBackend controls the zones through the api
+func ApiBackend() Backend {
+	return apiBackend{}
+}
+
+func (apiBackend) Zones() []string {
+	all := zones.All()
+	res := make([]string, len(all))
+	for i, z := range all {
+		res[i] = z.Name
+	}
+
+	return res
+}
+
+func (apiBackend) SubscribeZones() (<-chan string, func()) {
+	res := make(chan string)
+	created, unsubscribe := api.SubscribeZones(hub.Options{Name: "mqtt", Policy: hub.DropOldest})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(res)
+
+		for msg := range created {
+			select {
+			case res <- msg.(string):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return res, func() {
+		unsubscribe()
+		close(done)
+	}
+}
+
+func (apiBackend) Follow(zone string) (<-chan *player.VlcStatus, func()) {
+	res := make(chan *player.VlcStatus)
+	z, err := api.Zone(zone)
+	if err != nil {
+		close(res)
+		return res, func() {}
+	}
+
+	updates, unsubscribe := z.Player.Subscribe(hub.Options{Name: "mqtt", BufferSize: 1, Policy: hub.Coalesce})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(res)
+
+		for {
+			select {
+			case msg, ok := <-updates:
+				if !ok {
+					return
+				}
+
+				status, _ := msg.(*player.VlcStatus)
+				if status == nil {
+					continue
+				}
+
+				select {
+				case res <- status:
+				case <-z.Done():
+					return
+				case <-done:
+					return
+				}
+			case <-z.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return res, func() {
+		unsubscribe()
+		close(done)
+	}
+}
+
+func (apiBackend) Status(zone string) (*player.VlcStatus, error) {
+	return api.Status(zone)
+}
+
+func (apiBackend) Play(zone, provider, id string) error {
+	return api.Play(zone, provider, id, queuedBy)
+}
+
+func (apiBackend) Pause(zone string) error {
+	return api.Pause(zone)
+}
+
+func (apiBackend) Resume(zone string) error {
+	return api.Resume(zone)
+}
+
+func (apiBackend) Stop(zone string) error {
+	return api.Stop(zone)
+}
+
+func (apiBackend) Next(zone string) error {
+	return api.Next(zone)
+}
+
+func (apiBackend) Seek(zone string, time int) error {
+	return api.Seek(zone, time)
+}
+
+func (apiBackend) Volume(zone string, volume int) error {
+	return api.Volume(zone, volume)
+}
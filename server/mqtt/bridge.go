@@ -0,0 +1,526 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultClientID        = "audiotic"
+	defaultPrefix          = "audiotic"
+	defaultDiscoveryPrefix = "homeassistant"
+
+	online  = "online"
+	offline = "offline"
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+	pendingCommands   = 16
+)
+
+// the commands accepted on <prefix>/<zone>/cmd/<command>
+const (
+	commandPlay        = "play"
+	commandPlayURL     = "play_url"
+	commandPause       = "pause"
+	commandResume      = "resume"
+	commandStop        = "stop"
+	commandNext        = "next"
+	commandSeek        = "seek"
+	commandVolume      = "volume"
+	commandVolumeLevel = "volume_level"
+)
+
+// Bridge publishes the status of every zone to retained topics under <prefix>/<zone>/ and runs the commands
+// published to <prefix>/<zone>/cmd/<command>:
+//
+//	play          resumes, or plays the track in the payload, provider/id or a url
+//	play_url      plays the track in the payload
+//	pause, resume, stop, next
+//	seek          seeks to the seconds in the payload
+//	volume        sets the volume, 0 to 200
+//	volume_level  sets the volume as the 0 to 1 level of Home Assistant
+//
+// The failed commands are reported on <prefix>/<zone>/error, <prefix>/availability tells whether the bridge is connected.
+// Home Assistant has no MQTT media player, so every zone is announced under the discovery prefix as a device with
+// the state and title sensors, a volume number, play, pause, stop and next buttons and a text playing a url
+type Bridge struct {
+	backend         Backend
+	options         Options
+	dial            dialer
+	prefix          string
+	discoveryPrefix string
+
+	done      chan struct{}
+	closeOnce sync.Once
+	stopped   chan struct{}
+}
+
+// zoneStatus is a status of a zone followed by the bridge, a nil status is published as idle
+type zoneStatus struct {
+	zone   string
+	status *player.VlcStatus
+}
+
+// entity is the Home Assistant discovery config of an entity of a zone, ~ stands for the topic of the zone
+type entity struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	BaseTopic           string   `json:"~"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	PayloadAvailable    string   `json:"payload_available"`
+	PayloadNotAvailable string   `json:"payload_not_available"`
+	StateTopic          string   `json:"state_topic,omitempty"`
+	AttributesTopic     string   `json:"json_attributes_topic,omitempty"`
+	CommandTopic        string   `json:"command_topic,omitempty"`
+	Min                 *float64 `json:"min,omitempty"`
+	Max                 *float64 `json:"max,omitempty"`
+	Step                float64  `json:"step,omitempty"`
+	Icon                string   `json:"icon,omitempty"`
+	Device              device   `json:"device"`
+}
+
+// discovered is an entity with the Home Assistant component it belongs to, e.g. sensor, and its object id
+type discovered struct {
+	component, object string
+	entity            entity
+}
+
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// NewBridge creates the bridge of the configured broker, it connects once Run is called
+func NewBridge(b Backend, c config.MQTTConfig) *Bridge {
+	res := &Bridge{
+		backend:         b,
+		dial:            dialPaho,
+		prefix:          c.Prefix,
+		discoveryPrefix: c.DiscoveryPrefix,
+		done:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+
+	if res.prefix == "" {
+		res.prefix = defaultPrefix
+	}
+
+	if res.discoveryPrefix == "" {
+		res.discoveryPrefix = defaultDiscoveryPrefix
+	}
+
+	res.options = Options{
+		Address:  c.Broker,
+		ClientID: c.ClientID,
+		Username: c.Username,
+		Password: c.Password,
+		Will: &Message{
+			Topic:   res.availabilityTopic(),
+			Payload: []byte(offline),
+			Retain:  true,
+		},
+	}
+
+	if res.options.ClientID == "" {
+		res.options.ClientID = defaultClientID
+	}
+
+	return res
+}
+
+func (b *Bridge) availabilityTopic() string {
+	return b.prefix + "/availability"
+}
+
+func (b *Bridge) zoneTopic(zone, topic string) string {
+	return b.prefix + "/" + zone + "/" + topic
+}
+
+func (b *Bridge) discoveryTopic(zone string, d discovered) string {
+	return b.discoveryPrefix + "/" + d.component + "/" + b.options.ClientID + "_" + zone + "/" + d.object + "/config"
+}
+
+// Run connects to the broker and reconnects whenever the connection drops, until the bridge is closed
+func (b *Bridge) Run() {
+	defer close(b.stopped)
+
+	delay := minReconnectDelay
+	for {
+		started := time.Now()
+		err := b.session()
+		if err == nil {
+			return
+		}
+
+		if time.Since(started) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+
+		log.Printf("MQTT bridge disconnected: %v, reconnecting in %s", err, delay)
+		select {
+		case <-time.After(delay):
+		case <-b.done:
+			return
+		}
+
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// Close marks the bridge offline, disconnects and waits for Run to return
+func (b *Bridge) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+
+	<-b.stopped
+}
+
+// session runs a single connection to the broker, nil is returned once the bridge is closed
+func (b *Bridge) session() error {
+	commands := make(chan Message, pendingCommands)
+	client, err := b.dial(b.options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Subscribe(b.prefix+"/+/cmd/+", func(m Message) {
+		select {
+		case commands <- m:
+		default:
+			log.Printf("Dropped the MQTT command %s, too many commands are pending", m.Topic)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := client.Publish(Message{Topic: b.availabilityTopic(), Payload: []byte(online), Retain: true}); err != nil {
+		return err
+	}
+
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		for {
+			select {
+			case m := <-commands:
+				b.command(client, m)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	created, unsubscribeZones := b.backend.SubscribeZones()
+	defer unsubscribeZones()
+
+	statuses := make(chan zoneStatus)
+	removed := make(chan string)
+	followers := make(map[string]func())
+	defer func() {
+		for _, stop := range followers {
+			stop()
+		}
+	}()
+
+	published := make(map[string]string)
+	publish := func(topic, payload string) error {
+		if last, ok := published[topic]; ok && last == payload {
+			return nil
+		}
+
+		published[topic] = payload
+		return client.Publish(Message{Topic: topic, Payload: []byte(payload), Retain: true})
+	}
+
+	follow := func(zone string) error {
+		if _, ok := followers[zone]; ok {
+			return nil
+		}
+
+		ch, stop := b.backend.Follow(zone)
+		followers[zone] = stop
+		go func() {
+			for s := range ch {
+				select {
+				case statuses <- zoneStatus{zone: zone, status: s}:
+				case <-quit:
+					return
+				}
+			}
+
+			select {
+			case removed <- zone:
+			case <-quit:
+			}
+		}()
+
+		if err := b.publishDiscovery(client, zone); err != nil {
+			return err
+		}
+
+		status, err := b.backend.Status(zone)
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+
+		return b.publishStatus(publish, zoneStatus{zone: zone, status: status})
+	}
+
+	for _, zone := range b.backend.Zones() {
+		if err := follow(zone); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var err error
+		select {
+		case s := <-statuses:
+			err = b.publishStatus(publish, s)
+		case zone, ok := <-created:
+			if !ok {
+				created = nil
+				continue
+			}
+
+			err = follow(zone)
+		case zone := <-removed:
+			if stop, ok := followers[zone]; ok {
+				stop()
+				delete(followers, zone)
+			}
+
+			err = b.clearZone(client, zone, published)
+		case <-client.Done():
+			return client.Err()
+		case <-b.done:
+			return client.Publish(Message{Topic: b.availabilityTopic(), Payload: []byte(offline), Retain: true})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// haState maps the state of the player to the states of a Home Assistant media player
+func haState(status *player.VlcStatus) string {
+	switch {
+	case status == nil:
+		return "idle"
+	case models.IsActiveState(status.State):
+		return "playing"
+	case status.State == models.StatePaused:
+		return "paused"
+	default:
+		return "idle"
+	}
+}
+
+func (b *Bridge) publishStatus(publish func(topic, payload string) error, s zoneStatus) error {
+	status, err := json.Marshal(s.status)
+	if err != nil {
+		return err
+	}
+
+	title, volume := "", 0
+	if s.status != nil {
+		title, volume = s.status.Name, s.status.Volume
+	}
+
+	topics := []struct {
+		topic, payload string
+	}{
+		{"status", string(status)},
+		{"state", haState(s.status)},
+		{"media_title", title},
+		{"volume_level", strconv.FormatFloat(float64(volume)/100, 'f', -1, 64)},
+	}
+
+	for _, t := range topics {
+		if err := publish(b.zoneTopic(s.zone, t.topic), t.payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+// entities returns the Home Assistant entities of a zone
+func (b *Bridge) entities(zone string) []discovered {
+	id := b.options.ClientID + "_" + zone
+	d := device{
+		Identifiers:  []string{id},
+		Name:         "audiotic " + zone,
+		Manufacturer: "audiotic",
+		Model:        "zone",
+	}
+
+	res := []discovered{
+		{"sensor", "state", entity{Name: "State", StateTopic: "~/state", AttributesTopic: "~/status", Icon: "mdi:speaker"}},
+		{"sensor", "media_title", entity{Name: "Title", StateTopic: "~/media_title", Icon: "mdi:music"}},
+		{"number", "volume", entity{
+			Name: "Volume", StateTopic: "~/volume_level", CommandTopic: "~/cmd/" + commandVolumeLevel,
+			Min: float(0), Max: float(1), Step: 0.01, Icon: "mdi:volume-high",
+		}},
+		{"button", "play", entity{Name: "Play", CommandTopic: "~/cmd/" + commandResume, Icon: "mdi:play"}},
+		{"button", "pause", entity{Name: "Pause", CommandTopic: "~/cmd/" + commandPause, Icon: "mdi:pause"}},
+		{"button", "stop", entity{Name: "Stop", CommandTopic: "~/cmd/" + commandStop, Icon: "mdi:stop"}},
+		{"button", "next", entity{Name: "Next", CommandTopic: "~/cmd/" + commandNext, Icon: "mdi:skip-next"}},
+		{"text", "play_url", entity{Name: "Play url", CommandTopic: "~/cmd/" + commandPlayURL, Icon: "mdi:link"}},
+	}
+
+	for i := range res {
+		e := &res[i].entity
+		e.UniqueID = id + "_" + res[i].object
+		e.BaseTopic = b.prefix + "/" + zone
+		e.AvailabilityTopic, e.PayloadAvailable, e.PayloadNotAvailable = b.availabilityTopic(), online, offline
+		e.Device = d
+	}
+
+	return res
+}
+
+func (b *Bridge) publishDiscovery(client conn, zone string) error {
+	for _, d := range b.entities(zone) {
+		payload, err := json.Marshal(d.entity)
+		if err != nil {
+			return err
+		}
+
+		if err := client.Publish(Message{Topic: b.discoveryTopic(zone, d), Payload: payload, Retain: true}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clearZone removes the retained messages of a removed zone, so Home Assistant removes its entities too
+func (b *Bridge) clearZone(client conn, zone string, published map[string]string) error {
+	var topics []string
+	for _, d := range b.entities(zone) {
+		topics = append(topics, b.discoveryTopic(zone, d))
+	}
+
+	for _, t := range []string{"status", "state", "media_title", "volume_level"} {
+		topic := b.zoneTopic(zone, t)
+		delete(published, topic)
+		topics = append(topics, topic)
+	}
+
+	for _, topic := range topics {
+		if err := client.Publish(Message{Topic: topic, Retain: true}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// command runs a command published to <prefix>/<zone>/cmd/<command>, failures are published to <prefix>/<zone>/error
+func (b *Bridge) command(client conn, m Message) {
+	parts := strings.Split(strings.TrimPrefix(m.Topic, b.prefix+"/"), "/")
+	if len(parts) != 3 || parts[1] != "cmd" {
+		return
+	}
+
+	zone, command := parts[0], parts[2]
+	err := b.run(zone, command, strings.TrimSpace(string(m.Payload)))
+	if err == nil {
+		return
+	}
+
+	payload, marshalErr := json.Marshal(api.ToError(err))
+	if marshalErr != nil {
+		log.Println(marshalErr)
+		return
+	}
+
+	if err := client.Publish(Message{Topic: b.zoneTopic(zone, "error"), Payload: payload}); err != nil {
+		log.Println(err)
+	}
+}
+
+func invalidPayload(command, payload string) error {
+	return &api.Error{
+		Code:    api.CodeInvalidArgument,
+		Message: fmt.Sprintf("Invalid payload for %s - %s", command, payload),
+		Details: map[string]interface{}{"command": command},
+	}
+}
+
+func (b *Bridge) run(zone, command, payload string) error {
+	switch command {
+	case commandPlay, commandPlayURL:
+		if payload == "" && command == commandPlay {
+			return b.backend.Resume(zone)
+		}
+
+		provider, id, err := models.ParseTrack(payload)
+		if err != nil {
+			return invalidPayload(command, payload)
+		}
+
+		return b.backend.Play(zone, provider, id)
+	case commandPause:
+		return b.backend.Pause(zone)
+	case commandResume:
+		return b.backend.Resume(zone)
+	case commandStop:
+		return b.backend.Stop(zone)
+	case commandNext:
+		return b.backend.Next(zone)
+	case commandSeek:
+		seconds, err := strconv.ParseFloat(payload, 64)
+		if err != nil {
+			return invalidPayload(command, payload)
+		}
+
+		return b.backend.Seek(zone, int(seconds))
+	case commandVolume:
+		volume, err := strconv.Atoi(payload)
+		if err != nil {
+			return invalidPayload(command, payload)
+		}
+
+		return b.backend.Volume(zone, volume)
+	case commandVolumeLevel:
+		level, err := strconv.ParseFloat(payload, 64)
+		if err != nil || level < 0 || level > 1 {
+			return invalidPayload(command, payload)
+		}
+
+		return b.backend.Volume(zone, int(math.Floor(level*100+0.5)))
+	default:
+		return &api.Error{
+			Code:    api.CodeInvalidArgument,
+			Message: fmt.Sprintf("Unknown command - %s", command),
+			Details: map[string]interface{}{"command": command},
+		}
+	}
+}
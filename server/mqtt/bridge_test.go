@@ -0,0 +1,560 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/player"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend records the commands and lets the tests push statuses and zone changes
+type fakeBackend struct {
+	mutex    sync.Mutex
+	zones    []string
+	commands []string
+	statuses map[string]chan *player.VlcStatus
+	created  chan string
+}
+
+func newFakeBackend(zones ...string) *fakeBackend {
+	b := &fakeBackend{
+		zones:    zones,
+		statuses: make(map[string]chan *player.VlcStatus),
+		created:  make(chan string, 1),
+	}
+
+	for _, z := range zones {
+		b.statuses[z] = make(chan *player.VlcStatus, 1)
+	}
+
+	return b
+}
+
+func (f *fakeBackend) record(format string, args ...interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.commands = append(f.commands, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (f *fakeBackend) recorded() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.commands...)
+}
+
+func (f *fakeBackend) create(zone string) {
+	f.mutex.Lock()
+	f.statuses[zone] = make(chan *player.VlcStatus, 1)
+	f.mutex.Unlock()
+
+	f.created <- zone
+}
+
+func (f *fakeBackend) remove(zone string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	close(f.statuses[zone])
+}
+
+func (f *fakeBackend) push(zone string, s *player.VlcStatus) {
+	f.mutex.Lock()
+	ch := f.statuses[zone]
+	f.mutex.Unlock()
+
+	ch <- s
+}
+
+func (f *fakeBackend) Zones() []string {
+	return f.zones
+}
+
+func (f *fakeBackend) SubscribeZones() (<-chan string, func()) {
+	return f.created, func() {}
+}
+
+func (f *fakeBackend) Follow(zone string) (<-chan *player.VlcStatus, func()) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.statuses[zone], func() {}
+}
+
+func (f *fakeBackend) Status(zone string) (*player.VlcStatus, error) {
+	return &player.VlcStatus{Zone: zone, State: "stopped", Volume: 100}, nil
+}
+
+func (f *fakeBackend) Play(zone, provider, id string) error {
+	return f.record("%s play %s/%s", zone, provider, id)
+}
+
+func (f *fakeBackend) Pause(zone string) error {
+	return f.record("%s pause", zone)
+}
+
+func (f *fakeBackend) Resume(zone string) error {
+	return f.record("%s resume", zone)
+}
+
+func (f *fakeBackend) Stop(zone string) error {
+	return f.record("%s stop", zone)
+}
+
+func (f *fakeBackend) Next(zone string) error {
+	return f.record("%s next", zone)
+}
+
+func (f *fakeBackend) Seek(zone string, time int) error {
+	return f.record("%s seek %d", zone, time)
+}
+
+func (f *fakeBackend) Volume(zone string, volume int) error {
+	return f.record("%s volume %d", zone, volume)
+}
+
+// memoryBroker routes the messages between the connections in memory, it keeps the retained messages and
+// publishes the wills of the dropped connections like a broker does
+type memoryBroker struct {
+	mutex    sync.Mutex
+	retained map[string]Message
+	conns    map[*memoryConn]struct{}
+}
+
+type subscription struct {
+	filter  string
+	handler func(Message)
+}
+
+type memoryConn struct {
+	broker *memoryBroker
+	will   *Message
+
+	mutex         sync.Mutex
+	subscriptions []subscription
+	err           error
+	done          chan struct{}
+	doneOnce      sync.Once
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{
+		retained: make(map[string]Message),
+		conns:    make(map[*memoryConn]struct{}),
+	}
+}
+
+func (b *memoryBroker) dial(o Options) (conn, error) {
+	c := &memoryConn{broker: b, will: o.Will, done: make(chan struct{})}
+
+	b.mutex.Lock()
+	b.conns[c] = struct{}{}
+	b.mutex.Unlock()
+
+	return c, nil
+}
+
+func (b *memoryBroker) retainedMessage(topic string) (Message, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	m, ok := b.retained[topic]
+	return m, ok
+}
+
+// drop loses the connections with a will, i.e. the ones of the bridge
+func (b *memoryBroker) drop() {
+	b.mutex.Lock()
+	var dropped []*memoryConn
+	for c := range b.conns {
+		if c.will != nil {
+			dropped = append(dropped, c)
+		}
+	}
+	b.mutex.Unlock()
+
+	for _, c := range dropped {
+		c.disconnect(errors.New("connection lost"))
+		b.route(*c.will)
+	}
+}
+
+// matchTopic matches a topic against a filter with the + and # wildcards
+func matchTopic(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+
+	return len(f) == len(t)
+}
+
+func (b *memoryBroker) route(m Message) {
+	b.mutex.Lock()
+	if m.Retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+
+	var handlers []func(Message)
+	for c := range b.conns {
+		c.mutex.Lock()
+		for _, s := range c.subscriptions {
+			if matchTopic(s.filter, m.Topic) {
+				handlers = append(handlers, s.handler)
+			}
+		}
+		c.mutex.Unlock()
+	}
+	b.mutex.Unlock()
+
+	// the retain flag is set only on the messages sent because of a subscription
+	m.Retain = false
+	for _, h := range handlers {
+		h(m)
+	}
+}
+
+func (c *memoryConn) disconnect(err error) {
+	c.broker.mutex.Lock()
+	delete(c.broker.conns, c)
+	c.broker.mutex.Unlock()
+
+	c.doneOnce.Do(func() {
+		c.mutex.Lock()
+		c.err = err
+		c.mutex.Unlock()
+
+		close(c.done)
+	})
+}
+
+func (c *memoryConn) Publish(m Message) error {
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+
+	c.broker.route(m)
+	return nil
+}
+
+func (c *memoryConn) Subscribe(filter string, handler func(Message)) error {
+	c.mutex.Lock()
+	c.subscriptions = append(c.subscriptions, subscription{filter, handler})
+	c.mutex.Unlock()
+
+	c.broker.mutex.Lock()
+	var retained []Message
+	for topic, m := range c.broker.retained {
+		if matchTopic(filter, topic) {
+			retained = append(retained, m)
+		}
+	}
+	c.broker.mutex.Unlock()
+
+	for _, m := range retained {
+		handler(m)
+	}
+
+	return nil
+}
+
+func (c *memoryConn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *memoryConn) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
+}
+
+func (c *memoryConn) Close() {
+	c.disconnect(ErrClosed)
+}
+
+// observer is a client of the broker following the topics of a filter
+type observer struct {
+	conn
+	messages chan Message
+}
+
+func observe(t *testing.T, dial dialer, o Options, filter string) *observer {
+	c, err := dial(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &observer{conn: c, messages: make(chan Message, 256)}
+	if err := c.Subscribe(filter, func(m Message) { res.messages <- m }); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+// receive waits for a message on the topic, the messages of the other topics are skipped
+func (o *observer) receive(t *testing.T, topic string) Message {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-o.messages:
+			if m.Topic == topic {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("Expected a message on %s", topic)
+		}
+	}
+}
+
+// expect waits for a payload on the topic
+func (o *observer) expect(t *testing.T, topic, payload string) {
+	for {
+		if m := o.receive(t, topic); string(m.Payload) == payload {
+			return
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	for i := 0; i < 200; i++ {
+		if done() {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func startBridge(t *testing.T, b *memoryBroker, backend Backend) *Bridge {
+	bridge := NewBridge(backend, config.MQTTConfig{})
+	bridge.dial = b.dial
+	go bridge.Run()
+
+	waitFor(t, "the bridge to connect", func() bool {
+		m, ok := b.retainedMessage("audiotic/availability")
+		return ok && string(m.Payload) == online
+	})
+
+	return bridge
+}
+
+func TestBrokerURL(t *testing.T) {
+	cases := map[string]string{
+		"":                       "tcp://localhost:1883",
+		"broker":                 "tcp://broker:1883",
+		"broker:1884":            "tcp://broker:1884",
+		"mqtt://broker":          "tcp://broker:1883",
+		"mqtts://broker":         "ssl://broker:8883",
+		"tls://broker:8884":      "ssl://broker:8884",
+		"tcp://192.168.1.2:1883": "tcp://192.168.1.2:1883",
+	}
+
+	for address, expected := range cases {
+		if u, err := brokerURL(address); err != nil || u != expected {
+			t.Errorf("Expected %s for %q, got %s %v", expected, address, u, err)
+		}
+	}
+
+	if _, err := brokerURL("http://broker"); err == nil {
+		t.Error("Expected an unknown scheme to fail")
+	}
+}
+
+func TestBridgePublishesDiscoveryAndStatus(t *testing.T) {
+	b := newMemoryBroker()
+	backend := newFakeBackend("default", "kitchen")
+	bridge := startBridge(t, b, backend)
+	defer bridge.Close()
+
+	for _, zone := range backend.zones {
+		m, ok := b.retainedMessage("homeassistant/number/audiotic_" + zone + "/volume/config")
+		if !ok {
+			t.Fatalf("Expected the discovery config of %s", zone)
+		}
+
+		var e map[string]interface{}
+		if err := json.Unmarshal(m.Payload, &e); err != nil {
+			t.Fatal(err)
+		}
+
+		if e["unique_id"] != "audiotic_"+zone+"_volume" || e["~"] != "audiotic/"+zone || e["command_topic"] != "~/cmd/volume_level" ||
+			e["min"] != 0.0 || e["max"] != 1.0 || e["availability_topic"] != "audiotic/availability" {
+			t.Fatalf("Expected the topics of %s, got %+v", zone, e)
+		}
+
+		for _, topic := range []string{"sensor/audiotic_" + zone + "/state", "button/audiotic_" + zone + "/pause", "text/audiotic_" + zone + "/play_url"} {
+			if _, ok := b.retainedMessage("homeassistant/" + topic + "/config"); !ok {
+				t.Fatalf("Expected the discovery config %s", topic)
+			}
+		}
+	}
+
+	if m, _ := b.retainedMessage("audiotic/default/state"); string(m.Payload) != "idle" {
+		t.Fatalf("Expected the zone to be idle, got %s", m.Payload)
+	}
+
+	o := observe(t, b.dial, Options{ClientID: "observer"}, "audiotic/default/#")
+	defer o.Close()
+
+	backend.push("default", &player.VlcStatus{Zone: "default", State: "playing", Name: "Song", Volume: 80})
+	o.expect(t, "audiotic/default/state", "playing")
+	o.expect(t, "audiotic/default/media_title", "Song")
+	o.expect(t, "audiotic/default/volume_level", "0.8")
+
+	m, _ := b.retainedMessage("audiotic/default/status")
+	var status player.VlcStatus
+	if err := json.Unmarshal(m.Payload, &status); err != nil || status.Name != "Song" {
+		t.Fatalf("Expected the retained status, got %s %v", m.Payload, err)
+	}
+}
+
+func TestBridgeRunsTheCommands(t *testing.T) {
+	b := newMemoryBroker()
+	backend := newFakeBackend("default")
+	bridge := startBridge(t, b, backend)
+	defer bridge.Close()
+
+	o := observe(t, b.dial, Options{ClientID: "observer"}, "audiotic/+/error")
+	defer o.Close()
+
+	for _, c := range []struct {
+		command, payload string
+	}{
+		{"pause", ""},
+		{"play", ""},
+		{"play_url", "https://www.youtube.com/watch?v=abc"},
+		{"play", "youtube/def"},
+		{"seek", "42.5"},
+		{"volume", "150"},
+		{"volume_level", "0.25"},
+		{"next", ""},
+		{"stop", ""},
+	} {
+		if err := o.Publish(Message{Topic: "audiotic/default/cmd/" + c.command, Payload: []byte(c.payload)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"default pause",
+		"default resume",
+		"default play youtube/abc",
+		"default play youtube/def",
+		"default seek 42",
+		"default volume 150",
+		"default volume 25",
+		"default next",
+		"default stop",
+	}
+
+	waitFor(t, "the commands", func() bool { return len(backend.recorded()) == len(expected) })
+	for i, c := range backend.recorded() {
+		if c != expected[i] {
+			t.Fatalf("Expected %s, got %s", expected[i], c)
+		}
+	}
+
+	o.Publish(Message{Topic: "audiotic/default/cmd/volume_level", Payload: []byte("2")})
+	o.expect(t, "audiotic/default/error", `{"code":"invalid_argument","message":"Invalid payload for volume_level - 2","details":{"command":"volume_level"}}`)
+}
+
+func TestBridgeFollowsTheZones(t *testing.T) {
+	b := newMemoryBroker()
+	backend := newFakeBackend("default")
+	bridge := startBridge(t, b, backend)
+	defer bridge.Close()
+
+	backend.create("kitchen")
+	waitFor(t, "the kitchen to be announced", func() bool {
+		_, ok := b.retainedMessage("homeassistant/sensor/audiotic_kitchen/state/config")
+		return ok
+	})
+
+	backend.remove("kitchen")
+	waitFor(t, "the kitchen to be removed", func() bool {
+		_, config := b.retainedMessage("homeassistant/sensor/audiotic_kitchen/state/config")
+		_, button := b.retainedMessage("homeassistant/button/audiotic_kitchen/play/config")
+		_, state := b.retainedMessage("audiotic/kitchen/state")
+		return !config && !button && !state
+	})
+}
+
+func TestBridgeReconnectsAndGoesOfflineOnClose(t *testing.T) {
+	b := newMemoryBroker()
+	backend := newFakeBackend("default")
+	bridge := startBridge(t, b, backend)
+
+	o := observe(t, b.dial, Options{ClientID: "observer"}, "audiotic/availability")
+	defer o.Close()
+	o.expect(t, "audiotic/availability", online)
+
+	// the will marks the bridge offline when the connection drops, it's online again once reconnected
+	b.drop()
+	o.expect(t, "audiotic/availability", offline)
+	o.expect(t, "audiotic/availability", online)
+
+	bridge.Close()
+	o.expect(t, "audiotic/availability", offline)
+}
+
+// TestBridgeWithBroker runs the bridge through paho against a real broker, e.g. a local Mosquitto,
+// when AUDIOTIC_MQTT_TEST_BROKER is set to its address
+func TestBridgeWithBroker(t *testing.T) {
+	address := os.Getenv("AUDIOTIC_MQTT_TEST_BROKER")
+	if address == "" {
+		t.Skip("AUDIOTIC_MQTT_TEST_BROKER isn't set")
+	}
+
+	c := config.MQTTConfig{Broker: address, ClientID: "audiotic-test", Prefix: "audiotic-test", DiscoveryPrefix: "homeassistant-test"}
+	o := observe(t, dialPaho, Options{Address: address, ClientID: "audiotic-test-observer"}, "#")
+	defer o.Close()
+
+	backend := newFakeBackend("default")
+	bridge := NewBridge(backend, c)
+	go bridge.Run()
+
+	// the retained messages are removed afterwards, so the next run starts clean
+	defer bridge.clearZone(o.conn, "default", make(map[string]string))
+
+	o.expect(t, "audiotic-test/availability", online)
+	o.receive(t, "homeassistant-test/number/audiotic-test_default/volume/config")
+	o.expect(t, "audiotic-test/default/state", "idle")
+
+	backend.push("default", &player.VlcStatus{Zone: "default", State: "playing", Name: "Song", Volume: 80})
+	o.expect(t, "audiotic-test/default/media_title", "Song")
+
+	if err := o.Publish(Message{Topic: "audiotic-test/default/cmd/volume_level", Payload: []byte("0.5")}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the command", func() bool { return len(backend.recorded()) == 1 })
+	if c := backend.recorded()[0]; c != "default volume 50" {
+		t.Fatalf("Expected the volume to be set, got %s", c)
+	}
+
+	bridge.Close()
+	o.expect(t, "audiotic-test/availability", offline)
+}
@@ -0,0 +1,184 @@
+package mqtt
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-errors/errors"
+)
+
+const (
+	keepAlive   = 30 * time.Second
+	dialTimeout = 10 * time.Second
+	ackTimeout  = 10 * time.Second
+	// quiesce is how long a disconnect waits for the pending messages, in milliseconds
+	quiesce = 250
+)
+
+var ErrClosed = errors.New("MQTT connection closed")
+
+// Message is a message published to or received from the broker, they're sent with QoS 0
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// Options describe the connection to a broker
+type Options struct {
+	// Address is host:port or a url, tcp:// and mqtt:// connect in plain text, ssl://, tls:// and mqtts:// with tls
+	Address  string
+	ClientID string
+	Username string
+	Password string
+	// Will is published by the broker when the connection drops without a disconnect
+	Will *Message
+}
+
+// conn is a connection to the broker, it's a paho client outside of the tests
+type conn interface {
+	Publish(m Message) error
+	// Subscribe subscribes to a topic filter, the handler receives the messages one at a time
+	Subscribe(filter string, handler func(Message)) error
+	// Done is closed once the connection is closed or lost
+	Done() <-chan struct{}
+	// Err returns why the connection was lost, ErrClosed after Close
+	Err() error
+	// Close disconnects gracefully, the broker doesn't publish the will then
+	Close()
+}
+
+type dialer func(o Options) (conn, error)
+
+// brokerURL returns the paho url of an address, the port defaults to 1883 or 8883 with tls
+func brokerURL(address string) (string, error) {
+	scheme, host := "tcp", address
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return "", err
+		}
+
+		switch u.Scheme {
+		case "tcp", "mqtt":
+		case "ssl", "tls", "mqtts":
+			scheme = "ssl"
+		default:
+			return "", fmt.Errorf("Unknown MQTT scheme %s", u.Scheme)
+		}
+
+		host = u.Host
+	}
+
+	port := "1883"
+	if scheme == "ssl" {
+		port = "8883"
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if host == "" {
+			host = "localhost"
+		}
+
+		host = net.JoinHostPort(host, port)
+	}
+
+	return scheme + "://" + host, nil
+}
+
+// pahoConn is a connection of the paho client, it doesn't reconnect on its own, the bridge does with a fresh session
+type pahoConn struct {
+	client paho.Client
+
+	mutex    sync.Mutex
+	err      error
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func dialPaho(o Options) (conn, error) {
+	address, err := brokerURL(o.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &pahoConn{done: make(chan struct{})}
+	opts := paho.NewClientOptions().
+		AddBroker(address).
+		SetClientID(o.ClientID).
+		SetUsername(o.Username).
+		SetPassword(o.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetKeepAlive(keepAlive).
+		SetConnectTimeout(dialTimeout).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			c.fail(err)
+		})
+
+	if o.Will != nil {
+		opts.SetBinaryWill(o.Will.Topic, o.Will.Payload, 0, o.Will.Retain)
+	}
+
+	c.client = paho.NewClient(opts)
+	if err := wait(c.client.Connect()); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func wait(t paho.Token) error {
+	if !t.WaitTimeout(ackTimeout) {
+		return errors.New("MQTT broker didn't respond in time")
+	}
+
+	return t.Error()
+}
+
+func (c *pahoConn) fail(err error) {
+	c.doneOnce.Do(func() {
+		c.mutex.Lock()
+		c.err = err
+		c.mutex.Unlock()
+
+		close(c.done)
+	})
+}
+
+func (c *pahoConn) Publish(m Message) error {
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+
+	return wait(c.client.Publish(m.Topic, 0, m.Retain, m.Payload))
+}
+
+func (c *pahoConn) Subscribe(filter string, handler func(Message)) error {
+	return wait(c.client.Subscribe(filter, 0, func(_ paho.Client, m paho.Message) {
+		handler(Message{Topic: m.Topic(), Payload: m.Payload(), Retain: m.Retained()})
+	}))
+}
+
+func (c *pahoConn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *pahoConn) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
+}
+
+func (c *pahoConn) Close() {
+	c.client.Disconnect(quiesce)
+	c.fail(ErrClosed)
+}
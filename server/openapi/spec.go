@@ -0,0 +1,1057 @@
+package openapi
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/cache"
+	"gngeorgiev/audiotic/server/controlProtocol"
+	"gngeorgiev/audiotic/server/equalizer"
+	"gngeorgiev/audiotic/server/export"
+	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/lyrics"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/profiles"
+	"gngeorgiev/audiotic/server/scheduler"
+	"gngeorgiev/audiotic/server/webhooks"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type Schema map[string]interface{}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+	Schema   Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+
+	// SocketMessages describes the messages pushed over the sockjs session or event stream opened on the path
+	SocketMessages Schema `json:"x-sockjs-messages,omitempty"`
+}
+
+// PathItem maps lower case http methods to operations
+type PathItem map[string]*Operation
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// SecurityRequirement maps the name of a security scheme to its scopes
+type SecurityRequirement map[string][]string
+
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security"`
+}
+
+// Operation returns the operation documented for a method and a gin style path, e.g. /player/play/:provider/:id
+func (d *Document) Operation(method, ginPath string) *Operation {
+	item, ok := d.Paths[FromGinPath(ginPath)]
+	if !ok {
+		return nil
+	}
+
+	return item[strings.ToLower(method)]
+}
+
+func (d *Document) add(method, path string, o *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+
+	item[strings.ToLower(method)] = o
+}
+
+// FromGinPath converts gin's :param and *param segments to openapi's {param}
+func FromGinPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func ref(name string) Schema {
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(s Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+func pathParam(name string) Parameter {
+	return Parameter{Name: name, In: "path", Required: true, Schema: Schema{"type": "string"}}
+}
+
+func intPathParam(name string) Parameter {
+	return Parameter{Name: name, In: "path", Required: true, Schema: Schema{"type": "integer"}}
+}
+
+func queryParam(name string, required bool) Parameter {
+	return Parameter{Name: name, In: "query", Required: required, Schema: Schema{"type": "string"}}
+}
+
+func jsonBody(s Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: jsonContent(s)}
+}
+
+func ok(description string, s Schema) map[string]Response {
+	r := Response{Description: description}
+	if s != nil {
+		r.Content = jsonContent(s)
+	}
+
+	return map[string]Response{strconv.Itoa(http.StatusOK): r}
+}
+
+func noContent() map[string]Response {
+	return map[string]Response{strconv.Itoa(http.StatusNoContent): {Description: "Done"}}
+}
+
+func withErrors(responses map[string]Response, statuses ...int) map[string]Response {
+	for _, s := range statuses {
+		responses[strconv.Itoa(s)] = Response{
+			Description: http.StatusText(s),
+			Content:     jsonContent(ref("ErrorResponse")),
+		}
+	}
+
+	return responses
+}
+
+func withStatuses(responses map[string]Response, statuses ...int) map[string]Response {
+	for _, s := range statuses {
+		responses[strconv.Itoa(s)] = Response{Description: http.StatusText(s)}
+	}
+
+	return responses
+}
+
+func tracksSchema() Schema {
+	return Schema{"type": "array", "items": ref("Track")}
+}
+
+// Spec builds the document for every route served by the api server
+func Spec() *Document {
+	d := &Document{
+		OpenAPI: "3.0.0",
+		Info: Info{
+			Title: "audiotic",
+			Description: "Control the audiotic player, search the providers and browse the history. " +
+				"When auth is enabled the player, queue and history routes require a listener role to read, " +
+				"a controller role to change the playback and the user routes require an admin. " +
+				"Auth is disabled by default, then every caller who can reach the server is treated as an admin",
+			Version: "2.0.0",
+		},
+		Paths: map[string]PathItem{},
+		Security: []SecurityRequirement{
+			{"bearer": {}},
+			{"basic": {}},
+			{"queryToken": {}},
+		},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer":     {Type: "http", Scheme: "bearer"},
+				"basic":      {Type: "http", Scheme: "basic"},
+				"queryToken": {Type: "apiKey", In: "query", Name: "token"},
+			},
+			Schemas: map[string]Schema{
+				"Track":         SchemaOf(models.Track{}),
+				"VlcStatus":     SchemaOf(player.VlcStatus{}),
+				"Error":         SchemaOf(api.Error{}),
+				"ErrorResponse": {"type": "object", "properties": map[string]Schema{"error": ref("Error")}},
+				"PlayRequest": {
+					"type":       "object",
+					"required":   []string{"provider", "id"},
+					"properties": map[string]Schema{"provider": {"type": "string"}, "id": {"type": "string"}},
+				},
+				"SeekRequest": {
+					"type":       "object",
+					"required":   []string{"time"},
+					"properties": map[string]Schema{"time": {"type": "integer", "minimum": 0}},
+				},
+				"ControlRequest": {
+					"type":     "object",
+					"required": []string{"method"},
+					"properties": map[string]Schema{
+						"id":     {"description": "Copied to the response, requests without id get no response"},
+						"method": {"type": "string", "enum": controlProtocol.Methods()},
+						"params": {"type": "object"},
+					},
+				},
+				"ControlResponse": SchemaOf(controlProtocol.Response{}),
+				"Modes":           SchemaOf(api.Modes{}),
+				"HubMetrics":      SchemaOf(hub.Metrics{}),
+				"User":            SchemaOf(auth.User{}),
+				"Play":            SchemaOf(history.Play{}),
+				"Favorite":        SchemaOf(profiles.Favorite{}),
+				"Stats":           SchemaOf(profiles.Stats{}),
+				"Profile": {
+					"type": "object",
+					"properties": map[string]Schema{
+						"name":         {"type": "string"},
+						"autoplaySeed": {"type": "string", "enum": profiles.Seeds()},
+					},
+				},
+				"Token": SchemaOf(auth.Token{}),
+				"CreateUserRequest": {
+					"type":     "object",
+					"required": []string{"name", "role"},
+					"properties": map[string]Schema{
+						"name":     {"type": "string"},
+						"password": {"type": "string", "description": "Users without a password can use only tokens"},
+						"role":     {"type": "string", "enum": []string{auth.RoleAdmin, auth.RoleController, auth.RoleListener}},
+					},
+				},
+				"CreateTokenRequest": {
+					"type":       "object",
+					"properties": map[string]Schema{"name": {"type": "string"}},
+				},
+				"CreateTokenResponse": {
+					"type": "object",
+					"properties": map[string]Schema{
+						"token": {"type": "string", "description": "Shown only once, only its hash is stored"},
+						"info":  ref("Token"),
+					},
+				},
+				"Heartbeat": SchemaOf(controlProtocol.Heartbeat{}),
+				"ControlEvent": {
+					"type": "object",
+					"properties": map[string]Schema{
+						"type": {"type": "string", "enum": []string{
+							controlProtocol.TypeStatus,
+							controlProtocol.TypeQueue,
+							controlProtocol.TypeHistory,
+							controlProtocol.TypeError,
+							controlProtocol.TypeModes,
+							controlProtocol.TypeHeartbeat,
+						}},
+						"data": {"oneOf": []Schema{
+							ref("VlcStatus"),
+							tracksSchema(),
+							ref("Track"),
+							ref("Error"),
+							ref("Modes"),
+							ref("Heartbeat"),
+						}},
+					},
+				},
+				"Zone":         SchemaOf(api.ZoneInfo{}),
+				"AudioOutputs": SchemaOf(player.AudioOutputs{}),
+				"Equalizer":    SchemaOf(player.Equalizer{}),
+				"EqualizerChange": {
+					"type":        "object",
+					"description": "The gains of the preset or profile, if any, are overridden by preamp and bands",
+					"properties": map[string]Schema{
+						"preset":  {"type": "string"},
+						"profile": {"type": "string"},
+						"preamp":  {"type": "number", "minimum": api.MinEqualizerGain, "maximum": api.MaxEqualizerGain},
+						"bands": {"type": "array", "items": Schema{
+							"type": "number", "minimum": api.MinEqualizerGain, "maximum": api.MaxEqualizerGain,
+						}},
+					},
+				},
+				"EqualizerPresets": SchemaOf(api.EqualizerPresets{}),
+				"EqualizerProfile": SchemaOf(equalizer.Profile{}),
+				"Job":              SchemaOf(scheduler.Job{}),
+				"JobRequest": {
+					"type":        "object",
+					"description": "A sleep timer takes minutes or afterTrack, an alarm takes time, tracks and optionally days and volume",
+					"required":    []string{"kind"},
+					"properties": map[string]Schema{
+						"kind":       {"type": "string", "enum": []string{scheduler.KindSleep, scheduler.KindAlarm}},
+						"minutes":    {"type": "integer", "minimum": 1, "maximum": api.MaxSleepMinutes},
+						"afterTrack": {"type": "boolean", "description": "Stop at the end of the current track"},
+						"fade":       {"type": "integer", "minimum": 0, "maximum": api.MaxFade, "description": "Fade out or ramp up in seconds"},
+						"time":       {"type": "string", "pattern": "^[0-2][0-9]:[0-5][0-9]$", "description": "Local time of the alarm"},
+						"days":       {"type": "array", "items": Schema{"type": "string", "enum": scheduler.Days}, "description": "Every day when empty"},
+						"tracks": {"type": "array", "items": Schema{
+							"type":     "object",
+							"required": []string{"provider", "id"},
+							"properties": map[string]Schema{
+								"provider": {"type": "string"},
+								"id":       {"type": "string"},
+							},
+						}},
+						"volume": {"type": "integer", "minimum": api.MinVolume, "maximum": api.MaxVolume, "description": "0 keeps the volume of the zone"},
+					},
+				},
+				"Lyrics":     SchemaOf(lyrics.Lyrics{}),
+				"Webhook":    SchemaOf(webhooks.Webhook{}),
+				"Delivery":   SchemaOf(webhooks.Delivery{}),
+				"CacheStats": SchemaOf(cache.Stats{}),
+				"CacheEntry": SchemaOf(cache.Entry{}),
+				"CacheRequest": {
+					"type":     "object",
+					"required": []string{"provider", "id"},
+					"properties": map[string]Schema{
+						"provider": {"type": "string"},
+						"id":       {"type": "string"},
+						"pinned":   {"type": "boolean", "description": "Keep the track from being evicted"},
+					},
+				},
+				"WebhookRequest": {
+					"type":        "object",
+					"description": "Updates without a secret or enabled keep the current ones",
+					"required":    []string{"url"},
+					"properties": map[string]Schema{
+						"url":     {"type": "string", "format": "uri"},
+						"secret":  {"type": "string", "description": "Generated for new webhooks when empty"},
+						"events":  {"type": "array", "items": Schema{"type": "string", "enum": webhooks.Events}, "description": "All events when empty"},
+						"zone":    {"type": "string", "description": "All zones when empty"},
+						"enabled": {"type": "boolean"},
+					},
+				},
+				"NormalizationRequest": {
+					"type":       "object",
+					"required":   []string{"mode"},
+					"properties": map[string]Schema{"mode": {"type": "string", "enum": api.NormalizationModes()}},
+				},
+				"OutputRequest": {
+					"type":        "object",
+					"description": "At least one of output and device is required, the missing one is kept",
+					"properties": map[string]Schema{
+						"output": {"type": "string", "description": "Name of the libvlc output module, used from the next track"},
+						"device": {"type": "string", "description": "Id of the device, switched without stopping the playback"},
+					},
+				},
+				"CreateZoneRequest": {
+					"type":     "object",
+					"required": []string{"name"},
+					"properties": map[string]Schema{
+						"name":   {"type": "string", "pattern": "^[a-z0-9_-]+$"},
+						"volume": {"type": "integer", "minimum": api.MinVolume, "maximum": api.MaxVolume},
+					},
+				},
+				"RateRequest": {
+					"type":     "object",
+					"required": []string{"rate"},
+					"properties": map[string]Schema{
+						"rate":     {"type": "number", "minimum": api.MinRate, "maximum": api.MaxRate},
+						"remember": {"type": "boolean", "description": "Play the current track at this rate every time, remembering 1 forgets it"},
+					},
+				},
+				"FadeRequest": {
+					"type":     "object",
+					"required": []string{"length"},
+					"properties": map[string]Schema{
+						"length": {"type": "integer", "minimum": api.MinFadeLength, "maximum": api.MaxFadeLength, "description": "Milliseconds, 0 turns the fades off"},
+					},
+				},
+				"VolumeRequest": {
+					"type":     "object",
+					"required": []string{"volume"},
+					"properties": map[string]Schema{
+						"volume": {"type": "integer", "minimum": api.MinVolume, "maximum": api.MaxVolume},
+					},
+				},
+			},
+		},
+	}
+
+	d.add(http.MethodGet, "/openapi.json", &Operation{
+		OperationID: "getOpenApi",
+		Summary:     "This document",
+		Responses:   ok("The openapi document", Schema{"type": "object"}),
+	})
+	d.add(http.MethodGet, "/stream.ogg", &Operation{
+		OperationID: "getAudioStream",
+		Summary: "The playback of a zone as an endless ogg/opus stream when streaming is enabled, " +
+			"clients sending Icy-MetaData: 1 get the track names as ICY metadata every icy-metaint bytes",
+		Parameters: []Parameter{queryParam("zone", false)},
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusOK): {
+				Description: "Audio stream",
+				Content:     map[string]MediaType{"audio/ogg": {Schema: Schema{"type": "string", "format": "binary"}}},
+			},
+		}, http.StatusNotFound),
+	})
+
+	addV1Paths(d, "")
+	addV1Paths(d, "/api/v1")
+	addV2Paths(d, "/api/v2")
+
+	return d
+}
+
+func addV1Paths(d *Document, prefix string) {
+	id := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+
+		return "v1" + strings.ToUpper(name[:1]) + name[1:]
+	}
+
+	tags := []string{"v1"}
+	errors := func(r map[string]Response) map[string]Response {
+		return withStatuses(r, http.StatusBadRequest, http.StatusInternalServerError)
+	}
+
+	d.add(http.MethodGet, prefix+"/meta/autocomplete/{query}", &Operation{
+		OperationID: id("autocomplete"),
+		Summary:     "Autocomplete a search query",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("query")},
+		Responses:   errors(ok("Suggestions", Schema{"type": "array", "items": Schema{"type": "string"}})),
+	})
+	d.add(http.MethodGet, prefix+"/meta/search/{query}", &Operation{
+		OperationID: id("search"),
+		Summary:     "Search all providers",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("query")},
+		Responses:   errors(ok("Found tracks", tracksSchema())),
+	})
+	d.add(http.MethodGet, prefix+"/player/play/{provider}/{id}", &Operation{
+		OperationID: id("play"),
+		Summary:     "Resolve and play a track",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("provider"), pathParam("id")},
+		Responses:   errors(ok("Playing", nil)),
+	})
+
+	for _, action := range []string{"pause", "resume", "stop"} {
+		d.add(http.MethodGet, prefix+"/player/"+action, &Operation{
+			OperationID: id(action),
+			Summary:     strings.Title(action) + " the playback",
+			Tags:        tags,
+			Responses:   errors(ok("Done", nil)),
+		})
+	}
+
+	d.add(http.MethodGet, prefix+"/player/status", &Operation{
+		OperationID: id("status"),
+		Summary:     "Current player status",
+		Tags:        tags,
+		Responses:   errors(ok("Player status", ref("VlcStatus"))),
+	})
+	d.add(http.MethodGet, prefix+"/player/seek/{time}", &Operation{
+		OperationID: id("seek"),
+		Summary:     "Seek to a time in seconds",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("time")},
+		Responses:   errors(ok("Done", nil)),
+	})
+	d.add(http.MethodGet, prefix+"/player/volume/{volume}", &Operation{
+		OperationID: id("volume"),
+		Summary:     "Set the volume",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("volume")},
+		Responses:   errors(ok("Done", nil)),
+	})
+	d.add(http.MethodGet, prefix+"/player/updates/{info}", updatesOperation(id("updates"), tags))
+	d.add(http.MethodGet, prefix+"/player/events", eventsOperation(id("events"), tags))
+	d.add(http.MethodGet, prefix+"/history/get", &Operation{
+		OperationID: id("history"),
+		Summary:     "Room history, the tracks played by everyone, most recent first. The plays of a user are at /api/v2/me/history",
+		Tags:        tags,
+		Responses:   errors(ok("History", tracksSchema())),
+	})
+}
+
+func addV2Paths(d *Document, prefix string) {
+	tags := []string{"v2"}
+
+	d.add(http.MethodGet, prefix+"/meta/autocomplete", &Operation{
+		OperationID: "v2Autocomplete",
+		Summary:     "Autocomplete a search query",
+		Tags:        tags,
+		Parameters:  []Parameter{queryParam("q", false)},
+		Responses:   withErrors(ok("Suggestions", Schema{"type": "array", "items": Schema{"type": "string"}}), http.StatusInternalServerError),
+	})
+	d.add(http.MethodGet, prefix+"/meta/search", &Operation{
+		OperationID: "v2Search",
+		Summary:     "Search all providers",
+		Tags:        tags,
+		Parameters:  []Parameter{queryParam("q", true)},
+		Responses:   withErrors(ok("Found tracks", tracksSchema()), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	addZonePaths(d, prefix, tags, false)
+	addZonePaths(d, prefix+"/zones/{zone}", tags, true)
+	addZonesPaths(d, prefix, tags)
+	addEqualizerPaths(d, prefix, tags)
+	d.add(http.MethodGet, prefix+"/metrics/hubs", &Operation{
+		OperationID: "v2HubsMetrics",
+		Summary:     "Delivery metrics of the update hubs, slow subscribers are flagged",
+		Tags:        tags,
+		Responses:   ok("Hubs metrics", Schema{"type": "array", "items": ref("HubMetrics")}),
+	})
+	d.add(http.MethodGet, prefix+"/me", &Operation{
+		OperationID: "v2Me",
+		Summary:     "The authenticated user and its role",
+		Tags:        tags,
+		Responses: withErrors(ok("Current user", Schema{
+			"type": "object",
+			"properties": map[string]Schema{
+				"authEnabled": {"type": "boolean"},
+				"role":        {"type": "string"},
+				"user":        ref("User"),
+			},
+		}), http.StatusUnauthorized),
+	})
+	addUsersPaths(d, prefix, tags)
+	addWebhooksPaths(d, prefix, tags)
+	addCachePaths(d, prefix, tags)
+	d.add(http.MethodGet, prefix+"/tracks/{provider}/{id}/download", &Operation{
+		OperationID: "v2DownloadTrack",
+		Summary: "Download a track as a file tagged with its metadata and thumbnail, it's transcoded by ffmpeg to the format param. " +
+			"Without it the track keeps its format, it's sent untagged when ffmpeg isn't available or doesn't know the container",
+		Tags: tags,
+		Parameters: []Parameter{pathParam("provider"), pathParam("id"), {
+			Name: "format", In: "query", Schema: Schema{"type": "string", "enum": export.Formats},
+		}},
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusOK): {
+				Description: "Audio file",
+				Content:     map[string]MediaType{"audio/*": {Schema: Schema{"type": "string", "format": "binary"}}},
+			},
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+			http.StatusInternalServerError),
+	})
+	addProfilePaths(d, prefix, tags)
+	d.add(http.MethodGet, prefix+"/history", &Operation{
+		OperationID: "v2History",
+		Summary:     "Room history, the tracks played by everyone, most recent first. The plays of the caller are at /me/history",
+		Tags:        tags,
+		Responses:   withErrors(ok("History", tracksSchema()), http.StatusInternalServerError),
+	})
+	d.add(http.MethodDelete, prefix+"/history/{id}", &Operation{
+		OperationID: "v2DeleteHistory",
+		Summary:     "Remove a track from the history",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("id")},
+		Responses:   withErrors(noContent(), http.StatusNotFound, http.StatusInternalServerError),
+	})
+}
+
+// addZonePaths adds the player and queue paths of a zone, the zoned ones take the zone
+// as a path param, the others control the default zone
+func addZonePaths(d *Document, prefix string, tags []string, zoned bool) {
+	add := func(method, path string, o *Operation) {
+		if zoned {
+			o.OperationID = "v2Zone" + strings.TrimPrefix(o.OperationID, "v2")
+			o.Parameters = append([]Parameter{pathParam("zone")}, o.Parameters...)
+			o.Responses = withErrors(o.Responses, http.StatusNotFound)
+		}
+
+		d.add(method, path, o)
+	}
+
+	add(http.MethodGet, prefix+"/player/status", &Operation{
+		OperationID: "v2Status",
+		Summary:     "Current player status",
+		Tags:        tags,
+		Responses:   withErrors(ok("Player status", ref("VlcStatus")), http.StatusInternalServerError),
+	})
+	add(http.MethodPost, prefix+"/player/play", &Operation{
+		OperationID: "v2Play",
+		Summary:     "Resolve and play a track",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("PlayRequest")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+
+	for _, action := range []string{"pause", "resume", "stop"} {
+		add(http.MethodPost, prefix+"/player/"+action, &Operation{
+			OperationID: "v2" + strings.Title(action),
+			Summary:     strings.Title(action) + " the playback",
+			Tags:        tags,
+			Responses:   withErrors(noContent(), http.StatusInternalServerError),
+		})
+	}
+
+	add(http.MethodPut, prefix+"/player/seek", &Operation{
+		OperationID: "v2Seek",
+		Summary:     "Seek to a time in seconds, within the duration of the current track",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("SeekRequest")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError),
+	})
+	add(http.MethodPut, prefix+"/player/volume", &Operation{
+		OperationID: "v2Volume",
+		Summary:     "Set the volume",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("VolumeRequest")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	add(http.MethodPut, prefix+"/player/rate", &Operation{
+		OperationID: "v2Rate",
+		Summary:     "Set the playback rate, the pitch is preserved",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("RateRequest")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError),
+	})
+	add(http.MethodPut, prefix+"/player/fade", &Operation{
+		OperationID: "v2Fade",
+		Summary:     "Set the length of the volume ramps around pause, resume, stop and track changes",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("FadeRequest")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	add(http.MethodGet, prefix+"/player/modes", &Operation{
+		OperationID: "v2Modes",
+		Summary:     "The playback modes, changes are pushed as modes events",
+		Tags:        tags,
+		Responses:   ok("Modes", ref("Modes")),
+	})
+	add(http.MethodPut, prefix+"/player/normalization", &Operation{
+		OperationID: "v2Normalization",
+		Summary:     "Switch the loudness normalization, track brings every track to the same loudness using the loudness reported by the provider",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("NormalizationRequest")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	add(http.MethodGet, prefix+"/player/equalizer", &Operation{
+		OperationID: "v2Equalizer",
+		Summary:     "The gains of the equalizer, null while it's off",
+		Tags:        tags,
+		Responses:   ok("Equalizer", ref("Equalizer")),
+	})
+	add(http.MethodPut, prefix+"/player/equalizer", &Operation{
+		OperationID: "v2SetEqualizer",
+		Summary:     "Turn on the equalizer with a preset, a profile or custom gains, the change is pushed with the status",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("EqualizerChange")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+	add(http.MethodDelete, prefix+"/player/equalizer", &Operation{
+		OperationID: "v2DisableEqualizer",
+		Summary:     "Turn off the equalizer",
+		Tags:        tags,
+		Responses:   withErrors(noContent(), http.StatusInternalServerError),
+	})
+	add(http.MethodGet, prefix+"/player/outputs", &Operation{
+		OperationID: "v2Outputs",
+		Summary:     "The libvlc audio output modules, the devices of the active one and the current choice",
+		Tags:        tags,
+		Responses:   withErrors(ok("Audio outputs", ref("AudioOutputs")), http.StatusInternalServerError),
+	})
+	add(http.MethodPut, prefix+"/player/output", &Operation{
+		OperationID: "v2Output",
+		Summary:     "Switch the audio output module or device, the choice is remembered across restarts",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("OutputRequest")),
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+	add(http.MethodPost, prefix+"/player/next", &Operation{
+		OperationID: "v2Next",
+		Summary:     "Play the first queued track or the track suggested by the provider",
+		Tags:        tags,
+		Responses:   withErrors(noContent(), http.StatusConflict, http.StatusInternalServerError),
+	})
+	add(http.MethodGet, prefix+"/player/events", eventsOperation("v2Events", tags))
+	add(http.MethodGet, prefix+"/player/clients", &Operation{
+		OperationID: "v2Clients",
+		Summary:     "Number of connected sockjs clients",
+		Tags:        tags,
+		Responses: ok("Connected clients", Schema{
+			"type":       "object",
+			"properties": map[string]Schema{"count": {"type": "integer"}},
+		}),
+	})
+	add(http.MethodGet, prefix+"/player/lyrics", &Operation{
+		OperationID: "v2Lyrics",
+		Summary:     "Lyrics of the current track, the status has the current line of the synced ones",
+		Tags:        tags,
+		Responses:   withErrors(ok("Lyrics", ref("Lyrics")), http.StatusNotFound, http.StatusInternalServerError),
+	})
+	add(http.MethodGet, prefix+"/player/updates/{info}", &Operation{
+		OperationID: "v2Control",
+		Summary: "sockjs endpoint of the control protocol, accepts ControlRequest messages and pushes ControlEvent and ControlResponse messages. " +
+			"A snapshot of the modes, queue and status is sent on connect, followed by periodic heartbeats. " +
+			"history.get returns the room history, the tracks played by everyone",
+		Tags:       tags,
+		Parameters: []Parameter{pathParam("info")},
+		Responses:  withStatuses(ok("sockjs transport", nil), http.StatusSwitchingProtocols),
+		SocketMessages: Schema{
+			"oneOf": []Schema{ref("ControlEvent"), ref("ControlResponse")},
+		},
+	})
+	add(http.MethodGet, prefix+"/queue", &Operation{
+		OperationID: "v2Queue",
+		Summary:     "Queued tracks, played before the provider suggestions",
+		Tags:        tags,
+		Responses:   ok("Queue", tracksSchema()),
+	})
+	add(http.MethodPost, prefix+"/queue", &Operation{
+		OperationID: "v2Enqueue",
+		Summary:     "Resolve a track and add it to the end of the queue",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("PlayRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusCreated): {Description: "Queued track", Content: jsonContent(ref("Track"))},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+	add(http.MethodDelete, prefix+"/queue", &Operation{
+		OperationID: "v2ClearQueue",
+		Summary:     "Remove all queued tracks",
+		Tags:        tags,
+		Responses:   noContent(),
+	})
+	add(http.MethodDelete, prefix+"/queue/{index}", &Operation{
+		OperationID: "v2Dequeue",
+		Summary:     "Remove a queued track by its position",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("index")},
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	add(http.MethodGet, prefix+"/scheduler", &Operation{
+		OperationID: "v2Jobs",
+		Summary:     "The sleep timers and alarms, the active ones are pushed with the status as timers",
+		Tags:        tags,
+		Responses:   ok("Jobs", Schema{"type": "array", "items": ref("Job")}),
+	})
+	add(http.MethodPost, prefix+"/scheduler", &Operation{
+		OperationID: "v2CreateJob",
+		Summary:     "Schedule a sleep timer, which fades out and stops the playback, or an alarm, which plays tracks and ramps up the volume",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("JobRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusCreated): {Description: "Scheduled job", Content: jsonContent(ref("Job"))},
+		}, http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	add(http.MethodDelete, prefix+"/scheduler/{id}", &Operation{
+		OperationID: "v2CancelJob",
+		Summary:     "Cancel a sleep timer or an alarm",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id")},
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+}
+
+func addEqualizerPaths(d *Document, prefix string, tags []string) {
+	d.add(http.MethodGet, prefix+"/player/equalizer/presets", &Operation{
+		OperationID: "v2EqualizerPresets",
+		Summary:     "The presets built into libvlc and the frequencies of the bands",
+		Tags:        tags,
+		Responses:   withErrors(ok("Presets", ref("EqualizerPresets")), http.StatusInternalServerError),
+	})
+	d.add(http.MethodGet, prefix+"/player/equalizer/profiles", &Operation{
+		OperationID: "v2EqualizerProfiles",
+		Summary:     "The saved custom profiles, shared by all zones",
+		Tags:        tags,
+		Responses:   withErrors(ok("Profiles", Schema{"type": "array", "items": ref("EqualizerProfile")}), http.StatusInternalServerError),
+	})
+	d.add(http.MethodPut, prefix+"/player/equalizer/profiles/{name}", &Operation{
+		OperationID: "v2SaveEqualizerProfile",
+		Summary:     "Create or replace a custom profile",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("name")},
+		RequestBody: jsonBody(ref("EqualizerProfile")),
+		Responses:   withErrors(ok("Saved profile", ref("EqualizerProfile")), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	d.add(http.MethodDelete, prefix+"/player/equalizer/profiles/{name}", &Operation{
+		OperationID: "v2RemoveEqualizerProfile",
+		Summary:     "Remove a custom profile",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("name")},
+		Responses:   withErrors(noContent(), http.StatusNotFound, http.StatusInternalServerError),
+	})
+}
+
+func addZonesPaths(d *Document, prefix string, tags []string) {
+	d.add(http.MethodGet, prefix+"/zones", &Operation{
+		OperationID: "v2Zones",
+		Summary:     "The zones, each one a player with its own queue",
+		Tags:        tags,
+		Responses:   withErrors(ok("Zones", Schema{"type": "array", "items": ref("Zone")}), http.StatusInternalServerError),
+	})
+	d.add(http.MethodPost, prefix+"/zones", &Operation{
+		OperationID: "v2CreateZone",
+		Summary:     "Create a zone, it's gone after a restart unless it's in the config too",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("CreateZoneRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusCreated): {Description: "Created zone", Content: jsonContent(ref("Zone"))},
+		}, http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError),
+	})
+	d.add(http.MethodDelete, prefix+"/zones/{zone}", &Operation{
+		OperationID: "v2DeleteZone",
+		Summary:     "Stop and remove a zone, the default zone can't be removed",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("zone")},
+		Responses:   withErrors(noContent(), http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+}
+
+func updatesOperation(id string, tags []string) *Operation {
+	return &Operation{
+		OperationID:    id,
+		Summary:        "sockjs endpoint pushing the player status as JSON text messages, the status is sent again as a heartbeat",
+		Tags:           tags,
+		Parameters:     []Parameter{pathParam("info")},
+		Responses:      withStatuses(ok("sockjs transport", nil), http.StatusSwitchingProtocols),
+		SocketMessages: ref("VlcStatus"),
+	}
+}
+
+func eventsOperation(id string, tags []string) *Operation {
+	return &Operation{
+		OperationID: id,
+		Summary: "Server-sent events stream of the ControlEvent messages, the event field is the type and the data field the data. " +
+			"Pass Last-Event-ID, or the lastEventId query param, to resume, a snapshot of the state is sent when the missed events are gone",
+		Tags: tags,
+		Parameters: []Parameter{
+			{Name: "Last-Event-ID", In: "header", Schema: Schema{"type": "integer"}},
+			{Name: "lastEventId", In: "query", Schema: Schema{"type": "integer"}},
+		},
+		Responses: map[string]Response{
+			strconv.Itoa(http.StatusOK): {
+				Description: "Event stream",
+				Content: map[string]MediaType{
+					"text/event-stream": {Schema: Schema{"type": "string"}},
+				},
+			},
+		},
+		SocketMessages: ref("ControlEvent"),
+	}
+}
+
+func addUsersPaths(d *Document, prefix string, tags []string) {
+	errors := []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError}
+
+	d.add(http.MethodGet, prefix+"/users", &Operation{
+		OperationID: "v2Users",
+		Summary:     "All users",
+		Tags:        tags,
+		Responses:   withErrors(ok("Users", Schema{"type": "array", "items": ref("User")}), errors...),
+	})
+	d.add(http.MethodPost, prefix+"/users", &Operation{
+		OperationID: "v2CreateUser",
+		Summary:     "Create a user",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("CreateUserRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusCreated): {Description: "Created user", Content: jsonContent(ref("User"))},
+		}, errors...),
+	})
+	d.add(http.MethodDelete, prefix+"/users/{id}", &Operation{
+		OperationID: "v2DeleteUser",
+		Summary:     "Delete a user and its tokens",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id")},
+		Responses:   withErrors(noContent(), errors...),
+	})
+	d.add(http.MethodGet, prefix+"/users/{id}/tokens", &Operation{
+		OperationID: "v2Tokens",
+		Summary:     "The api tokens of a user",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id")},
+		Responses:   withErrors(ok("Tokens", Schema{"type": "array", "items": ref("Token")}), errors...),
+	})
+	d.add(http.MethodPost, prefix+"/users/{id}/tokens", &Operation{
+		OperationID: "v2CreateToken",
+		Summary:     "Create an api token for a user",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id")},
+		RequestBody: jsonBody(ref("CreateTokenRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusCreated): {Description: "Created token", Content: jsonContent(ref("CreateTokenResponse"))},
+		}, errors...),
+	})
+	d.add(http.MethodDelete, prefix+"/users/{id}/tokens/{tokenId}", &Operation{
+		OperationID: "v2DeleteToken",
+		Summary:     "Revoke an api token",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id"), intPathParam("tokenId")},
+		Responses:   withErrors(noContent(), errors...),
+	})
+}
+
+func addWebhooksPaths(d *Document, prefix string, tags []string) {
+	errors := []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError}
+
+	d.add(http.MethodGet, prefix+"/webhooks", &Operation{
+		OperationID: "v2Webhooks",
+		Summary:     "All webhooks, without their secrets",
+		Tags:        tags,
+		Responses:   withErrors(ok("Webhooks", Schema{"type": "array", "items": ref("Webhook")}), errors...),
+	})
+	d.add(http.MethodPost, prefix+"/webhooks", &Operation{
+		OperationID: "v2CreateWebhook",
+		Summary:     "Create a webhook, the response is the only one showing its secret",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("WebhookRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusCreated): {Description: "Created webhook", Content: jsonContent(ref("Webhook"))},
+		}, errors...),
+	})
+	d.add(http.MethodPut, prefix+"/webhooks/{id}", &Operation{
+		OperationID: "v2UpdateWebhook",
+		Summary:     "Update a webhook",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id")},
+		RequestBody: jsonBody(ref("WebhookRequest")),
+		Responses:   withErrors(ok("Updated webhook", ref("Webhook")), errors...),
+	})
+	d.add(http.MethodDelete, prefix+"/webhooks/{id}", &Operation{
+		OperationID: "v2DeleteWebhook",
+		Summary:     "Delete a webhook and its delivery log",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id")},
+		Responses:   withErrors(noContent(), errors...),
+	})
+	d.add(http.MethodGet, prefix+"/webhooks/{id}/deliveries", &Operation{
+		OperationID: "v2WebhookDeliveries",
+		Summary:     "The delivery log of a webhook, the latest delivery first",
+		Tags:        tags,
+		Parameters:  []Parameter{intPathParam("id")},
+		Responses:   withErrors(ok("Deliveries", Schema{"type": "array", "items": ref("Delivery")}), errors...),
+	})
+}
+
+func addCachePaths(d *Document, prefix string, tags []string) {
+	errors := []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError}
+	params := []Parameter{pathParam("provider"), pathParam("id")}
+
+	d.add(http.MethodGet, prefix+"/cache", &Operation{
+		OperationID: "v2Cache",
+		Summary:     "The cached tracks, the least recently used first, and the downloads in progress",
+		Tags:        tags,
+		Responses:   withErrors(ok("Cache", ref("CacheStats")), errors...),
+	})
+	d.add(http.MethodPost, prefix+"/cache", &Operation{
+		OperationID: "v2CacheTrack",
+		Summary:     "Download a track to the cache in the background, a cached track is pinned when pinned is set",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("CacheRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusAccepted): {Description: "Download started"},
+		}, errors...),
+	})
+	d.add(http.MethodPut, prefix+"/cache/{provider}/{id}", &Operation{
+		OperationID: "v2PinCached",
+		Summary:     "Pin a cached track or let it be evicted again",
+		Tags:        tags,
+		Parameters:  params,
+		RequestBody: jsonBody(Schema{
+			"type":       "object",
+			"required":   []string{"pinned"},
+			"properties": map[string]Schema{"pinned": {"type": "boolean"}},
+		}),
+		Responses: withErrors(ok("Cached track", ref("CacheEntry")), errors...),
+	})
+	d.add(http.MethodDelete, prefix+"/cache/{provider}/{id}", &Operation{
+		OperationID: "v2RemoveCached",
+		Summary:     "Remove a track from the cache",
+		Tags:        tags,
+		Parameters:  params,
+		Responses:   withErrors(noContent(), errors...),
+	})
+}
+
+func addProfilePaths(d *Document, prefix string, tags []string) {
+	errors := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError}
+
+	d.add(http.MethodGet, prefix+"/me/profile", &Operation{
+		OperationID: "v2Profile",
+		Summary:     "The profile of the current user, shared by everyone when auth is disabled",
+		Tags:        tags,
+		Responses:   withErrors(ok("Profile", ref("Profile")), errors...),
+	})
+	d.add(http.MethodPut, prefix+"/me/profile", &Operation{
+		OperationID: "v2UpdateProfile",
+		Summary:     "Choose where autoplay picks tracks from after the ones the user queued",
+		Tags:        tags,
+		RequestBody: jsonBody(Schema{
+			"type":       "object",
+			"required":   []string{"autoplaySeed"},
+			"properties": map[string]Schema{"autoplaySeed": {"type": "string", "enum": profiles.Seeds()}},
+		}),
+		Responses: withErrors(noContent(), append(errors, http.StatusBadRequest)...),
+	})
+	d.add(http.MethodGet, prefix+"/me/history", &Operation{
+		OperationID: "v2UserHistory",
+		Summary:     "Tracks played by the current user, most recent first",
+		Tags:        tags,
+		Responses:   withErrors(ok("Plays", Schema{"type": "array", "items": ref("Play")}), errors...),
+	})
+	d.add(http.MethodGet, prefix+"/me/stats", &Operation{
+		OperationID: "v2UserStats",
+		Summary:     "Play counts and most played tracks of the current user",
+		Tags:        tags,
+		Responses:   withErrors(ok("Stats", ref("Stats")), errors...),
+	})
+	d.add(http.MethodGet, prefix+"/me/favorites", &Operation{
+		OperationID: "v2Favorites",
+		Summary:     "Favorite tracks of the current user",
+		Tags:        tags,
+		Responses:   withErrors(ok("Favorites", Schema{"type": "array", "items": ref("Favorite")}), errors...),
+	})
+	d.add(http.MethodPost, prefix+"/me/favorites", &Operation{
+		OperationID: "v2AddFavorite",
+		Summary:     "Resolve a track and add it to the favorites of the current user",
+		Tags:        tags,
+		RequestBody: jsonBody(ref("PlayRequest")),
+		Responses: withErrors(map[string]Response{
+			strconv.Itoa(http.StatusCreated): {Description: "Favorite", Content: jsonContent(ref("Favorite"))},
+		}, append(errors, http.StatusBadRequest, http.StatusNotFound)...),
+	})
+	d.add(http.MethodDelete, prefix+"/me/favorites/{provider}/{id}", &Operation{
+		OperationID: "v2RemoveFavorite",
+		Summary:     "Remove a track from the favorites of the current user",
+		Tags:        tags,
+		Parameters:  []Parameter{pathParam("provider"), pathParam("id")},
+		Responses:   withErrors(noContent(), append(errors, http.StatusNotFound)...),
+	})
+	d.add(http.MethodGet, prefix+"/room/history", &Operation{
+		OperationID: "v2RoomHistory",
+		Summary:     "Tracks played by every user, most recent first",
+		Tags:        tags,
+		Responses:   withErrors(ok("Plays", Schema{"type": "array", "items": ref("Play")}), errors...),
+	})
+	d.add(http.MethodGet, prefix+"/room/stats", &Operation{
+		OperationID: "v2RoomStats",
+		Summary:     "Stats of every user who played something, the most active first",
+		Tags:        tags,
+		Responses:   withErrors(ok("Stats", Schema{"type": "array", "items": ref("Stats")}), errors...),
+	})
+}
@@ -1,9 +1,11 @@
 package player
 
 import (
+	"gngeorgiev/audiotic/server/hub"
 	"gngeorgiev/audiotic/server/models"
 
 	"log"
+	"time"
 )
 
 func (v *VlcPlayer) IsPlaying() bool {
@@ -14,20 +16,44 @@ func (v *VlcPlayer) IsPlaying() bool {
 }
 
 func (v *VlcPlayer) Resume() error {
-	v.resumePlayingChan <- struct{}{}
-	return nil
+	select {
+	case v.resumePlayingChan <- struct{}{}:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
+}
+
+// Subscribe returns a channel receiving a *VlcStatus every time the status changes
+// and a func to unsubscribe, a slow subscriber never blocks the player
+func (v *VlcPlayer) Subscribe(o hub.Options) (<-chan interface{}, func()) {
+	return v.updates.Subscribe(o)
 }
 
-func (v *VlcPlayer) OnUpdated(ch chan *VlcStatus) {
-	v.onUpdatedChansMutex.Lock()
-	defer v.onUpdatedChansMutex.Unlock()
+func (v *VlcPlayer) UpdatesMetrics() hub.Metrics {
+	return v.updates.Metrics()
+}
 
-	v.onUpdatedChans = append(v.onUpdatedChans, ch)
+// PlayOptions are applied to a track once it starts, the track playing until then keeps its own
+type PlayOptions struct {
+	// Gain is the loudness normalization gain in dB applied on top of the volume
+	Gain float64
+	// Rate is the playback rate, 0 plays at the normal speed
+	Rate float64
 }
 
-func (v *VlcPlayer) Play(t models.Track) error {
-	v.startedPlayingChan <- t
-	return nil
+type playRequest struct {
+	track   models.Track
+	options PlayOptions
+}
+
+func (v *VlcPlayer) Play(t models.Track, o PlayOptions) error {
+	select {
+	case v.startedPlayingChan <- playRequest{t, o}:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
 }
 
 func (v *VlcPlayer) Track() models.Track {
@@ -37,27 +63,74 @@ func (v *VlcPlayer) Track() models.Track {
 	return v.track
 }
 
+// UpdateTrack replaces the track playing from the stream url of t, e.g. with its looked up metadata,
+// it's ignored once another track plays
+func (v *VlcPlayer) UpdateTrack(t models.Track) {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	if v.source == t.StreamUrl {
+		v.track = t
+	}
+}
+
 func (v *VlcPlayer) Pause() error {
-	v.pausedPlayingChan <- struct{}{}
-	return nil
+	select {
+	case v.pausedPlayingChan <- struct{}{}:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
 }
 
 func (v *VlcPlayer) Stop() error {
-	v.stoppedPlayingChah <- struct{}{}
-	return nil
+	select {
+	case v.stoppedPlayingChah <- struct{}{}:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
 }
 
 func (v *VlcPlayer) Seek(time int) error {
-	v.seekChan <- time
-	return nil
+	select {
+	case v.seekChan <- time:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
 }
 
 func (v *VlcPlayer) Volume(vol int) error {
 	v.statsMutex.Lock()
 	v.volume = vol
 	v.statsMutex.Unlock()
-	v.volumeChan <- vol
-	return nil
+	select {
+	case v.volumeChan <- vol:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
+}
+
+// Rate sets the playback rate, 1 is the normal speed
+func (v *VlcPlayer) Rate(rate float64) error {
+	select {
+	case v.rateChan <- rate:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
+}
+
+// SetGain sets the gain in dB applied on top of the volume, it's reset by setting it to 0
+func (v *VlcPlayer) SetGain(gain float64) error {
+	select {
+	case v.gainChan <- gain:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
 }
 
 func (v *VlcPlayer) Status() (*VlcStatus, error) {
@@ -66,34 +139,53 @@ func (v *VlcPlayer) Status() (*VlcStatus, error) {
 	}
 
 	status := &VlcStatus{}
+	status.Zone = v.zone
 	status.Name = v.name
 	status.Duration = v.duration
 	status.Source = v.source
 	status.Time = v.time
 	status.Volume = v.volume
+	status.Gain = v.gain
+	status.Rate = v.rate
 	status.State = MediaStateToString(v.state)
 	status.IsPlaying = v.IsPlaying()
 	status.Thumbnail = v.thumbnail
+	status.Output = v.output
+	status.OutputDevice = v.device
+	status.Equalizer = v.Equalizer()
+	status.Timers = v.Timers()
+	status.Listeners = v.Listeners()
+	status.LyricsLine = v.LyricsLine()
+	status.FadeLength = int(v.FadeLength() / time.Millisecond)
+
+	t := v.Track()
+	status.QueuedBy = t.QueuedBy
+	status.Autoplayed = t.Autoplayed
+	status.Artist, status.Album, status.CleanTitle = t.Artist, t.Album, t.CleanTitle
 
 	return status, nil
 }
 
 func (v *VlcPlayer) Release() error {
-	v.releaseChan <- struct{}{}
-	return nil
+	select {
+	case v.releaseChan <- struct{}{}:
+		<-v.released
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
 }
 
-func Init() error {
-	oncePlayer.Do(func() {
-		player = &VlcPlayer{}
-		if err := player.init(); err != nil {
-			log.Fatal(err)
-		}
-	})
+// New starts the player of a zone, it runs until released
+func New(zone string) *VlcPlayer {
+	v := &VlcPlayer{zone: zone}
+	if err := v.init(); err != nil {
+		log.Fatal(err)
+	}
 
-	return nil
+	return v
 }
 
-func Get() *VlcPlayer {
-	return player
+func (v *VlcPlayer) Zone() string {
+	return v.zone
 }
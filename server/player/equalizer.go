@@ -0,0 +1,114 @@
+package player
+
+import (
+	vlc "github.com/adrg/libvlc-go/v3"
+)
+
+// Equalizer are the gains in dB of the preamp and the bands of the libvlc equalizer
+type Equalizer struct {
+	// Preset is the name of the preset or profile the gains were taken from, empty for custom gains
+	Preset string    `json:"preset,omitempty"`
+	Preamp float64   `json:"preamp"`
+	Bands  []float64 `json:"bands"`
+}
+
+// EqualizerBands returns the frequencies of the bands in Hz
+func EqualizerBands() []float64 {
+	return vlc.EqualizerBandFrequencies()
+}
+
+// EqualizerPresets returns the presets built into libvlc
+func EqualizerPresets() ([]Equalizer, error) {
+	names := vlc.EqualizerPresetNames()
+	res := make([]Equalizer, len(names))
+	for i, name := range names {
+		eq, err := vlc.NewEqualizerFromPreset(uint(i))
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := equalizerFromVlc(eq)
+		eq.Release()
+		if err != nil {
+			return nil, err
+		}
+
+		e.Preset = name
+		res[i] = e
+	}
+
+	return res, nil
+}
+
+func equalizerFromVlc(eq *vlc.Equalizer) (Equalizer, error) {
+	preamp, err := eq.PreampValue()
+	if err != nil {
+		return Equalizer{}, err
+	}
+
+	e := Equalizer{
+		Preamp: preamp,
+		Bands:  make([]float64, vlc.EqualizerBandCount()),
+	}
+
+	for i := range e.Bands {
+		if e.Bands[i], err = eq.AmpValueAtIndex(uint(i)); err != nil {
+			return Equalizer{}, err
+		}
+	}
+
+	return e, nil
+}
+
+// Equalizer returns the active equalizer, nil when it's off
+func (v *VlcPlayer) Equalizer() *Equalizer {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	return v.equalizer
+}
+
+// SetEqualizer applies the gains of e, passing nil turns the equalizer off
+func (v *VlcPlayer) SetEqualizer(e *Equalizer) error {
+	select {
+	case v.equalizerChan <- e:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
+}
+
+func (v *VlcPlayer) setEqualizer(e *Equalizer) error {
+	if e == nil {
+		if err := v.player.SetEqualizer(nil); err != nil {
+			return err
+		}
+	} else {
+		eq, err := vlc.NewEqualizer()
+		if err != nil {
+			return err
+		}
+		defer eq.Release()
+
+		if err := eq.SetPreampValue(e.Preamp); err != nil {
+			return err
+		}
+
+		for i, gain := range e.Bands {
+			if err := eq.SetAmpValueAtIndex(gain, uint(i)); err != nil {
+				return err
+			}
+		}
+
+		// libvlc copies the gains, so the equalizer can be released right away
+		if err := v.player.SetEqualizer(eq); err != nil {
+			return err
+		}
+	}
+
+	v.statsMutex.Lock()
+	v.equalizer = e
+	v.statsMutex.Unlock()
+
+	return nil
+}
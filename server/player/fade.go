@@ -0,0 +1,230 @@
+package player
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"time"
+
+	vlc "github.com/adrg/libvlc-go/v3"
+)
+
+// fade ramps the fade level, the fraction of the set volume which is actually played
+type fade struct {
+	to       float64
+	duration time.Duration
+	// then runs in the event loop once the level reached to, it's skipped when the fade is cancelled
+	then func() error
+	// stops tells that the fade ends the playback, e.g. the one of a sleep timer, it goes on across track changes
+	stops bool
+}
+
+// fading is the fade in progress, it's only touched by the event loop
+type fading struct {
+	fade
+	from    float64
+	started time.Time
+	ticker  *time.Ticker
+}
+
+var (
+	fadeInterval = 50 * time.Millisecond
+	// defaultFadeLength is the length of the ramps around pause, resume, stop and track changes
+	defaultFadeLength = 300 * time.Millisecond
+)
+
+// Fade ramps the volume from its current level to the given fraction of the set volume, 0 mutes and 1 is the set volume
+func (v *VlcPlayer) Fade(to float64, d time.Duration) error {
+	select {
+	case v.fadeChan <- fade{to: to, duration: d}:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
+}
+
+// FadeOutAndStop fades the volume out and stops the playback, the volume is back to the set one afterwards
+func (v *VlcPlayer) FadeOutAndStop(d time.Duration) error {
+	select {
+	case v.fadeChan <- fade{duration: d, then: v.stopAndRestore, stops: true}:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
+}
+
+func (v *VlcPlayer) FadeLength() time.Duration {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	return v.fadeLength
+}
+
+// SetFadeLength sets the length of the ramps around pause, resume, stop and track changes, 0 turns them off.
+// A fade in progress keeps its length
+func (v *VlcPlayer) SetFadeLength(d time.Duration) {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	v.fadeLength = d
+}
+
+// changeTrack fades the playing track out, plays t and fades it in, a track played while nothing is audible starts right away.
+// During a fade which stops the playback t is played right away and the fade goes on, so the stop isn't lost
+func (v *VlcPlayer) changeTrack(t models.Track, o PlayOptions) error {
+	if v.fading != nil && v.fading.stops {
+		return v.play(t, o)
+	}
+
+	v.cancelFade()
+	d := v.FadeLength()
+	if d <= 0 || !v.audible() {
+		return v.play(t, o)
+	}
+
+	return v.startFade(fade{duration: d, then: func() error {
+		if err := v.play(t, o); err != nil {
+			v.setFadeLevel(1)
+			return err
+		}
+
+		return v.startFade(fade{to: 1, duration: d})
+	}})
+}
+
+func (v *VlcPlayer) fadedPause() error {
+	v.cancelFade()
+	return v.fadeOut(func() error {
+		if err := v.pause(); err != nil {
+			return err
+		}
+
+		// nothing plays while paused, resume fades in from silence again
+		return v.setFadeLevel(1)
+	})
+}
+
+// fadedResume resumes the playback and fades it in, resuming during the fade out of a pause fades back in
+func (v *VlcPlayer) fadedResume() error {
+	v.cancelFade()
+	d := v.FadeLength()
+	if d <= 0 {
+		return v.resume()
+	}
+
+	st, err := v.player.MediaState()
+	if err != nil {
+		return err
+	}
+
+	if st == vlc.MediaPaused {
+		if err := v.setFadeLevel(0); err != nil {
+			return err
+		}
+
+		if err := v.resume(); err != nil {
+			return err
+		}
+	}
+
+	return v.startFade(fade{to: 1, duration: d})
+}
+
+func (v *VlcPlayer) fadedStop() error {
+	v.cancelFade()
+	return v.fadeOut(v.stopAndRestore)
+}
+
+// fadeOut fades the volume out before running then, right away when fades are off or nothing is playing
+func (v *VlcPlayer) fadeOut(then func() error) error {
+	d := v.FadeLength()
+	if d <= 0 || !v.audible() {
+		return then()
+	}
+
+	return v.startFade(fade{duration: d, then: then})
+}
+
+// audible tells whether anything can be heard, a muted player has nothing to fade out
+func (v *VlcPlayer) audible() bool {
+	v.statsMutex.Lock()
+	level := v.fadeLevel
+	v.statsMutex.Unlock()
+
+	return level > 0 && v.player.IsPlaying()
+}
+
+func (v *VlcPlayer) stopAndRestore() error {
+	if err := v.stop(); err != nil {
+		return err
+	}
+
+	return v.setFadeLevel(1)
+}
+
+// startFade replaces the fade in progress, a fade without a duration ends right away
+func (v *VlcPlayer) startFade(f fade) error {
+	v.cancelFade()
+	if f.duration <= 0 {
+		return v.endFade(f)
+	}
+
+	v.statsMutex.Lock()
+	from := v.fadeLevel
+	v.statsMutex.Unlock()
+
+	v.fading = &fading{
+		fade:    f,
+		from:    from,
+		started: time.Now(),
+		ticker:  time.NewTicker(fadeInterval),
+	}
+
+	return nil
+}
+
+// fadeTicks returns the ticks of the fade in progress, nil blocks the event loop case while nothing fades
+func (v *VlcPlayer) fadeTicks() <-chan time.Time {
+	if v.fading == nil {
+		return nil
+	}
+
+	return v.fading.ticker.C
+}
+
+// stepFade moves the level towards the target of the fade, true is returned once the fade is done
+func (v *VlcPlayer) stepFade() (bool, error) {
+	f := v.fading
+	progress := float64(time.Since(f.started)) / float64(f.duration)
+	if progress >= 1 {
+		v.cancelFade()
+		return true, v.endFade(f.fade)
+	}
+
+	return false, v.setFadeLevel(f.from + (f.to-f.from)*progress)
+}
+
+func (v *VlcPlayer) endFade(f fade) error {
+	if err := v.setFadeLevel(f.to); err != nil {
+		return err
+	}
+
+	if f.then != nil {
+		return f.then()
+	}
+
+	return nil
+}
+
+func (v *VlcPlayer) cancelFade() {
+	if v.fading != nil {
+		v.fading.ticker.Stop()
+		v.fading = nil
+	}
+}
+
+func (v *VlcPlayer) setFadeLevel(level float64) error {
+	v.statsMutex.Lock()
+	v.fadeLevel = level
+	v.statsMutex.Unlock()
+
+	return v.player.SetVolume(v.effectiveVolume())
+}
@@ -0,0 +1,132 @@
+package player
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"testing"
+	"time"
+
+	vlc "github.com/adrg/libvlc-go/v3"
+)
+
+// fakeVlc plays whatever it's given right away
+type fakeVlc struct {
+	url     string
+	playing bool
+	stopped bool
+	volume  int
+	rate    float32
+	output  string
+	device  string
+	time    int
+}
+
+func (f *fakeVlc) Play() error                     { f.playing = true; return nil }
+func (f *fakeVlc) IsPlaying() bool                 { return f.playing }
+func (f *fakeVlc) Stop() error                     { f.playing, f.stopped = false, true; return nil }
+func (f *fakeVlc) SetPause(pause bool) error       { f.playing = !pause; return nil }
+func (f *fakeVlc) SetVolume(volume int) error      { f.volume = volume; return nil }
+func (f *fakeVlc) MediaLength() (int, error)       { return 180000, nil }
+func (f *fakeVlc) MediaTime() (int, error)         { return f.time, nil }
+func (f *fakeVlc) SetMediaTime(t int) error        { return nil }
+func (f *fakeVlc) SetPlaybackRate(r float32) error { f.rate = r; return nil }
+func (f *fakeVlc) SetAudioOutput(o string) error   { f.output = o; return nil }
+func (f *fakeVlc) Release() error                  { return nil }
+
+func (f *fakeVlc) LoadMediaFromURL(url string) (*vlc.Media, error) {
+	f.url = url
+	return nil, nil
+}
+
+func (f *fakeVlc) MediaState() (vlc.MediaState, error) {
+	if f.playing {
+		return vlc.MediaPlaying, nil
+	}
+
+	return vlc.MediaStopped, nil
+}
+
+func (f *fakeVlc) SetEqualizer(e *vlc.Equalizer) error { return nil }
+func (f *fakeVlc) SetAudioOutputDevice(device, output string) error {
+	f.device, f.output = device, output
+	return nil
+}
+
+func (f *fakeVlc) AudioOutputDevices() ([]*vlc.AudioOutputDevice, error) {
+	return nil, nil
+}
+
+func newFadingPlayer() (*VlcPlayer, *fakeVlc) {
+	f := &fakeVlc{playing: true}
+	return &VlcPlayer{player: f, volume: 100, rate: 1, fadeLevel: 1, fadeLength: defaultFadeLength}, f
+}
+
+func TestTrackChangeKeepsPendingStop(t *testing.T) {
+	v, f := newFadingPlayer()
+	if err := v.startFade(fade{duration: 10 * time.Minute, then: v.stopAndRestore, stops: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.changeTrack(models.Track{StreamUrl: "http://example.com/next"}, PlayOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.url != "http://example.com/next" {
+		t.Fatalf("The next track wasn't played right away, got %q", f.url)
+	}
+
+	if v.fading == nil || !v.fading.stops {
+		t.Fatal("The stop was dropped by the track change")
+	}
+
+	v.fading.started = time.Now().Add(-11 * time.Minute)
+	done, err := v.stepFade()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !done || !f.stopped {
+		t.Fatal("The playback wasn't stopped at the end of the fade")
+	}
+
+	if v.fadeLevel != 1 {
+		t.Errorf("The volume wasn't restored after the stop, the level is %f", v.fadeLevel)
+	}
+}
+
+func TestTrackChangeReplacesOtherFades(t *testing.T) {
+	v, f := newFadingPlayer()
+	if err := v.startFade(fade{to: 0.5, duration: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.changeTrack(models.Track{StreamUrl: "http://example.com/next"}, PlayOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the old track fades out first
+	if f.url != "" || v.fading == nil || v.fading.to != 0 || v.fading.then == nil {
+		t.Fatalf("Expected a fade out before the track change, got %+v", v.fading)
+	}
+	v.cancelFade()
+}
+
+func TestTrackChangeAppliesTheGainAndRateToTheNewTrack(t *testing.T) {
+	v, f := newFadingPlayer()
+	if err := v.changeTrack(models.Track{StreamUrl: "http://example.com/next"}, PlayOptions{Gain: -6, Rate: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the old track fades out at its own loudness and speed
+	if v.gain != 0 || v.rate != 1 || f.rate != 0 || f.url != "" {
+		t.Fatalf("The gain or the rate was applied before the track change, they're %f and %f", v.gain, v.rate)
+	}
+
+	if err := v.fading.then(); err != nil {
+		t.Fatal(err)
+	}
+	v.cancelFade()
+
+	if f.url != "http://example.com/next" || v.gain != -6 || v.rate != 1.5 || f.rate != 1.5 {
+		t.Fatalf("Expected the next track to play with the gain and the rate, got %q at %f and %f", f.url, v.gain, f.rate)
+	}
+}
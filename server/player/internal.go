@@ -4,9 +4,10 @@ import (
 	"gngeorgiev/audiotic/server/models"
 
 	"log"
+	"math"
 	"time"
 
-	vlc "github.com/adrg/libvlc-go"
+	vlc "github.com/adrg/libvlc-go/v3"
 )
 
 func (v *VlcPlayer) createPlayer() (*vlc.Player, error) {
@@ -15,7 +16,7 @@ func (v *VlcPlayer) createPlayer() (*vlc.Player, error) {
 		return nil, err
 	}
 
-	player.SetVolume(v.volume)
+	player.SetVolume(v.effectiveVolume())
 	return player, nil
 }
 
@@ -39,29 +40,34 @@ func (v *VlcPlayer) updateStatus() {
 		log.Println(err)
 		return
 	}
+	v.mediaTime = t
 	v.time = t / 1000
 
-	vol, err := v.player.Volume()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	v.volume = vol
-
 	v.isPlaying = v.player.IsPlaying() && v.state == vlc.MediaPlaying
 }
 
 func (v *VlcPlayer) update(t *time.Timer) {
 	v.updateStatus()
-	go v.notifyUpdated()
+	v.notifyUpdated()
 	t.Reset(updatesInterval)
 }
 
-func (v *VlcPlayer) play(t models.Track) error {
-	if err := v.player.SetMedia(t.StreamUrl, false); err != nil {
+func (v *VlcPlayer) play(t models.Track, o PlayOptions) error {
+	m, err := v.player.LoadMediaFromURL(t.StreamUrl)
+	if err != nil {
 		return err
 	}
 
+	v.statsMutex.Lock()
+	sout := v.streamOutput
+	v.statsMutex.Unlock()
+	if sout != "" {
+		// the stream output is kept between the tracks, so the listeners aren't disconnected on track changes
+		if err := m.AddOptions(":sout="+sout, ":sout-keep"); err != nil {
+			return err
+		}
+	}
+
 	if err := v.player.Play(); err != nil {
 		return err
 	}
@@ -70,6 +76,23 @@ func (v *VlcPlayer) play(t models.Track) error {
 		return err
 	}
 
+	// the gain and the rate of the new track are set right before it's played
+	rate := o.Rate
+	if rate == 0 {
+		rate = 1
+	}
+
+	v.statsMutex.Lock()
+	v.gain, v.rate = o.Gain, rate
+	v.statsMutex.Unlock()
+	if err := v.player.SetVolume(v.effectiveVolume()); err != nil {
+		return err
+	}
+
+	if err := v.player.SetPlaybackRate(float32(rate)); err != nil {
+		return err
+	}
+
 	d, err := v.player.MediaLength()
 	if err != nil {
 		return err
@@ -103,14 +126,47 @@ func (v *VlcPlayer) stop() error {
 }
 
 func (v *VlcPlayer) setVolume(vol int) error {
-	return v.player.SetVolume(vol)
+	return v.player.SetVolume(v.effectiveVolume())
 }
 
-func (v *VlcPlayer) release() error {
-	if err := v.Stop(); err != nil {
-		return err
+func (v *VlcPlayer) setRate(rate float64) error {
+	v.statsMutex.Lock()
+	v.rate = rate
+	mediaSet := v.mediaSet
+	v.statsMutex.Unlock()
+
+	if !mediaSet {
+		return nil
 	}
 
+	return v.player.SetPlaybackRate(float32(rate))
+}
+
+func (v *VlcPlayer) setGain(gain float64) error {
+	v.statsMutex.Lock()
+	v.gain = gain
+	v.statsMutex.Unlock()
+
+	return v.player.SetVolume(v.effectiveVolume())
+}
+
+// effectiveVolume is the volume set by the user with the gain of the track and the fade level applied
+func (v *VlcPlayer) effectiveVolume() int {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	vol := int(math.Floor(float64(v.volume)*math.Pow(10, v.gain/20)*v.fadeLevel + 0.5))
+	if vol > maxVlcVolume {
+		return maxVlcVolume
+	}
+
+	return vol
+}
+
+// release runs on the event loop, so it can't go through Stop
+func (v *VlcPlayer) release() error {
+	defer close(v.released)
+
 	if v.player != nil {
 		if err := v.player.Stop(); err != nil {
 			return err
@@ -121,9 +177,5 @@ func (v *VlcPlayer) release() error {
 		}
 	}
 
-	if err := vlc.Release(); err != nil {
-		return err
-	}
-
-	return nil
+	return releaseVlc()
 }
@@ -0,0 +1,27 @@
+package player
+
+import "sort"
+
+// SetLyrics sets the start times in milliseconds of the lines of the synced lyrics of the track playing from source,
+// they're ignored once another track plays
+func (v *VlcPlayer) SetLyrics(source string, times []int) {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	v.lyricsSource, v.lyricsTimes = source, times
+}
+
+// LyricsLine returns the index of the line of the synced lyrics at the current time,
+// -1 without synced lyrics and before the first line
+func (v *VlcPlayer) LyricsLine() int {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	if v.lyricsSource != v.source || len(v.lyricsTimes) == 0 {
+		return -1
+	}
+
+	return sort.Search(len(v.lyricsTimes), func(i int) bool {
+		return v.lyricsTimes[i] > v.mediaTime
+	}) - 1
+}
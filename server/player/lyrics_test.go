@@ -0,0 +1,40 @@
+package player
+
+import "testing"
+
+func TestLyricsLineUsesTheMillisecondTime(t *testing.T) {
+	v, f := newFadingPlayer()
+	v.source, v.mediaSet = "youtube/abc", true
+	v.SetLyrics("youtube/abc", []int{1000, 2400, 2900, 5000})
+
+	for _, c := range []struct {
+		time, line int
+	}{
+		{0, -1},
+		{999, -1},
+		{1000, 0},
+		{2399, 0},
+		{2400, 1},
+		{2899, 1},
+		{2950, 2},
+		{7000, 3},
+	} {
+		f.time = c.time
+		v.updateStatus()
+		if line := v.LyricsLine(); line != c.line {
+			t.Errorf("Expected the line %d at %dms, got %d", c.line, c.time, line)
+		}
+	}
+}
+
+func TestLyricsLineIgnoresTheLyricsOfAnotherTrack(t *testing.T) {
+	v, f := newFadingPlayer()
+	v.source, v.mediaSet = "youtube/def", true
+	v.SetLyrics("youtube/abc", []int{1000})
+
+	f.time = 5000
+	v.updateStatus()
+	if line := v.LyricsLine(); line != -1 {
+		t.Errorf("Expected no line, got %d", line)
+	}
+}
@@ -0,0 +1,121 @@
+package player
+
+import vlc "github.com/adrg/libvlc-go/v3"
+
+// AudioOutput is a libvlc audio output module, e.g. alsa or pulse
+type AudioOutput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// AudioDevice is a device of an audio output module, e.g. a sound card
+type AudioDevice struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// AudioOutputs are the available output modules and the devices of the active one
+type AudioOutputs struct {
+	Outputs []AudioOutput `json:"outputs"`
+	Devices []AudioDevice `json:"devices"`
+	Output  string        `json:"output"`
+	Device  string        `json:"device"`
+}
+
+type outputChange struct {
+	output, device string
+}
+
+type outputsReply struct {
+	outputs AudioOutputs
+	err     error
+}
+
+// AudioOutputs lists the outputs in the event loop, which owns the libvlc player
+func (v *VlcPlayer) AudioOutputs() (AudioOutputs, error) {
+	reply := make(chan outputsReply, 1)
+	select {
+	case v.outputsChan <- reply:
+	case <-v.released:
+		return AudioOutputs{}, ErrReleased
+	}
+
+	r := <-reply
+	return r.outputs, r.err
+}
+
+func (v *VlcPlayer) audioOutputs() (AudioOutputs, error) {
+	outputs, err := vlc.AudioOutputList()
+	if err != nil {
+		return AudioOutputs{}, err
+	}
+
+	devices, err := v.player.AudioOutputDevices()
+	if err != nil {
+		return AudioOutputs{}, err
+	}
+
+	v.statsMutex.Lock()
+	res := AudioOutputs{
+		Outputs: make([]AudioOutput, len(outputs)),
+		Devices: make([]AudioDevice, len(devices)),
+		Output:  v.output,
+		Device:  v.device,
+	}
+	v.statsMutex.Unlock()
+
+	for i, o := range outputs {
+		res.Outputs[i] = AudioOutput{Name: o.Name, Description: o.Description}
+	}
+
+	for i, d := range devices {
+		res.Devices[i] = AudioDevice{ID: d.Name, Description: d.Description}
+	}
+
+	return res, nil
+}
+
+// SetAudioOutput switches the output module and device, empty values keep the current ones.
+// The device is switched without stopping the playback, a new module is used from the next track
+func (v *VlcPlayer) SetAudioOutput(output, device string) error {
+	select {
+	case v.outputChan <- outputChange{output: output, device: device}:
+		return nil
+	case <-v.released:
+		return ErrReleased
+	}
+}
+
+func (v *VlcPlayer) setAudioOutput(o outputChange) error {
+	v.statsMutex.Lock()
+	current := v.output
+	v.statsMutex.Unlock()
+
+	if o.output != "" && o.output != current {
+		if err := v.player.SetAudioOutput(o.output); err != nil {
+			return err
+		}
+
+		current = o.output
+	}
+
+	if o.device != "" {
+		if err := v.player.SetAudioOutputDevice(o.device, current); err != nil {
+			return err
+		}
+	}
+
+	v.statsMutex.Lock()
+	if current != v.output {
+		// the device of the previous module means nothing to the new one
+		v.device = ""
+	}
+
+	v.output = current
+	if o.device != "" {
+		v.device = o.device
+	}
+	v.statsMutex.Unlock()
+
+	return nil
+}
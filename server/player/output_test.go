@@ -0,0 +1,47 @@
+package player
+
+import "testing"
+
+func TestSetAudioOutputForgetsTheDeviceOfThePreviousModule(t *testing.T) {
+	v, f := newFadingPlayer()
+	if err := v.setAudioOutput(outputChange{output: "alsa", device: "hw:1,0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.output != "alsa" || v.device != "hw:1,0" || f.output != "alsa" || f.device != "hw:1,0" {
+		t.Fatalf("Expected alsa on hw:1,0, got %s on %s", v.output, v.device)
+	}
+
+	if err := v.setAudioOutput(outputChange{output: "pulse"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.output != "pulse" || v.device != "" {
+		t.Errorf("Expected pulse on its default device, got %s on %q", v.output, v.device)
+	}
+}
+
+func TestSetAudioOutputKeepsTheModule(t *testing.T) {
+	v, f := newFadingPlayer()
+	if err := v.setAudioOutput(outputChange{output: "alsa", device: "hw:1,0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range []outputChange{{device: "hw:2,0"}, {output: "alsa", device: "hw:2,0"}} {
+		if err := v.setAudioOutput(o); err != nil {
+			t.Fatal(err)
+		}
+
+		if v.output != "alsa" || v.device != "hw:2,0" || f.output != "alsa" || f.device != "hw:2,0" {
+			t.Errorf("Expected alsa on hw:2,0 after %+v, got %s on %s", o, v.output, v.device)
+		}
+	}
+
+	if err := v.setAudioOutput(outputChange{output: "alsa"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.device != "hw:2,0" {
+		t.Errorf("Expected the device to be kept with the module, got %q", v.device)
+	}
+}
@@ -9,64 +9,149 @@ import (
 
 	"fmt"
 
+	"gngeorgiev/audiotic/server/hub"
 	"gngeorgiev/audiotic/server/models"
 
 	"runtime"
 
 	"reflect"
 
-	vlc "github.com/adrg/libvlc-go"
+	vlc "github.com/adrg/libvlc-go/v3"
 	"github.com/go-errors/errors"
 )
 
+// libvlcPlayer is the part of the libvlc player the zones use, it's faked in the tests
+type libvlcPlayer interface {
+	Play() error
+	IsPlaying() bool
+	Stop() error
+	SetPause(pause bool) error
+	SetVolume(volume int) error
+	LoadMediaFromURL(url string) (*vlc.Media, error)
+	MediaLength() (int, error)
+	MediaTime() (int, error)
+	SetMediaTime(t int) error
+	MediaState() (vlc.MediaState, error)
+	SetPlaybackRate(rate float32) error
+	SetEqualizer(e *vlc.Equalizer) error
+	SetAudioOutput(output string) error
+	SetAudioOutputDevice(device, output string) error
+	AudioOutputDevices() ([]*vlc.AudioOutputDevice, error)
+	Release() error
+}
+
 type VlcPlayer struct {
-	player *vlc.Player
+	player libvlcPlayer
+	zone   string
 
 	source, name, thumbnail string
+	output, device          string
+	streamOutput            string
+	listeners               int
 	duration, time, volume  int
-	state                   vlc.MediaState
-	isPlaying, mediaSet     bool
-	track                   models.Track
-	statsMutex              sync.Mutex
-
-	startedPlayingChan chan models.Track
+	fadeLength              time.Duration
+	gain, rate, fadeLevel   float64
+	equalizer               *Equalizer
+	fading                  *fading
+	timers                  []Timer
+	lyricsSource            string
+	lyricsTimes             []int
+	// mediaTime is the time in milliseconds, the status has it in whole seconds
+	mediaTime           int
+	state               vlc.MediaState
+	isPlaying, mediaSet bool
+	track               models.Track
+	statsMutex          sync.Mutex
+
+	startedPlayingChan chan playRequest
 	stoppedPlayingChah chan struct{}
 	pausedPlayingChan  chan struct{}
 	seekChan           chan int
 	volumeChan         chan int
+	rateChan           chan float64
+	fadeChan           chan fade
+	outputChan         chan outputChange
+	outputsChan        chan chan outputsReply
+	gainChan           chan float64
+	equalizerChan      chan *Equalizer
 	resumePlayingChan  chan struct{}
 	releaseChan        chan struct{}
+	released           chan struct{}
 
-	onUpdatedChansMutex sync.Mutex
-	onUpdatedChans      []chan *VlcStatus
-	statusOnLastUpdate  *VlcStatus
+	updates            *hub.Hub
+	lastUpdateMutex    sync.Mutex
+	statusOnLastUpdate *VlcStatus
 }
 
 var (
-	oncePlayer          sync.Once
-	player              *VlcPlayer
+	// ErrReleased is returned by the players of removed zones
+	ErrReleased = errors.New("The player was released")
+
+	vlcMutex sync.Mutex
+	vlcUsers int
+
 	updatesInterval     = 500 * time.Millisecond
+	maxVlcVolume        = 200
 	maxWaitStateTimeout = 60 * time.Second
 )
 
 func (v *VlcPlayer) init() error {
-	v.startedPlayingChan = make(chan models.Track)
+	v.startedPlayingChan = make(chan playRequest)
 	v.stoppedPlayingChah = make(chan struct{})
 	v.pausedPlayingChan = make(chan struct{})
 	v.resumePlayingChan = make(chan struct{})
 	v.volumeChan = make(chan int)
+	v.rateChan = make(chan float64)
+	v.fadeChan = make(chan fade)
+	v.outputChan = make(chan outputChange)
+	v.outputsChan = make(chan chan outputsReply)
+	v.gainChan = make(chan float64)
+	v.equalizerChan = make(chan *Equalizer)
 	v.seekChan = make(chan int)
 	v.releaseChan = make(chan struct{})
-	v.onUpdatedChans = make([]chan *VlcStatus, 0)
+	v.released = make(chan struct{})
+	v.updates = hub.New(v.zone + " player")
 	v.volume = 100
+	v.rate = 1
+	v.fadeLevel = 1
+	v.fadeLength = defaultFadeLength
+	v.timers = make([]Timer, 0)
 
 	go v.eventLoop()
 
 	return nil
 }
 
+// acquireVlc initializes libvlc for the first player, the players of all zones share it
+func acquireVlc() error {
+	vlcMutex.Lock()
+	defer vlcMutex.Unlock()
+
+	if vlcUsers == 0 {
+		// time stretching keeps the pitch when the rate changes
+		if err := vlc.Init("--no-video", "--audio-time-stretch"); err != nil {
+			return err
+		}
+	}
+
+	vlcUsers++
+	return nil
+}
+
+func releaseVlc() error {
+	vlcMutex.Lock()
+	defer vlcMutex.Unlock()
+
+	vlcUsers--
+	if vlcUsers == 0 {
+		return vlc.Release()
+	}
+
+	return nil
+}
+
 func (v *VlcPlayer) initInternals() error {
-	if err := vlc.Init("--no-video"); err != nil {
+	if err := acquireVlc(); err != nil {
 		return err
 	}
 
@@ -90,26 +175,26 @@ func (v *VlcPlayer) eventLoop() {
 		select {
 		case <-t.C:
 			v.update(t)
-		case track := <-v.startedPlayingChan:
-			if err := v.play(track); err != nil {
+		case r := <-v.startedPlayingChan:
+			if err := v.changeTrack(r.track, r.options); err != nil {
 				log.Println(err)
 			}
 
 			v.update(t)
 		case <-v.stoppedPlayingChah:
-			if err := v.stop(); err != nil {
+			if err := v.fadedStop(); err != nil {
 				log.Println(err)
 			}
 
 			v.update(t)
 		case <-v.pausedPlayingChan:
-			if err := v.pause(); err != nil {
+			if err := v.fadedPause(); err != nil {
 				log.Println(err)
 			}
 
 			v.update(t)
 		case <-v.resumePlayingChan:
-			if err := v.resume(); err != nil {
+			if err := v.fadedResume(); err != nil {
 				log.Println(err)
 			}
 
@@ -120,6 +205,48 @@ func (v *VlcPlayer) eventLoop() {
 			}
 
 			v.update(t)
+		case gain := <-v.gainChan:
+			if err := v.setGain(gain); err != nil {
+				log.Println(err)
+			}
+
+			v.update(t)
+		case e := <-v.equalizerChan:
+			if err := v.setEqualizer(e); err != nil {
+				log.Println(err)
+			}
+
+			v.update(t)
+		case o := <-v.outputChan:
+			if err := v.setAudioOutput(o); err != nil {
+				log.Println(err)
+			}
+
+			v.update(t)
+		case reply := <-v.outputsChan:
+			outputs, err := v.audioOutputs()
+			reply <- outputsReply{outputs, err}
+		case rate := <-v.rateChan:
+			if err := v.setRate(rate); err != nil {
+				log.Println(err)
+			}
+
+			v.update(t)
+		case f := <-v.fadeChan:
+			if err := v.startFade(f); err != nil {
+				log.Println(err)
+			}
+
+			v.update(t)
+		case <-v.fadeTicks():
+			done, err := v.stepFade()
+			if err != nil {
+				log.Println(err)
+			}
+
+			if done {
+				v.update(t)
+			}
 		case pos := <-v.seekChan:
 			if err := v.seek(pos); err != nil {
 				log.Println(err)
@@ -127,6 +254,7 @@ func (v *VlcPlayer) eventLoop() {
 
 			v.update(t)
 		case <-v.releaseChan:
+			v.cancelFade()
 			if err := v.release(); err != nil {
 				log.Println(err)
 			}
@@ -137,15 +265,13 @@ func (v *VlcPlayer) eventLoop() {
 }
 
 func (v *VlcPlayer) notifyUpdated() {
-	v.onUpdatedChansMutex.Lock()
-	defer v.onUpdatedChansMutex.Unlock()
+	v.lastUpdateMutex.Lock()
+	defer v.lastUpdateMutex.Unlock()
 
 	payload, _ := v.Status()
 	if !reflect.DeepEqual(v.statusOnLastUpdate, payload) {
 		v.statusOnLastUpdate = payload
-		for _, ch := range v.onUpdatedChans {
-			ch <- payload
-		}
+		v.updates.Publish(payload)
 	}
 }
 
@@ -161,7 +287,7 @@ func (v *VlcPlayer) waitForMediaState(targetStates ...vlc.MediaState) error {
 		select {
 		case <-timeoutTimer.C:
 			v.Stop()
-			return errors.New(fmt.Sprintf("Timeout waiting for state %s", targetStates))
+			return errors.New(fmt.Sprintf("Timeout waiting for state %v", targetStates))
 		case <-updateTicker.C:
 			playerState, err := v.player.MediaState()
 			if err != nil {
@@ -0,0 +1,25 @@
+package player
+
+// SetStreamOutput sets the libvlc sout chain every track is played through, next to the local output,
+// it's used from the next track. An empty chain plays the tracks only locally
+func (v *VlcPlayer) SetStreamOutput(sout string) {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	v.streamOutput = sout
+}
+
+// SetListeners sets the number of clients listening to the stream of the player, shown in the status
+func (v *VlcPlayer) SetListeners(n int) {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	v.listeners = n
+}
+
+func (v *VlcPlayer) Listeners() int {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	return v.listeners
+}
@@ -0,0 +1,26 @@
+package player
+
+import "time"
+
+// Timer is a scheduled job of the zone, e.g. a sleep timer, shown in the status while it's active
+type Timer struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+	// At is when the timer fires, nil when it fires at the end of the current track
+	At *time.Time `json:"at,omitempty"`
+}
+
+// SetTimers replaces the timers shown in the status, the change is pushed with the next update
+func (v *VlcPlayer) SetTimers(timers []Timer) {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	v.timers = timers
+}
+
+func (v *VlcPlayer) Timers() []Timer {
+	v.statsMutex.Lock()
+	defer v.statsMutex.Unlock()
+
+	return v.timers
+}
@@ -1,25 +1,29 @@
 package player
 
-import vlc "github.com/adrg/libvlc-go"
+import (
+	"gngeorgiev/audiotic/server/models"
+
+	vlc "github.com/adrg/libvlc-go/v3"
+)
 
 func MediaStateToString(st vlc.MediaState) string {
 	switch st {
 	case vlc.MediaPlaying:
-		return "playing"
+		return models.StatePlaying
 	case vlc.MediaBuffering:
-		return "buffering"
+		return models.StateBuffering
 	case vlc.MediaEnded:
-		return "ended"
+		return models.StateEnded
 	case vlc.MediaError:
-		return "error"
-	case vlc.MediaIdle:
-		return "idle"
+		return models.StateError
+	case vlc.MediaNothingSpecial:
+		return models.StateIdle
 	case vlc.MediaOpening:
-		return "openning"
+		return models.StateOpening
 	case vlc.MediaPaused:
-		return "paused"
+		return models.StatePaused
 	case vlc.MediaStopped:
-		return "stopped"
+		return models.StateStopped
 	default:
 		return "unknown"
 	}
@@ -1,12 +1,36 @@
 package player
 
 type VlcStatus struct {
-	Duration  int    `json:"length"`
-	Time      int    `json:"time"`
-	Volume    int    `json:"volume"`
-	Name      string `json:"name"`
-	Source    string `json:"source"`
-	State     string `json:"state"`
-	Thumbnail string `json:"thumbnail"`
-	IsPlaying bool   `json:"isPlaying"`
+	Zone     string `json:"zone"`
+	Duration int    `json:"length"`
+	Time     int    `json:"time"`
+	Volume   int    `json:"volume"`
+	// Gain is the loudness normalization gain in dB applied on top of the volume
+	Gain      float64 `json:"gain"`
+	Rate      float64 `json:"rate"`
+	Name      string  `json:"name"`
+	Source    string  `json:"source"`
+	State     string  `json:"state"`
+	Thumbnail string  `json:"thumbnail"`
+	IsPlaying bool    `json:"isPlaying"`
+	// QueuedBy is the user who queued the track, for autoplayed tracks it's the user whose profile picked it
+	QueuedBy   string `json:"queuedBy"`
+	Autoplayed bool   `json:"autoplayed"`
+	// Artist, Album and CleanTitle are the metadata of the track, Name is its raw title
+	Artist     string `json:"artist"`
+	Album      string `json:"album"`
+	CleanTitle string `json:"cleanTitle"`
+	// Output and OutputDevice are empty while the libvlc defaults are used
+	Output       string `json:"output"`
+	OutputDevice string `json:"outputDevice"`
+	// Equalizer is nil while the equalizer is off
+	Equalizer *Equalizer `json:"equalizer"`
+	// Listeners is the number of clients listening to the http stream of the zone
+	Listeners int `json:"listeners"`
+	// FadeLength is the length of the ramps around pause, resume, stop and track changes in milliseconds
+	FadeLength int `json:"fadeLength"`
+	// LyricsLine is the index of the current line of the synced lyrics, -1 without synced lyrics and before the first line
+	LyricsLine int `json:"lyricsLine"`
+	// Timers are the active sleep timers and alarms of the zone
+	Timers []Timer `json:"timers"`
 }
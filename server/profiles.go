@@ -0,0 +1,192 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/profiles"
+	"net/http"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+type profileRequest struct {
+	AutoplaySeed string `json:"autoplaySeed"`
+}
+
+func registerProfileRoutes(g *gin.RouterGroup) {
+	listener := auth.Require(auth.RoleListener)
+	controller := auth.Require(auth.RoleController)
+
+	me := g.Group("/me")
+	{
+		me.GET("/profile", listener, v2GetProfileHandler())
+		me.PUT("/profile", listener, v2UpdateProfileHandler())
+		me.GET("/history", listener, v2UserHistoryHandler())
+		me.GET("/stats", listener, v2UserStatsHandler())
+		me.GET("/favorites", listener, v2FavoritesHandler())
+		me.POST("/favorites", controller, v2AddFavoriteHandler())
+		me.DELETE("/favorites/:provider/:id", controller, v2RemoveFavoriteHandler())
+	}
+
+	room := g.Group("/room")
+	{
+		room.GET("/history", listener, v2RoomHistoryHandler())
+		room.GET("/stats", listener, v2RoomStatsHandler())
+	}
+}
+
+func v2GetProfileHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := profiles.Get(auth.CallerOf(c).Name)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func v2UpdateProfileHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req profileRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if !profiles.IsValidSeed(req.AutoplaySeed) {
+			abortWithApiError(c, &api.Error{
+				Code:    api.CodeInvalidArgument,
+				Message: "Unknown autoplay seed " + req.AutoplaySeed,
+				Details: map[string]interface{}{"field": "autoplaySeed", "seeds": profiles.Seeds()},
+			})
+			return
+		}
+
+		p := profiles.Profile{
+			Name:         auth.CallerOf(c).Name,
+			AutoplaySeed: req.AutoplaySeed,
+		}
+
+		if err := profiles.Update(p); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2UserHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plays, err := history.UserPlays(profiles.Name(auth.CallerOf(c).Name))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, plays)
+	}
+}
+
+func v2UserStatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s, err := profiles.UserStats(auth.CallerOf(c).Name)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+func v2FavoritesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, err := profiles.Favorites(auth.CallerOf(c).Name)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, f)
+	}
+}
+
+func v2AddFavoriteHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req playRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Provider == "" {
+			abortWithApiError(c, missingField("provider"))
+			return
+		}
+
+		if req.ID == "" {
+			abortWithApiError(c, missingField("id"))
+			return
+		}
+
+		t, err := api.Resolve(req.Provider, req.ID)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		f, err := profiles.AddFavorite(auth.CallerOf(c).Name, t)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, f)
+	}
+}
+
+func v2RemoveFavoriteHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, id := c.Param("provider"), c.Param("id")
+		if err := profiles.RemoveFavorite(auth.CallerOf(c).Name, provider, id); err != nil {
+			if err == history.ErrNotFound {
+				err = &api.Error{
+					Code:    api.CodeNotFound,
+					Message: "Track is not a favorite",
+					Details: map[string]interface{}{"provider": provider, "id": id},
+				}
+			}
+
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2RoomHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plays, err := history.Plays()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, plays)
+	}
+}
+
+func v2RoomStatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s, err := profiles.RoomStats()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, s)
+	}
+}
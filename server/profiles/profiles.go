@@ -0,0 +1,271 @@
+package profiles
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/models"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	// Anonymous owns the profile used when auth is disabled
+	Anonymous = "anonymous"
+
+	// SeedProvider lets autoplay follow the suggestions of the provider
+	SeedProvider = "provider"
+	// SeedFavorites lets autoplay pick one of the user's favorites
+	SeedFavorites = "favorites"
+	// SeedHistory lets autoplay pick one of the user's most played tracks
+	SeedHistory = "history"
+
+	topTracksCount = 10
+)
+
+var (
+	db *storm.DB
+
+	seeds = []string{SeedProvider, SeedFavorites, SeedHistory}
+)
+
+type Profile struct {
+	Name         string `json:"name" storm:"id"`
+	AutoplaySeed string `json:"autoplaySeed"`
+}
+
+type Favorite struct {
+	ID      string       `json:"-" storm:"id"`
+	User    string       `json:"user" storm:"index"`
+	Track   models.Track `json:"track"`
+	AddedAt time.Time    `json:"addedAt"`
+}
+
+type TrackStats struct {
+	Track     models.Track `json:"track"`
+	PlayCount int          `json:"playCount"`
+}
+
+type Stats struct {
+	User         string       `json:"user"`
+	TotalPlays   int          `json:"totalPlays"`
+	UniqueTracks int          `json:"uniqueTracks"`
+	Favorites    int          `json:"favorites"`
+	LastPlayed   time.Time    `json:"lastPlayed"`
+	TopTracks    []TrackStats `json:"topTracks"`
+}
+
+type byPlayCount []TrackStats
+
+func (s byPlayCount) Len() int           { return len(s) }
+func (s byPlayCount) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byPlayCount) Less(i, j int) bool { return s[i].PlayCount > s[j].PlayCount }
+
+type byTotalPlays []Stats
+
+func (s byTotalPlays) Len() int           { return len(s) }
+func (s byTotalPlays) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTotalPlays) Less(i, j int) bool { return s[i].TotalPlays > s[j].TotalPlays }
+
+func Init() error {
+	db = database.Get()
+	if err := db.Init(Profile{}); err != nil {
+		return err
+	}
+
+	return db.Init(Favorite{})
+}
+
+// Name maps the name of an authenticated user to its profile, requests without a user share the anonymous profile
+func Name(user string) string {
+	if user == "" {
+		return Anonymous
+	}
+
+	return user
+}
+
+func Seeds() []string {
+	return seeds
+}
+
+func IsValidSeed(seed string) bool {
+	for _, s := range seeds {
+		if s == seed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func Get(user string) (Profile, error) {
+	p := Profile{
+		Name:         Name(user),
+		AutoplaySeed: SeedProvider,
+	}
+
+	if err := db.One("Name", p.Name, &p); err != nil && err != storm.ErrNotFound {
+		return Profile{}, err
+	}
+
+	return p, nil
+}
+
+func Update(p Profile) error {
+	p.Name = Name(p.Name)
+	return db.Save(&p)
+}
+
+func favoriteID(user, provider, id string) string {
+	return strings.Join([]string{Name(user), strings.ToLower(provider), id}, "/")
+}
+
+func Favorites(user string) ([]Favorite, error) {
+	var res []Favorite
+	if err := db.Find("User", Name(user), &res); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if res == nil {
+		res = make([]Favorite, 0)
+	}
+
+	return res, nil
+}
+
+func AddFavorite(user string, t models.Track) (Favorite, error) {
+	t.StreamUrl = ""
+	f := Favorite{
+		ID:      favoriteID(user, t.Provider, t.ID),
+		User:    Name(user),
+		Track:   t,
+		AddedAt: time.Now(),
+	}
+
+	return f, db.Save(&f)
+}
+
+// RemoveFavorite returns storm.ErrNotFound when the track is not a favorite
+func RemoveFavorite(user, provider, id string) error {
+	var f Favorite
+	if err := db.One("ID", favoriteID(user, provider, id), &f); err != nil {
+		return err
+	}
+
+	// the id isn't encoded, the loaded favorite doesn't have it
+	f.ID = favoriteID(user, provider, id)
+	return db.Remove(&f)
+}
+
+func statsOf(user string, plays []history.Play) Stats {
+	s := Stats{
+		User:      user,
+		TopTracks: make([]TrackStats, 0, len(plays)),
+	}
+
+	for _, p := range plays {
+		s.TotalPlays += p.PlayCount
+		s.UniqueTracks++
+		if p.LastPlayed.After(s.LastPlayed) {
+			s.LastPlayed = p.LastPlayed
+		}
+
+		s.TopTracks = append(s.TopTracks, TrackStats{Track: p.Track, PlayCount: p.PlayCount})
+	}
+
+	sort.Stable(byPlayCount(s.TopTracks))
+	if len(s.TopTracks) > topTracksCount {
+		s.TopTracks = s.TopTracks[:topTracksCount]
+	}
+
+	return s
+}
+
+func UserStats(user string) (Stats, error) {
+	name := Name(user)
+	plays, err := history.UserPlays(name)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	favorites, err := Favorites(name)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	s := statsOf(name, plays)
+	s.Favorites = len(favorites)
+	return s, nil
+}
+
+// RoomStats returns the stats of every user who played something, the most active first
+func RoomStats() ([]Stats, error) {
+	plays, err := history.Plays()
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[string][]history.Play)
+	for _, p := range plays {
+		byUser[p.User] = append(byUser[p.User], p)
+	}
+
+	res := make([]Stats, 0, len(byUser))
+	for user, userPlays := range byUser {
+		res = append(res, statsOf(user, userPlays))
+	}
+
+	sort.Sort(byTotalPlays(res))
+
+	return res, nil
+}
+
+// SeedTrack picks the next track for autoplay from the profile of the user, false is returned
+// when the profile leaves it to the provider or there is nothing to pick from
+func SeedTrack(user string, current models.Track) (models.Track, bool) {
+	p, err := Get(user)
+	if err != nil {
+		return models.Track{}, false
+	}
+
+	candidates := make([]models.Track, 0)
+	switch p.AutoplaySeed {
+	case SeedFavorites:
+		favorites, err := Favorites(user)
+		if err != nil {
+			return models.Track{}, false
+		}
+
+		for _, f := range favorites {
+			candidates = append(candidates, f.Track)
+		}
+	case SeedHistory:
+		s, err := UserStats(user)
+		if err != nil {
+			return models.Track{}, false
+		}
+
+		for _, t := range s.TopTracks {
+			candidates = append(candidates, t.Track)
+		}
+	default:
+		return models.Track{}, false
+	}
+
+	filtered := candidates[:0]
+	for _, t := range candidates {
+		if t.ID != current.ID || !strings.EqualFold(t.Provider, current.Provider) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return models.Track{}, false
+	}
+
+	return filtered[rand.Intn(len(filtered))], true
+}
@@ -0,0 +1,255 @@
+package profiles
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/history"
+	"gngeorgiev/audiotic/server/models"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Open(filepath.Join(dir, "db.db")); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := history.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	database.Release()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var epoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func track(id string) models.Track {
+	return models.Track{ID: id, Provider: "YouTube", Title: "Track " + id, StreamUrl: "http://stream/" + id}
+}
+
+func play(t *testing.T, user string, id string, minute int) {
+	tr := track(id)
+	tr.LastPlayed = epoch.Add(time.Duration(minute) * time.Minute)
+	if err := history.AddPlay(user, tr); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDefaultsToTheProviderSeed(t *testing.T) {
+	p, err := Get(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != t.Name() || p.AutoplaySeed != SeedProvider {
+		t.Errorf("Expected a profile seeded by the provider, got %+v", p)
+	}
+
+	if err := Update(Profile{Name: t.Name(), AutoplaySeed: SeedFavorites}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err = Get(t.Name()); err != nil || p.AutoplaySeed != SeedFavorites {
+		t.Errorf("Expected the updated seed, got %+v %v", p, err)
+	}
+}
+
+func TestRequestsWithoutAUserShareTheAnonymousProfile(t *testing.T) {
+	if err := Update(Profile{AutoplaySeed: SeedHistory}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != Anonymous || p.AutoplaySeed != SeedHistory {
+		t.Errorf("Expected the anonymous profile, got %+v", p)
+	}
+
+	if err := Update(Profile{AutoplaySeed: SeedProvider}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFavoritesBelongToTheirUser(t *testing.T) {
+	user, other := t.Name()+"-user", t.Name()+"-other"
+	if _, err := AddFavorite(user, track("fav-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AddFavorite(user, track("fav-b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AddFavorite(other, track("fav-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	favorites, err := Favorites(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(favorites) != 2 {
+		t.Fatalf("Expected 2 favorites, got %v", favorites)
+	}
+
+	for _, f := range favorites {
+		if f.User != user || f.Track.StreamUrl != "" {
+			t.Errorf("Expected a favorite of the user without the stream url, got %+v", f)
+		}
+	}
+
+	if err := RemoveFavorite(user, "youtube", "fav-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveFavorite(user, "youtube", "fav-a"); err != storm.ErrNotFound {
+		t.Errorf("Expected the removed favorite not to be found, got %v", err)
+	}
+
+	if favorites, err = Favorites(user); err != nil || len(favorites) != 1 || favorites[0].Track.ID != "fav-b" {
+		t.Errorf("Expected only fav-b to be left, got %v %v", favorites, err)
+	}
+
+	if favorites, err = Favorites(other); err != nil || len(favorites) != 1 {
+		t.Errorf("Expected the favorite of the other user to be kept, got %v %v", favorites, err)
+	}
+}
+
+func TestUserStats(t *testing.T) {
+	user := t.Name()
+	play(t, user, "stats-a", 1)
+	play(t, user, "stats-b", 2)
+	play(t, user, "stats-b", 3)
+	play(t, user, "stats-b", 4)
+	if _, err := AddFavorite(user, track("stats-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := UserStats(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.User != user || s.TotalPlays != 4 || s.UniqueTracks != 2 || s.Favorites != 1 {
+		t.Errorf("Expected 4 plays of 2 tracks and a favorite, got %+v", s)
+	}
+
+	if !s.LastPlayed.Equal(epoch.Add(4 * time.Minute)) {
+		t.Errorf("Expected the last play at %v, got %v", epoch.Add(4*time.Minute), s.LastPlayed)
+	}
+
+	if len(s.TopTracks) != 2 || s.TopTracks[0].Track.ID != "stats-b" || s.TopTracks[0].PlayCount != 3 {
+		t.Errorf("Expected stats-b to be the top track, got %v", s.TopTracks)
+	}
+}
+
+func TestStatsKeepTheTopTracks(t *testing.T) {
+	plays := make([]history.Play, 0)
+	for i := 0; i < topTracksCount+5; i++ {
+		plays = append(plays, history.Play{Track: track(string(rune('a' + i))), PlayCount: i + 1})
+	}
+
+	s := statsOf("someone", plays)
+	if len(s.TopTracks) != topTracksCount {
+		t.Fatalf("Expected %d top tracks, got %d", topTracksCount, len(s.TopTracks))
+	}
+
+	if s.TopTracks[0].PlayCount != topTracksCount+5 || s.UniqueTracks != topTracksCount+5 {
+		t.Errorf("Expected the most played track first, got %+v", s.TopTracks[0])
+	}
+}
+
+func TestRoomStatsPutsTheMostActiveFirst(t *testing.T) {
+	quiet, active := t.Name()+"-quiet", t.Name()+"-active"
+	play(t, quiet, "room-a", 1)
+	play(t, active, "room-a", 2)
+	play(t, active, "room-b", 3)
+
+	stats, err := RoomStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	positions := make(map[string]int)
+	for i, s := range stats {
+		positions[s.User] = i
+	}
+
+	q, ok := positions[quiet]
+	a, ok2 := positions[active]
+	if !ok || !ok2 {
+		t.Fatalf("Expected the stats of both users, got %v", stats)
+	}
+
+	if a > q {
+		t.Errorf("Expected %s before %s, got %v", active, quiet, stats)
+	}
+}
+
+func TestSeedTrack(t *testing.T) {
+	user := t.Name()
+	current := track("seed-current")
+
+	if _, ok := SeedTrack(user, current); ok {
+		t.Error("Expected the provider seed to leave autoplay to the provider")
+	}
+
+	if err := Update(Profile{Name: user, AutoplaySeed: SeedFavorites}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := SeedTrack(user, current); ok {
+		t.Error("Expected nothing to be picked without favorites")
+	}
+
+	if _, err := AddFavorite(user, current); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := SeedTrack(user, current); ok {
+		t.Error("Expected the current track not to be picked again")
+	}
+
+	if _, err := AddFavorite(user, track("seed-favorite")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if tr, ok := SeedTrack(user, current); !ok || tr.ID != "seed-favorite" {
+			t.Fatalf("Expected the favorite to be picked, got %v %v", tr, ok)
+		}
+	}
+
+	if err := Update(Profile{Name: user, AutoplaySeed: SeedHistory}); err != nil {
+		t.Fatal(err)
+	}
+
+	play(t, user, "seed-current", 1)
+	play(t, user, "seed-played", 2)
+	for i := 0; i < 10; i++ {
+		if tr, ok := SeedTrack(user, current); !ok || tr.ID != "seed-played" {
+			t.Fatalf("Expected the played track to be picked, got %v %v", tr, ok)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// playerUrl is the player api of the web client, it returns the player response the watch page embeds
+	playerUrl = "https://www.youtube.com/youtubei/v1/player"
+
+	playerClientName    = "WEB"
+	playerClientVersion = "2.20240726.00.00"
+)
+
+type playerRequest struct {
+	VideoID string        `json:"videoId"`
+	Context playerContext `json:"context"`
+}
+
+type playerContext struct {
+	Client struct {
+		ClientName    string `json:"clientName"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+}
+
+// playerResponse is the part of the player response with the loudness, YouTube turns down the videos louder
+// than its reference by loudnessDb and doesn't boost the quieter ones
+type playerResponse struct {
+	PlayerConfig struct {
+		AudioConfig struct {
+			LoudnessDb *float64 `json:"loudnessDb"`
+		} `json:"audioConfig"`
+	} `json:"playerConfig"`
+}
+
+// fetchLoudness asks the player api at endpoint for the loudness of a video, nil is returned when it's unknown
+func fetchLoudness(client *http.Client, endpoint, id string) (*float64, error) {
+	req := playerRequest{VideoID: id}
+	req.Context.Client.ClientName = playerClientName
+	req.Context.Client.ClientVersion = playerClientVersion
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("YouTube player api responded with %s", res.Status)
+	}
+
+	return parseLoudness(res.Body)
+}
+
+// parseLoudness reads the loudness of a player response
+func parseLoudness(r io.Reader) (*float64, error) {
+	var res playerResponse
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, err
+	}
+
+	return res.PlayerConfig.AudioConfig.LoudnessDb, nil
+}
@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseLoudness(t *testing.T) {
+	for body, expected := range map[string]*float64{
+		`{"playerConfig": {"audioConfig": {"loudnessDb": -4.25, "perceptualLoudnessDb": -18.25}}}`: floatPtr(-4.25),
+		`{"playerConfig": {"audioConfig": {"loudnessDb": 0}}}`:                                     floatPtr(0),
+		`{"playerConfig": {"audioConfig": {}}}`:                                                    nil,
+		`{"playabilityStatus": {"status": "LOGIN_REQUIRED"}}`:                                      nil,
+	} {
+		l, err := parseLoudness(strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (l == nil) != (expected == nil) || (l != nil && *l != *expected) {
+			t.Errorf("Expected %v for %s, got %v", expected, body, l)
+		}
+	}
+
+	if _, err := parseLoudness(strings.NewReader("<html>")); err == nil {
+		t.Error("Expected a page which isn't a player response to fail")
+	}
+}
+
+func TestFetchLoudnessAsksThePlayerApi(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req playerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPost {
+			t.Errorf("Expected a player request, got %s %v", r.Method, err)
+		}
+
+		if req.VideoID != "abc" || req.Context.Client.ClientName != playerClientName {
+			t.Errorf("Expected the request of abc by the web client, got %+v", req)
+		}
+
+		w.Write([]byte(`{"playerConfig": {"audioConfig": {"loudnessDb": 3.5}}}`))
+	}))
+	defer server.Close()
+
+	l, err := fetchLoudness(server.Client(), server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l == nil || *l != 3.5 {
+		t.Errorf("Expected the loudness 3.5, got %v", l)
+	}
+}
+
+func TestFetchLoudnessFailsOnErrorResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	if _, err := fetchLoudness(server.Client(), server.URL, "abc"); err == nil {
+		t.Error("Expected an error response to fail")
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
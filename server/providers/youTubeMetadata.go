@@ -0,0 +1,38 @@
+package providers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// topicSuffix ends the names of the channels YouTube generates for the artists, their videos are titled after the song
+const topicSuffix = " - Topic"
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// topicArtist returns the artist of a generated channel, it's empty for the other channels
+func topicArtist(channelTitle string) string {
+	if !strings.HasSuffix(channelTitle, topicSuffix) {
+		return ""
+	}
+
+	return strings.TrimSuffix(channelTitle, topicSuffix)
+}
+
+// parseISODuration parses the durations of the videos, like PT4M13S, into seconds, it's 0 for invalid durations
+func parseISODuration(s string) int {
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+
+	res := 0
+	for i, unit := range []int{24 * 60 * 60, 60 * 60, 60, 1} {
+		if n, err := strconv.Atoi(m[i+1]); err == nil {
+			res += n * unit
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,28 @@
+package providers
+
+import "testing"
+
+func TestParseISODuration(t *testing.T) {
+	for in, expected := range map[string]int{
+		"PT4M13S":  253,
+		"PT1H2M3S": 3723,
+		"PT45S":    45,
+		"P1DT1S":   86401,
+		"P0D":      0,
+		"4:13":     0,
+	} {
+		if d := parseISODuration(in); d != expected {
+			t.Errorf("Expected %d for %s, got %d", expected, in, d)
+		}
+	}
+}
+
+func TestTopicArtist(t *testing.T) {
+	if a := topicArtist("Daft Punk - Topic"); a != "Daft Punk" {
+		t.Errorf("Expected Daft Punk, got %s", a)
+	}
+
+	if a := topicArtist("DaftPunkVEVO"); a != "" {
+		t.Errorf("Expected no artist, got %s", a)
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 	"errors"
 
+	"time"
+
 	"github.com/otium/ytdl"
 	"google.golang.org/api/googleapi/transport"
 	"google.golang.org/api/youtube/v3"
@@ -22,6 +24,9 @@ const (
 	name    = "YouTube"
 )
 
+// loudnessClient bounds the loudness lookups, the tracks are played at the unknown loudness rather than waiting
+var loudnessClient = &http.Client{Timeout: 3 * time.Second}
+
 func init() {
 	c := &http.Client{
 		Transport: &transport.APIKey{Key: apiKey},
@@ -100,6 +105,7 @@ func (y *YouTubeProvider) Search(q string) ([]models.Track, error) {
 			Provider:  y.GetName(),
 			Thumbnail: y.getThumbnailUrl(item.Snippet.Thumbnails),
 			Title:     item.Snippet.Title,
+			Artist:    topicArtist(item.Snippet.ChannelTitle),
 		}
 
 		if i < len(videos)-1 {
@@ -119,7 +125,7 @@ func (y *YouTubeProvider) GetUrlFromId(id string) string {
 }
 
 func (y *YouTubeProvider) getVideoInfo(id string) (video *youtube.Video, err error) {
-	call := y.service.Videos.List("snippet").Id(id).MaxResults(1)
+	call := y.service.Videos.List("snippet,contentDetails").Id(id).MaxResults(1)
 	r, callError := call.Do()
 	if callError != nil {
 		err = callError
@@ -160,6 +166,11 @@ func (y *YouTubeProvider) getStreamUrl(id string) (string, error) {
 	return downloadUrl.String(), nil
 }
 
+// getLoudness reads the loudness YouTube normalizes the video with, it's in dB relative to YouTube's reference
+func (y *YouTubeProvider) getLoudness(id string) (*float64, error) {
+	return fetchLoudness(loudnessClient, playerUrl, id)
+}
+
 func (y *YouTubeProvider) getNextVideo(id string) (string, error) {
 	res, err := y.GetService().Search.List("id").Type("video").SafeSearch("none").RelatedToVideoId(id).Do()
 	if err != nil {
@@ -177,7 +188,7 @@ func (y *YouTubeProvider) getNextVideo(id string) (string, error) {
 
 func (y *YouTubeProvider) Resolve(id string) (models.Track, error) {
 	wg := sync.WaitGroup{}
-	wg.Add(3)
+	wg.Add(4)
 	errs := make([]error, 0)
 
 	//TODO: cache these calls
@@ -217,17 +228,36 @@ func (y *YouTubeProvider) Resolve(id string) (models.Track, error) {
 		}
 	}()
 
+	// the loudness is optional, the track just isn't normalized without it
+	var loudness *float64
+	go func() {
+		defer wg.Done()
+
+		l, err := y.getLoudness(id)
+		if err == nil {
+			loudness = l
+		}
+	}()
+
 	wg.Wait()
 	if len(errs) > 0 {
 		return models.Track{}, errors.New(fmt.Sprintf("%s", errs))
 	}
 
-	return models.Track{
+	track := models.Track{
 		ID:        video.Id,
 		Provider:  y.GetName(),
 		StreamUrl: streamUrl,
 		Thumbnail: y.getThumbnailUrl(video.Snippet.Thumbnails),
 		Title:     video.Snippet.Title,
+		Artist:    topicArtist(video.Snippet.ChannelTitle),
 		Next:      nextVideo,
-	}, nil
+		Loudness:  loudness,
+	}
+
+	if video.ContentDetails != nil {
+		track.Duration = parseISODuration(video.ContentDetails.Duration)
+	}
+
+	return track, nil
 }
@@ -0,0 +1,94 @@
+package queue
+
+import (
+	"fmt"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/models"
+	"sync"
+
+	"github.com/go-errors/errors"
+)
+
+type Queue struct {
+	mutex   sync.Mutex
+	tracks  []models.Track
+	changes *hub.Hub
+}
+
+func New(name string) *Queue {
+	return &Queue{
+		tracks:  make([]models.Track, 0),
+		changes: hub.New(name + " queue"),
+	}
+}
+
+// Subscribe returns a channel receiving the whole queue as []models.Track every time it changes
+func (q *Queue) Subscribe(o hub.Options) (<-chan interface{}, func()) {
+	return q.changes.Subscribe(o)
+}
+
+func (q *Queue) Metrics() hub.Metrics {
+	return q.changes.Metrics()
+}
+
+func (q *Queue) snapshot() []models.Track {
+	res := make([]models.Track, len(q.tracks))
+	copy(res, q.tracks)
+	return res
+}
+
+func (q *Queue) changed() {
+	q.changes.Publish(q.snapshot())
+}
+
+func (q *Queue) Get() []models.Track {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.snapshot()
+}
+
+func (q *Queue) Add(t models.Track) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	t.StreamUrl = ""
+	q.tracks = append(q.tracks, t)
+	q.changed()
+}
+
+func (q *Queue) Remove(index int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if index < 0 || index >= len(q.tracks) {
+		return errors.New(fmt.Sprintf("Queue index %d out of range", index))
+	}
+
+	q.tracks = append(q.tracks[:index], q.tracks[index+1:]...)
+	q.changed()
+	return nil
+}
+
+func (q *Queue) Clear() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.tracks = make([]models.Track, 0)
+	q.changed()
+}
+
+// Pop removes and returns the first track of the queue, false is returned when the queue is empty
+func (q *Queue) Pop() (models.Track, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.tracks) == 0 {
+		return models.Track{}, false
+	}
+
+	t := q.tracks[0]
+	q.tracks = q.tracks[1:]
+	q.changed()
+	return t, true
+}
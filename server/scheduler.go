@@ -0,0 +1,65 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+func v2GetJobsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs, err := api.Jobs(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, jobs)
+	}
+}
+
+func v2CreateJobHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req api.JobRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Kind == "" {
+			abortWithApiError(c, missingField("kind"))
+			return
+		}
+
+		j, err := api.CreateJob(zoneName(c), req, auth.CallerOf(c).Name)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, j)
+	}
+}
+
+func v2CancelJobHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithApiError(c, &api.Error{
+				Code:    api.CodeInvalidArgument,
+				Message: "Id must be a number",
+				Details: map[string]interface{}{"field": "id"},
+			})
+			return
+		}
+
+		if err := api.CancelJob(zoneName(c), id); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/go-errors/errors"
+)
+
+const (
+	// KindSleep stops the playback of a zone at a time or after the current track
+	KindSleep = "sleep"
+	// KindAlarm starts playing in a zone at a time of the day
+	KindAlarm = "alarm"
+
+	clockLayout = "15:04"
+)
+
+var (
+	db *storm.DB
+
+	// ErrNotFound is returned for unknown jobs
+	ErrNotFound = storm.ErrNotFound
+	// ErrInvalidTime is returned for alarm times not in the 15:04 format
+	ErrInvalidTime = errors.New("Alarm times must be in the HH:MM format")
+
+	// Days are the names of the days of an alarm, indexed by time.Weekday
+	Days = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Track is a track played by an alarm
+type Track struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+// Job is a sleep timer or an alarm of a zone
+type Job struct {
+	ID   int    `json:"id" storm:"id,increment"`
+	Zone string `json:"zone" storm:"index"`
+	Kind string `json:"kind"`
+	// At is when the job fires next, it's zero for sleep timers firing at the end of the current track
+	At         time.Time `json:"at"`
+	AfterTrack bool      `json:"afterTrack,omitempty"`
+	// Fade is the length of the fade out of a sleep timer or of the ramp up of an alarm in seconds
+	Fade int `json:"fade"`
+	// Time and Days are when an alarm rings, an alarm without days rings every day
+	Time string   `json:"time,omitempty"`
+	Days []string `json:"days,omitempty"`
+	// Tracks are played by an alarm, the first one right away and the rest are queued
+	Tracks []Track `json:"tracks,omitempty"`
+	// Volume is set by an alarm before it starts playing, 0 keeps the volume of the zone
+	Volume    int       `json:"volume,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func Init() error {
+	db = database.Get()
+	return db.Init(Job{})
+}
+
+func All() ([]Job, error) {
+	var res []Job
+	if err := db.All(&res); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if res == nil {
+		res = make([]Job, 0)
+	}
+
+	return res, nil
+}
+
+// Save creates the job or updates an existing one, new jobs get their ID assigned
+func Save(j *Job) error {
+	return db.Save(j)
+}
+
+func Remove(id int) error {
+	var j Job
+	if err := db.One("ID", id, &j); err != nil {
+		return err
+	}
+
+	return db.Remove(&j)
+}
+
+// ParseTime returns the hour and the minute of an alarm time
+func ParseTime(s string) (int, int, error) {
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, 0, ErrInvalidTime
+	}
+
+	return t.Hour(), t.Minute(), nil
+}
+
+// IsDay tells whether s is one of Days
+func IsDay(s string) bool {
+	for _, d := range Days {
+		if d == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextAlarm returns the first time after t the alarm rings, in the local time zone
+func (j Job) NextAlarm(t time.Time) (time.Time, error) {
+	hour, minute, err := ParseTime(j.Time)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t = t.Local()
+	for i := 0; i <= len(Days); i++ {
+		day := t.AddDate(0, 0, i)
+		at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, time.Local)
+		if at.After(t) && j.ringsOn(at.Weekday()) {
+			return at, nil
+		}
+	}
+
+	return time.Time{}, errors.New("The alarm never rings")
+}
+
+func (j Job) ringsOn(d time.Weekday) bool {
+	if len(j.Days) == 0 {
+		return true
+	}
+
+	for _, day := range j.Days {
+		if day == Days[d] {
+			return true
+		}
+	}
+
+	return false
+}
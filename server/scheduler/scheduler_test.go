@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextAlarmSkipsDaysItDoesntRingOn(t *testing.T) {
+	// a friday evening
+	now := time.Date(2017, time.March, 3, 20, 0, 0, 0, time.Local)
+	j := Job{Time: "08:30", Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+
+	at, err := j.NextAlarm(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := time.Date(2017, time.March, 6, 8, 30, 0, 0, time.Local)
+	if !at.Equal(expected) {
+		t.Fatalf("Expected the alarm on monday %s, got %s", expected, at)
+	}
+}
+
+func TestNextAlarmRingsTodayWhenTheTimeIsAhead(t *testing.T) {
+	now := time.Date(2017, time.March, 3, 7, 0, 0, 0, time.Local)
+	j := Job{Time: "08:30"}
+
+	at, err := j.NextAlarm(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := time.Date(2017, time.March, 3, 8, 30, 0, 0, time.Local)
+	if !at.Equal(expected) {
+		t.Fatalf("Expected the alarm today %s, got %s", expected, at)
+	}
+}
+
+func TestParseTimeRejectsInvalidTimes(t *testing.T) {
+	for _, s := range []string{"8", "25:00", "08:61", "morning"} {
+		if _, _, err := ParseTime(s); err != ErrInvalidTime {
+			t.Fatalf("Expected %s to be rejected, got %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package scrobbler
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gngeorgiev/audiotic/server/config"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	lastFMURL = "https://ws.audioscrobbler.com/2.0/"
+
+	// the Last.fm errors about the scrobbles themselves, the other ones are about the account or the service
+	lastFMInvalidParameters = 6
+	lastFMInvalidSession    = 9
+)
+
+// lastFM scrobbles to the Last.fm api or a compatible one
+type lastFM struct {
+	url      string
+	apiKey   string
+	secret   string
+	username string
+	password string
+	client   *http.Client
+
+	mutex      sync.Mutex
+	sessionKey string
+}
+
+type lastFMResponse struct {
+	Error   int    `json:"error"`
+	Message string `json:"message"`
+	Session struct {
+		Key string `json:"key"`
+	} `json:"session"`
+}
+
+func newLastFM(c config.LastFMConfig) *lastFM {
+	l := &lastFM{
+		url:        c.URL,
+		apiKey:     c.APIKey,
+		secret:     c.Secret,
+		username:   c.Username,
+		password:   c.Password,
+		sessionKey: c.SessionKey,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if l.url == "" {
+		l.url = lastFMURL
+	}
+
+	return l
+}
+
+func (l *lastFM) Name() string {
+	return "lastfm"
+}
+
+// sign returns the signature of the params, the md5 of their sorted names and values followed by the secret
+func (l *lastFM) sign(params url.Values) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if name != "format" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteString(params.Get(name))
+	}
+	b.WriteString(l.secret)
+
+	sum := md5.Sum(b.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func (l *lastFM) call(method string, params url.Values) (lastFMResponse, error) {
+	params.Set("method", method)
+	params.Set("api_key", l.apiKey)
+	params.Set("api_sig", l.sign(params))
+	params.Set("format", "json")
+
+	var res lastFMResponse
+	r, err := l.client.PostForm(l.url, params)
+	if err != nil {
+		return res, err
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return res, err
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil || (res.Error == 0 && r.StatusCode != http.StatusOK) {
+		return res, &ServiceError{
+			Service:    l.Name(),
+			StatusCode: r.StatusCode,
+			Message:    fmt.Sprintf("%s responded with %s", method, r.Status),
+			Retryable:  r.StatusCode >= http.StatusInternalServerError || r.StatusCode == http.StatusTooManyRequests,
+		}
+	}
+
+	if res.Error != 0 {
+		if res.Error == lastFMInvalidSession && l.username != "" {
+			// a new session is requested with the next call
+			l.mutex.Lock()
+			l.sessionKey = ""
+			l.mutex.Unlock()
+		}
+
+		return res, &ServiceError{
+			Service:    l.Name(),
+			StatusCode: r.StatusCode,
+			Message:    fmt.Sprintf("%s failed with %d %s", method, res.Error, res.Message),
+			Retryable:  res.Error != lastFMInvalidParameters,
+		}
+	}
+
+	return res, nil
+}
+
+// session returns the session key, it's requested with the username and password when there's none
+func (l *lastFM) session() (string, error) {
+	l.mutex.Lock()
+	key := l.sessionKey
+	l.mutex.Unlock()
+	if key != "" || l.username == "" {
+		return key, nil
+	}
+
+	res, err := l.call("auth.getMobileSession", url.Values{"username": {l.username}, "password": {l.password}})
+	if err != nil {
+		return "", err
+	}
+
+	l.mutex.Lock()
+	l.sessionKey = res.Session.Key
+	l.mutex.Unlock()
+
+	return res.Session.Key, nil
+}
+
+func (l *lastFM) NowPlaying(listen Listen) error {
+	sk, err := l.session()
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{"sk": {sk}, "artist": {listen.Artist}, "track": {listen.Track}}
+	if listen.Duration > 0 {
+		params.Set("duration", strconv.Itoa(listen.Duration))
+	}
+
+	_, err = l.call("track.updateNowPlaying", params)
+	return err
+}
+
+func (l *lastFM) Scrobble(listens []Listen) error {
+	sk, err := l.session()
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{"sk": {sk}}
+	for i, listen := range listens {
+		suffix := "[" + strconv.Itoa(i) + "]"
+		params.Set("artist"+suffix, listen.Artist)
+		params.Set("track"+suffix, listen.Track)
+		params.Set("timestamp"+suffix, strconv.FormatInt(listen.Time.Unix(), 10))
+		if listen.Duration > 0 {
+			params.Set("duration"+suffix, strconv.Itoa(listen.Duration))
+		}
+	}
+
+	_, err = l.call("track.scrobble", params)
+	return err
+}
@@ -0,0 +1,145 @@
+package scrobbler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gngeorgiev/audiotic/server/config"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	listenBrainzURL = "https://api.listenbrainz.org"
+
+	listenPlayingNow = "playing_now"
+	listenSingle     = "single"
+	listenImport     = "import"
+)
+
+// listenBrainz submits the listens to the ListenBrainz api or a compatible one
+type listenBrainz struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+type listenBrainzListen struct {
+	ListenedAt int64 `json:"listened_at,omitempty"`
+	Metadata   struct {
+		Artist         string                 `json:"artist_name"`
+		Track          string                 `json:"track_name"`
+		AdditionalInfo map[string]interface{} `json:"additional_info"`
+	} `json:"track_metadata"`
+}
+
+type listenBrainzRequest struct {
+	ListenType string               `json:"listen_type"`
+	Payload    []listenBrainzListen `json:"payload"`
+}
+
+func newListenBrainz(c config.ListenBrainzConfig) *listenBrainz {
+	l := &listenBrainz{
+		url:    strings.TrimRight(c.URL, "/"),
+		token:  c.Token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if l.url == "" {
+		l.url = listenBrainzURL
+	}
+
+	return l
+}
+
+func (l *listenBrainz) Name() string {
+	return "listenbrainz"
+}
+
+func toListenBrainz(listen Listen, listenedAt bool) listenBrainzListen {
+	var res listenBrainzListen
+	if listenedAt {
+		res.ListenedAt = listen.Time.Unix()
+	}
+
+	res.Metadata.Artist = listen.Artist
+	res.Metadata.Track = listen.Track
+	res.Metadata.AdditionalInfo = map[string]interface{}{
+		"media_player":      "audiotic",
+		"submission_client": "audiotic",
+	}
+
+	if listen.Duration > 0 {
+		res.Metadata.AdditionalInfo["duration"] = listen.Duration
+	}
+
+	if listen.Provider == "youtube" && listen.ID != "" {
+		res.Metadata.AdditionalInfo["music_service"] = "youtube.com"
+		res.Metadata.AdditionalInfo["origin_url"] = "https://www.youtube.com/watch?v=" + listen.ID
+	}
+
+	return res
+}
+
+func (l *listenBrainz) submit(r listenBrainzRequest) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.url+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Token "+l.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var e struct {
+		Error string `json:"error"`
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	if json.Unmarshal(b, &e) != nil || e.Error == "" {
+		e.Error = res.Status
+	}
+
+	return &ServiceError{
+		Service:    l.Name(),
+		StatusCode: res.StatusCode,
+		Message:    fmt.Sprintf("%s listens rejected: %s", r.ListenType, e.Error),
+		// only the bad requests are about the listens, the other errors are about the token or the service
+		Retryable: res.StatusCode != http.StatusBadRequest,
+	}
+}
+
+func (l *listenBrainz) NowPlaying(listen Listen) error {
+	return l.submit(listenBrainzRequest{
+		ListenType: listenPlayingNow,
+		Payload:    []listenBrainzListen{toListenBrainz(listen, false)},
+	})
+}
+
+func (l *listenBrainz) Scrobble(listens []Listen) error {
+	r := listenBrainzRequest{ListenType: listenImport}
+	if len(listens) == 1 {
+		r.ListenType = listenSingle
+	}
+
+	for _, listen := range listens {
+		r.Payload = append(r.Payload, toListenBrainz(listen, true))
+	}
+
+	return l.submit(r)
+}
@@ -0,0 +1,57 @@
+package scrobbler
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+var db *storm.DB
+
+// Scrobble is a listen waiting to be submitted to a service
+type Scrobble struct {
+	ID        int       `json:"id" storm:"id,increment"`
+	Service   string    `json:"service" storm:"index"`
+	Listen    Listen    `json:"listen"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// queue keeps the scrobbles until the services accept them, it's the database outside of the tests
+type queue interface {
+	add(s *Scrobble) error
+	// pending returns the oldest scrobbles of a service
+	pending(service string, limit int) ([]Scrobble, error)
+	update(s *Scrobble) error
+	remove(s *Scrobble) error
+}
+
+func Init() error {
+	db = database.Get()
+	return db.Init(Scrobble{})
+}
+
+type dbQueue struct{}
+
+func (dbQueue) add(s *Scrobble) error {
+	return db.Save(s)
+}
+
+func (dbQueue) pending(service string, limit int) ([]Scrobble, error) {
+	var res []Scrobble
+	if err := db.Find("Service", service, &res, storm.Limit(limit)); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (dbQueue) update(s *Scrobble) error {
+	return db.Save(s)
+}
+
+func (dbQueue) remove(s *Scrobble) error {
+	return db.Remove(s)
+}
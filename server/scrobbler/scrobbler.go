@@ -0,0 +1,311 @@
+package scrobbler
+
+import (
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/metadata"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// minDuration is the length of the shortest track which is scrobbled, in seconds
+	minDuration = 30
+	// maxHeard is how long a track has to be heard to get scrobbled when half of it is longer, in seconds
+	maxHeard = 4 * 60
+	// maxStep is the largest advance of the time between two updates which counts as heard,
+	// larger ones are seeks
+	maxStep = 10
+
+	// batchSize is the most scrobbles submitted at once, it's the limit of Last.fm
+	batchSize = 50
+)
+
+var (
+	// minRetryDelay and maxRetryDelay bound the delay before submitting the queue again after a failure
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = 30 * time.Minute
+)
+
+// Listen is a track heard in a zone
+type Listen struct {
+	Artist string `json:"artist"`
+	Track  string `json:"track"`
+	// Duration is in seconds, 0 when it's unknown
+	Duration int `json:"duration"`
+	// Time is when the track started
+	Time     time.Time `json:"time"`
+	Provider string    `json:"provider"`
+	ID       string    `json:"id"`
+}
+
+// Service is a scrobbling service
+type Service interface {
+	Name() string
+	NowPlaying(l Listen) error
+	Scrobble(l []Listen) error
+}
+
+// ServiceError is an error response of a service, the scrobbles rejected by the services aren't retried
+type ServiceError struct {
+	Service    string
+	StatusCode int
+	Message    string
+	Retryable  bool
+}
+
+func (e *ServiceError) Error() string {
+	return e.Service + ": " + e.Message
+}
+
+// retryable tells whether a failed submission may succeed later, the ones failing without a response,
+// e.g. while offline, always are
+func retryable(err error) bool {
+	if e, ok := err.(*ServiceError); ok {
+		return e.Retryable
+	}
+
+	return true
+}
+
+// play is the track playing in a zone
+type play struct {
+	source    string
+	state     string
+	time      int
+	heard     int
+	listen    Listen
+	parsed    bool
+	scrobbled bool
+}
+
+// Scrobbler follows the status of the zones, sends the now playing tracks and scrobbles the heard ones.
+// The scrobbles are queued in the database until the services accept them
+type Scrobbler struct {
+	services []Service
+	queue    queue
+	zones    []string
+	now      func() time.Time
+
+	mutex sync.Mutex
+	plays map[string]*play
+
+	wake      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates a scrobbler for the services configured in c
+func New(c config.ScrobblerConfig) *Scrobbler {
+	var services []Service
+	if c.LastFM.APIKey != "" {
+		services = append(services, newLastFM(c.LastFM))
+	}
+
+	if c.ListenBrainz.Token != "" {
+		services = append(services, newListenBrainz(c.ListenBrainz))
+	}
+
+	return newScrobbler(services, dbQueue{}, c.Zones)
+}
+
+func newScrobbler(services []Service, q queue, zones []string) *Scrobbler {
+	return &Scrobbler{
+		services: services,
+		queue:    q,
+		zones:    zones,
+		now:      time.Now,
+		plays:    make(map[string]*play),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Wants tells whether the tracks of a zone are scrobbled
+func (s *Scrobbler) Wants(zone string) bool {
+	if len(s.zones) == 0 {
+		return true
+	}
+
+	for _, z := range s.zones {
+		if z == zone {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stopped tells whether nothing plays in a state
+// Update takes a status of a zone and the track it plays
+func (s *Scrobbler) Update(zone string, status *player.VlcStatus, track models.Track) {
+	if status == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p := s.plays[zone]
+	// a track played again after it ended is a new play too
+	if p == nil || p.source != status.Source || (p.state != "" && models.IsStoppedState(p.state) && !models.IsStoppedState(status.State)) {
+		p = &play{source: status.Source, time: status.Time}
+		// the tracks played through the api are enriched already, the other ones get their titles parsed
+		metadata.Fill(&track)
+		p.listen.Artist, p.listen.Track = track.Artist, track.CleanTitle
+		p.parsed = track.Artist != "" && track.CleanTitle != ""
+		p.listen.Time, p.listen.Provider, p.listen.ID = s.now(), track.Provider, track.ID
+		s.plays[zone] = p
+
+		if p.parsed && status.Source != "" && !models.IsStoppedState(status.State) {
+			p.listen.Duration = status.Duration
+			go s.nowPlaying(p.listen)
+		}
+	}
+
+	if status.State == models.StatePlaying && p.state == models.StatePlaying {
+		if step := status.Time - p.time; step > 0 && step <= maxStep {
+			p.heard += step
+		}
+	}
+
+	p.state, p.time = status.State, status.Time
+	if status.Duration > 0 {
+		p.listen.Duration = status.Duration
+	}
+
+	if !p.parsed || p.scrobbled || p.listen.Duration <= minDuration {
+		return
+	}
+
+	if p.heard >= p.listen.Duration/2 || p.heard >= maxHeard {
+		p.scrobbled = true
+		s.enqueue(p.listen)
+	}
+}
+
+// Forget drops the play of a removed zone
+func (s *Scrobbler) Forget(zone string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.plays, zone)
+}
+
+func (s *Scrobbler) nowPlaying(l Listen) {
+	for _, service := range s.services {
+		if err := service.NowPlaying(l); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (s *Scrobbler) enqueue(l Listen) {
+	for _, service := range s.services {
+		if err := s.queue.add(&Scrobble{Service: service.Name(), Listen: l, CreatedAt: s.now()}); err != nil {
+			log.Println(err)
+		}
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run submits the queued scrobbles, including the ones left from previous runs, until the scrobbler is closed.
+// After a failure the queue is submitted again after a growing delay
+func (s *Scrobbler) Run() {
+	delay := minRetryDelay
+	retrying := false
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+			// the new scrobbles wait for the retry of the ones before them
+			if retrying {
+				continue
+			}
+		case <-timer.C:
+		}
+
+		if s.flush() {
+			delay, retrying = minRetryDelay, false
+			continue
+		}
+
+		timer.Reset(delay)
+		retrying = true
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+func (s *Scrobbler) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// flush submits the queued scrobbles of every service, it returns false when some of them have to be retried
+func (s *Scrobbler) flush() bool {
+	res := true
+	for _, service := range s.services {
+		if !s.flushService(service) {
+			res = false
+		}
+	}
+
+	return res
+}
+
+func (s *Scrobbler) flushService(service Service) bool {
+	for {
+		pending, err := s.queue.pending(service.Name(), batchSize)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+
+		if len(pending) == 0 {
+			return true
+		}
+
+		listens := make([]Listen, len(pending))
+		for i := range pending {
+			listens[i] = pending[i].Listen
+		}
+
+		err = service.Scrobble(listens)
+		if err != nil && retryable(err) {
+			log.Println(err)
+			for i := range pending {
+				pending[i].Attempts++
+				pending[i].Error = err.Error()
+				if err := s.queue.update(&pending[i]); err != nil {
+					log.Println(err)
+				}
+			}
+
+			return false
+		}
+
+		if err != nil {
+			log.Printf("Dropping %d scrobbles: %s", len(pending), err)
+		}
+
+		for i := range pending {
+			if err := s.queue.remove(&pending[i]); err != nil {
+				log.Println(err)
+				return false
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package scrobbler
+
+import (
+	"errors"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryQueue struct {
+	mutex     sync.Mutex
+	id        int
+	scrobbles []Scrobble
+}
+
+func (q *memoryQueue) add(s *Scrobble) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.id++
+	s.ID = q.id
+	q.scrobbles = append(q.scrobbles, *s)
+	return nil
+}
+
+func (q *memoryQueue) pending(service string, limit int) ([]Scrobble, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var res []Scrobble
+	for _, s := range q.scrobbles {
+		if s.Service == service && len(res) < limit {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+func (q *memoryQueue) update(s *Scrobble) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i := range q.scrobbles {
+		if q.scrobbles[i].ID == s.ID {
+			q.scrobbles[i] = *s
+		}
+	}
+
+	return nil
+}
+
+func (q *memoryQueue) remove(s *Scrobble) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i := range q.scrobbles {
+		if q.scrobbles[i].ID == s.ID {
+			q.scrobbles = append(q.scrobbles[:i], q.scrobbles[i+1:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (q *memoryQueue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.scrobbles)
+}
+
+type fakeService struct {
+	mutex      sync.Mutex
+	err        error
+	nowPlaying chan Listen
+	scrobbled  []Listen
+}
+
+func newFakeService() *fakeService {
+	return &fakeService{nowPlaying: make(chan Listen, 10)}
+}
+
+func (f *fakeService) Name() string {
+	return "fake"
+}
+
+func (f *fakeService) NowPlaying(l Listen) error {
+	f.nowPlaying <- l
+	return nil
+}
+
+func (f *fakeService) Scrobble(l []Listen) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+
+	f.scrobbled = append(f.scrobbled, l...)
+	return nil
+}
+
+func (f *fakeService) setErr(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.err = err
+}
+
+func (f *fakeService) listens() []Listen {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Listen(nil), f.scrobbled...)
+}
+
+var track = models.Track{Provider: "youtube", ID: "abc", Title: "Daft Punk - Around the World (Official Video)"}
+
+// playFor sends the statuses of a track playing from second from to second to
+func playFor(s *Scrobbler, source string, duration, from, to int) {
+	for t := from; t <= to; t++ {
+		s.Update("default", &player.VlcStatus{Source: source, State: "playing", Time: t, Duration: duration}, track)
+	}
+}
+
+func TestScrobblesHalfOfTheTrack(t *testing.T) {
+	service, q := newFakeService(), &memoryQueue{}
+	s := newScrobbler([]Service{service}, q, nil)
+
+	playFor(s, "a", 200, 0, 99)
+	if q.len() != 0 {
+		t.Fatal("Expected no scrobble before half of the track")
+	}
+
+	select {
+	case l := <-service.nowPlaying:
+		if l.Artist != "Daft Punk" || l.Track != "Around the World" || l.Duration != 200 {
+			t.Errorf("Unexpected now playing %+v", l)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected now playing")
+	}
+
+	playFor(s, "a", 200, 100, 150)
+	if q.len() != 1 {
+		t.Fatalf("Expected a single scrobble, got %d", q.len())
+	}
+}
+
+func TestScrobblesAfterFourMinutes(t *testing.T) {
+	q := &memoryQueue{}
+	s := newScrobbler([]Service{newFakeService()}, q, nil)
+
+	playFor(s, "a", 3600, 0, maxHeard-1)
+	if q.len() != 0 {
+		t.Fatal("Expected no scrobble before four minutes")
+	}
+
+	playFor(s, "a", 3600, maxHeard, maxHeard)
+	if q.len() != 1 {
+		t.Fatalf("Expected a scrobble after four minutes, got %d", q.len())
+	}
+}
+
+func TestSeeksAndShortTracksArentScrobbled(t *testing.T) {
+	q := &memoryQueue{}
+	s := newScrobbler([]Service{newFakeService()}, q, nil)
+
+	playFor(s, "a", 200, 0, 10)
+	playFor(s, "a", 200, 190, 200)
+	if q.len() != 0 {
+		t.Error("Expected the seeked part not to count as heard")
+	}
+
+	playFor(s, "b", 20, 0, 20)
+	if q.len() != 0 {
+		t.Error("Expected tracks shorter than 30 seconds not to be scrobbled")
+	}
+}
+
+func TestRepeatedTrackIsScrobbledAgain(t *testing.T) {
+	q := &memoryQueue{}
+	s := newScrobbler([]Service{newFakeService()}, q, nil)
+
+	playFor(s, "a", 60, 0, 60)
+	s.Update("default", &player.VlcStatus{Source: "a", State: "ended", Time: 60, Duration: 60}, track)
+	playFor(s, "a", 60, 0, 60)
+
+	if q.len() != 2 {
+		t.Errorf("Expected two scrobbles, got %d", q.len())
+	}
+}
+
+func TestQueueIsRetriedUntilAccepted(t *testing.T) {
+	defer func(min, max time.Duration) { minRetryDelay, maxRetryDelay = min, max }(minRetryDelay, maxRetryDelay)
+	minRetryDelay, maxRetryDelay = 10*time.Millisecond, 20*time.Millisecond
+
+	service, q := newFakeService(), &memoryQueue{}
+	service.setErr(errors.New("offline"))
+
+	s := newScrobbler([]Service{service}, q, nil)
+	finished := make(chan struct{})
+	go func() {
+		s.Run()
+		close(finished)
+	}()
+	defer func() {
+		s.Close()
+		<-finished
+	}()
+
+	playFor(s, "a", 60, 0, 30)
+	time.Sleep(50 * time.Millisecond)
+	if q.len() != 1 || len(service.listens()) != 0 {
+		t.Fatal("Expected the scrobble to stay queued while the service fails")
+	}
+
+	service.setErr(nil)
+	deadline := time.Now().Add(time.Second)
+	for q.len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if l := service.listens(); len(l) != 1 || l[0].Artist != "Daft Punk" || q.len() != 0 {
+		t.Errorf("Expected the scrobble submitted once the service is back, got %+v", l)
+	}
+}
+
+func TestRejectedScrobblesAreDropped(t *testing.T) {
+	service, q := newFakeService(), &memoryQueue{}
+	service.setErr(&ServiceError{Service: "fake", Message: "invalid", Retryable: false})
+
+	s := newScrobbler([]Service{service}, q, nil)
+	q.add(&Scrobble{Service: "fake", Listen: Listen{Artist: "a", Track: "b"}})
+
+	if !s.flush() || q.len() != 0 {
+		t.Error("Expected the rejected scrobble to be dropped")
+	}
+}
@@ -0,0 +1,134 @@
+package scrobbler
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// lastFMStandIn answers like Last.fm, it checks the signatures and returns the failures it's given first
+func lastFMStandIn(t *testing.T, secret string, failures ...int) (*httptest.Server, chan url.Values) {
+	calls := make(chan url.Values, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		params := r.PostForm
+
+		sig := params.Get("api_sig")
+		params.Del("api_sig")
+		if expected := (&lastFM{secret: secret}).sign(params); sig != expected {
+			t.Errorf("Expected signature %s, got %s", expected, sig)
+		}
+
+		calls <- params
+
+		if len(failures) > 0 {
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": failures[0], "message": "failed"})
+			failures = failures[1:]
+			return
+		}
+
+		switch params.Get("method") {
+		case "auth.getMobileSession":
+			json.NewEncoder(w).Encode(map[string]interface{}{"session": map[string]string{"key": "session"}})
+		default:
+			w.Write([]byte(`{"scrobbles":{}}`))
+		}
+	}))
+
+	return server, calls
+}
+
+func TestLastFMScrobbles(t *testing.T) {
+	server, calls := lastFMStandIn(t, "secret")
+	defer server.Close()
+
+	l := newLastFM(config.LastFMConfig{URL: server.URL, APIKey: "key", Secret: "secret", Username: "user", Password: "pass"})
+	listens := []Listen{
+		{Artist: "Daft Punk", Track: "Around the World", Duration: 200, Time: time.Unix(100, 0)},
+		{Artist: "Queen", Track: "Bohemian Rhapsody", Time: time.Unix(400, 0)},
+	}
+
+	if err := l.Scrobble(listens); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := <-calls; p.Get("method") != "auth.getMobileSession" || p.Get("username") != "user" {
+		t.Errorf("Expected a session request first, got %v", p)
+	}
+
+	p := <-calls
+	if p.Get("method") != "track.scrobble" || p.Get("sk") != "session" || p.Get("api_key") != "key" {
+		t.Errorf("Unexpected scrobble %v", p)
+	}
+
+	if p.Get("artist[1]") != "Queen" || p.Get("timestamp[0]") != "100" || p.Get("duration[0]") != "200" || p.Get("duration[1]") != "" {
+		t.Errorf("Unexpected scrobble params %v", p)
+	}
+}
+
+func TestLastFMErrors(t *testing.T) {
+	server, _ := lastFMStandIn(t, "secret", 16, lastFMInvalidParameters)
+	defer server.Close()
+
+	l := newLastFM(config.LastFMConfig{URL: server.URL, APIKey: "key", Secret: "secret", SessionKey: "session"})
+	listens := []Listen{{Artist: "a", Track: "b", Time: time.Unix(100, 0)}}
+
+	if err := l.Scrobble(listens); err == nil || !retryable(err) {
+		t.Errorf("Expected an unavailable service to be retried, got %v", err)
+	}
+
+	if err := l.Scrobble(listens); err == nil || retryable(err) {
+		t.Errorf("Expected invalid parameters not to be retried, got %v", err)
+	}
+}
+
+func TestListenBrainzSubmitsListens(t *testing.T) {
+	requests := make(chan listenBrainzRequest, 10)
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/compat/1/submit-listens" || r.Header.Get("Authorization") != "Token token" {
+			t.Errorf("Unexpected request %s %v", r.URL.Path, r.Header)
+		}
+
+		var req listenBrainzRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests <- req
+
+		w.WriteHeader(status)
+		w.Write([]byte(`{"code":400,"error":"bad listen"}`))
+	}))
+	defer server.Close()
+
+	l := newListenBrainz(config.ListenBrainzConfig{URL: server.URL + "/compat/", Token: "token"})
+	listen := Listen{Artist: "Daft Punk", Track: "Around the World", Time: time.Unix(100, 0), Provider: "youtube", ID: "abc"}
+
+	if err := l.NowPlaying(listen); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := <-requests; r.ListenType != listenPlayingNow || r.Payload[0].ListenedAt != 0 {
+		t.Errorf("Unexpected now playing %+v", r)
+	}
+
+	if err := l.Scrobble([]Listen{listen, listen}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-requests
+	if r.ListenType != listenImport || len(r.Payload) != 2 || r.Payload[0].ListenedAt != 100 || r.Payload[0].Metadata.Artist != "Daft Punk" {
+		t.Errorf("Unexpected import %+v", r)
+	}
+
+	if r.Payload[0].Metadata.AdditionalInfo["origin_url"] != "https://www.youtube.com/watch?v=abc" {
+		t.Errorf("Expected the origin url, got %v", r.Payload[0].Metadata.AdditionalInfo)
+	}
+
+	status = http.StatusBadRequest
+	if err := l.Scrobble([]Listen{listen}); err == nil || retryable(err) {
+		t.Errorf("Expected bad listens not to be retried, got %v", err)
+	}
+}
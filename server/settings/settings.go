@@ -0,0 +1,59 @@
+package settings
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/player"
+	"sync"
+
+	"github.com/asdine/storm"
+)
+
+var (
+	db    *storm.DB
+	mutex sync.Mutex
+)
+
+// Settings are the choices made for a zone which have to survive a restart
+type Settings struct {
+	Zone string `json:"zone" storm:"id"`
+	// Output is the libvlc audio output module, e.g. alsa or pulse, empty for the libvlc default
+	Output string `json:"output"`
+	// OutputDevice is the device of the output module, empty for the default device of the module
+	OutputDevice string `json:"outputDevice"`
+	// Normalization is the loudness normalization mode, empty until it's changed
+	Normalization string `json:"normalization"`
+	// Equalizer is nil while the equalizer is off
+	Equalizer *player.Equalizer `json:"equalizer"`
+	// FadeLength is in milliseconds, nil until it's changed
+	FadeLength *int `json:"fadeLength"`
+}
+
+func Init() error {
+	db = database.Get()
+	return db.Init(Settings{})
+}
+
+// Get returns the settings of a zone, zones without saved settings get the defaults
+func Get(zone string) (Settings, error) {
+	s := Settings{Zone: zone}
+	if err := db.One("Zone", zone, &s); err != nil && err != storm.ErrNotFound {
+		return Settings{}, err
+	}
+
+	return s, nil
+}
+
+// Update loads the settings of a zone, passes them to change and saves the result
+func Update(zone string, change func(s *Settings)) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	s, err := Get(zone)
+	if err != nil {
+		return err
+	}
+
+	change(&s)
+	s.Zone = zone
+	return db.Save(&s)
+}
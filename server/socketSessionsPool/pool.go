@@ -1,21 +1,48 @@
 package socketSessionsPool
 
 import (
+	"log"
 	"sync"
+	"time"
 
 	"gopkg.in/igm/sockjs-go.v2/sockjs"
 )
 
+type Options struct {
+	// Snapshot returns the messages sent to every session as soon as it is added
+	Snapshot func() ([]string, error)
+
+	// Heartbeat returns the message sent to every session on each HeartbeatInterval,
+	// no heartbeats are sent when it's nil and an empty message is skipped
+	Heartbeat         func() (string, error)
+	HeartbeatInterval time.Duration
+}
+
 type SocketSessionsPool struct {
 	sync.Mutex
 
-	sessions []sockjs.Session
+	sessions  []sockjs.Session
+	options   Options
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 func New() *SocketSessionsPool {
-	return &SocketSessionsPool{
+	return NewWithOptions(Options{})
+}
+
+func NewWithOptions(o Options) *SocketSessionsPool {
+	p := &SocketSessionsPool{
 		sessions: make([]sockjs.Session, 0),
+		options:  o,
+		closed:   make(chan struct{}),
+	}
+
+	if o.Heartbeat != nil && o.HeartbeatInterval > 0 {
+		go p.heartbeat()
 	}
+
+	return p
 }
 
 func (p *SocketSessionsPool) Add(s sockjs.Session) {
@@ -23,6 +50,39 @@ func (p *SocketSessionsPool) Add(s sockjs.Session) {
 	defer p.Unlock()
 
 	p.sessions = append(p.sessions, s)
+	p.sendSnapshot(s)
+}
+
+// Handle adds the session and reads from it until it gets closed, then removes it from the pool.
+// Every received message is passed to onMessage, which can be nil
+func (p *SocketSessionsPool) Handle(s sockjs.Session, onMessage func(msg string)) {
+	p.Add(s)
+	defer p.Remove(s)
+
+	for {
+		msg, err := s.Recv()
+		if err != nil {
+			return
+		}
+
+		if onMessage != nil {
+			onMessage(msg)
+		}
+	}
+}
+
+func (p *SocketSessionsPool) Remove(s sockjs.Session) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.remove(s)
+}
+
+func (p *SocketSessionsPool) Count() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return len(p.sessions)
 }
 
 func (p *SocketSessionsPool) Send(payload string) {
@@ -39,11 +99,74 @@ func (p *SocketSessionsPool) Send(payload string) {
 	}
 
 	for _, s := range sessionsToRemove {
-		for i, ss := range p.sessions {
-			if ss == s {
-				p.sessions = append(p.sessions[:i], p.sessions[i+1:]...)
-				break
-			}
+		p.remove(s)
+	}
+}
+
+// Close closes and removes all sessions and stops the heartbeats, the pool can't be used afterwards
+func (p *SocketSessionsPool) Close(status uint32, reason string) {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
+
+	p.Lock()
+	defer p.Unlock()
+
+	for _, s := range p.sessions {
+		s.Close(status, reason)
+	}
+
+	p.sessions = make([]sockjs.Session, 0)
+}
+
+func (p *SocketSessionsPool) remove(s sockjs.Session) {
+	for i, ss := range p.sessions {
+		if ss == s {
+			p.sessions = append(p.sessions[:i], p.sessions[i+1:]...)
+			break
+		}
+	}
+}
+
+func (p *SocketSessionsPool) sendSnapshot(s sockjs.Session) {
+	if p.options.Snapshot == nil {
+		return
+	}
+
+	messages, err := p.options.Snapshot()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, msg := range messages {
+		if err := s.Send(msg); err != nil {
+			s.Close(1, "failed to send")
+			p.remove(s)
+			return
+		}
+	}
+}
+
+func (p *SocketSessionsPool) heartbeat() {
+	t := time.NewTicker(p.options.HeartbeatInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-p.closed:
+			return
+		}
+
+		msg, err := p.options.Heartbeat()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if msg != "" {
+			p.Send(msg)
 		}
 	}
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"gngeorgiev/audiotic/server/controlProtocol"
+	"gngeorgiev/audiotic/server/eventStream"
+	"gngeorgiev/audiotic/server/hub"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+var sseKeepAliveInterval = 15 * time.Second
+
+func writeSSE(w io.Writer, id uint64, e controlProtocol.Event) error {
+	data, err := controlProtocol.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, e.Type, data)
+	return err
+}
+
+// lastEventID reads the id the client has seen last, browsers send it as a header on reconnect,
+// other clients can use the lastEventId query param instead
+func lastEventID(c *gin.Context) (uint64, bool) {
+	raw := c.Request.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("lastEventId")
+	}
+
+	if raw == "" {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(raw, 10, 64)
+	return id, err == nil
+}
+
+// playerEventsHandler streams the same events as the control protocol, of the zone, as server-sent events.
+// Clients resuming with a Last-Event-ID get the events they missed, if they are still buffered,
+// otherwise they get a snapshot of the current state
+func playerEventsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stream, err := eventStream.For(zoneName(c))
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		events, unsubscribe := stream.Subscribe(hub.Options{Name: "sse", BufferSize: 64, Policy: hub.DropOldest})
+		defer unsubscribe()
+
+		sent, err := replayEvents(c, stream)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		c.Writer.Flush()
+
+		keepAlive := time.NewTicker(sseKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		closed := c.Writer.CloseNotify()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-stream.Done():
+				return
+			case <-keepAlive.C:
+				if _, err := io.WriteString(c.Writer, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case msg, ok := <-events:
+				if !ok {
+					return
+				}
+
+				e := msg.(eventStream.Event)
+				if e.ID <= sent {
+					continue
+				}
+
+				if err := writeSSE(c.Writer, e.ID, e.Event); err != nil {
+					log.Println(err)
+					return
+				}
+				sent = e.ID
+			}
+
+			c.Writer.Flush()
+		}
+	}
+}
+
+// replayEvents writes the missed events or the current state and returns the id of the last written event
+func replayEvents(c *gin.Context, stream *eventStream.Stream) (uint64, error) {
+	if id, ok := lastEventID(c); ok {
+		if missed, ok := stream.Since(id); ok {
+			for _, e := range missed {
+				if err := writeSSE(c.Writer, e.ID, e.Event); err != nil {
+					return 0, err
+				}
+				id = e.ID
+			}
+
+			return id, nil
+		}
+	}
+
+	lastID := stream.LastID()
+	snapshot, err := stream.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range snapshot {
+		if err := writeSSE(c.Writer, lastID, e); err != nil {
+			return 0, err
+		}
+	}
+
+	return lastID, nil
+}
@@ -0,0 +1,75 @@
+package trackStore
+
+import (
+	"gngeorgiev/audiotic/server/database"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+var db *storm.DB
+
+// Value is a number remembered for a track, e.g. its loudness or the rate it's played at
+type Value struct {
+	// ID is kind/provider/id
+	ID        string    `json:"-" storm:"id"`
+	Kind      string    `json:"kind"`
+	Provider  string    `json:"provider"`
+	TrackID   string    `json:"trackId"`
+	Value     float64   `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Store keeps the values of a kind by the provider and the id of their tracks
+type Store struct {
+	kind string
+}
+
+func Init() error {
+	db = database.Get()
+	return db.Init(Value{})
+}
+
+// New returns the store of a kind of values, the stores of the different kinds don't see each other's values
+func New(kind string) Store {
+	return Store{kind: kind}
+}
+
+func (s Store) valueID(provider, id string) string {
+	return s.kind + "/" + strings.ToLower(provider) + "/" + id
+}
+
+// Get returns the value of a track, false is returned when there is none
+func (s Store) Get(provider, id string) (float64, bool, error) {
+	var v Value
+	if err := db.One("ID", s.valueID(provider, id), &v); err != nil {
+		if err == storm.ErrNotFound {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	return v.Value, true, nil
+}
+
+func (s Store) Save(provider, id string, value float64) error {
+	return db.Save(&Value{
+		ID:        s.valueID(provider, id),
+		Kind:      s.kind,
+		Provider:  provider,
+		TrackID:   id,
+		Value:     value,
+		UpdatedAt: time.Now(),
+	})
+}
+
+// Remove forgets the value of a track
+func (s Store) Remove(provider, id string) error {
+	if err := db.Remove(&Value{ID: s.valueID(provider, id)}); err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
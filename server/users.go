@@ -0,0 +1,156 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+type createUserRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type createTokenRequest struct {
+	Name string `json:"name"`
+}
+
+type createTokenResponse struct {
+	Token string      `json:"token"`
+	Info  *auth.Token `json:"info"`
+}
+
+func intParam(c *gin.Context, name string) (int, bool) {
+	v, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		abortWithApiError(c, &api.Error{
+			Code:    api.CodeInvalidArgument,
+			Message: name + " must be a number",
+			Details: map[string]interface{}{"field": name},
+		})
+		return 0, false
+	}
+
+	return v, true
+}
+
+func v2MeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := auth.CurrentUser(c)
+		if u == nil {
+			c.JSON(http.StatusOK, gin.H{"authEnabled": false, "role": auth.RoleAdmin})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"authEnabled": true, "user": u, "role": u.Role})
+	}
+}
+
+func v2GetUsersHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		users, err := auth.Users()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+func v2CreateUserHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req createUserRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		u, err := auth.CreateUser(req.Name, req.Password, req.Role)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, u)
+	}
+}
+
+func v2DeleteUserHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		if err := auth.DeleteUser(id); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2GetTokensHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		tokens, err := auth.Tokens(id)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+func v2CreateTokenHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var req createTokenRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		plain, t, err := auth.CreateToken(id, req.Name)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, createTokenResponse{Token: plain, Info: t})
+	}
+}
+
+func v2DeleteTokenHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		tokenID, ok := intParam(c, "tokenId")
+		if !ok {
+			return
+		}
+
+		if err := auth.DeleteToken(id, tokenID); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
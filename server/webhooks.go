@@ -0,0 +1,103 @@
+package main
+
+import (
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/auth"
+	"net/http"
+
+	"gopkg.in/gin-gonic/gin.v1"
+)
+
+func v2GetWebhooksHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hooks, err := api.Webhooks()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, hooks)
+	}
+}
+
+func v2CreateWebhookHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req api.WebhookRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.URL == "" {
+			abortWithApiError(c, missingField("url"))
+			return
+		}
+
+		w, err := api.CreateWebhook(req, auth.CallerOf(c).Name)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, w)
+	}
+}
+
+func v2UpdateWebhookHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var req api.WebhookRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.URL == "" {
+			abortWithApiError(c, missingField("url"))
+			return
+		}
+
+		w, err := api.UpdateWebhook(id, req)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+func v2DeleteWebhookHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		if err := api.RemoveWebhook(id); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func v2WebhookDeliveriesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := intParam(c, "id")
+		if !ok {
+			return
+		}
+
+		deliveries, err := api.WebhookDeliveries(id)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, deliveries)
+	}
+}
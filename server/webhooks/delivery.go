@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// the headers of the deliveries, the signature is the hex HMAC-SHA256 of the body keyed with the secret
+const (
+	SignatureHeader = "X-Audiotic-Signature"
+	EventHeader     = "X-Audiotic-Event"
+	DeliveryHeader  = "X-Audiotic-Delivery"
+
+	signaturePrefix  = "sha256="
+	maxResponseBytes = 64 * 1024
+)
+
+var (
+	client = &http.Client{Timeout: 10 * time.Second}
+
+	// maxAttempts and retryDelay bound the retries of a delivery, the delay doubles after every attempt
+	maxAttempts = 5
+	retryDelay  = 2 * time.Second
+)
+
+// Sign returns the signature of a body as sent in the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch posts the event to every webhook which wants it, in the background
+func Dispatch(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	hooks, err := all()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, w := range hooks {
+		if !w.Wants(e) {
+			continue
+		}
+
+		go func(w Webhook) {
+			deliver(w, e, body, saveDelivery)
+			if err := prune(w.ID); err != nil {
+				log.Println(err)
+			}
+		}(w)
+	}
+}
+
+// retryable tells whether a failed attempt may succeed later, the webhooks rejecting the payload aren't retried
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode >= http.StatusInternalServerError ||
+		statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout
+}
+
+// deliver posts the body until the webhook accepts it or the attempts run out,
+// record is called before the first attempt, which assigns the ID of the delivery, and after every attempt
+func deliver(w Webhook, e Event, body []byte, record func(d *Delivery) error) *Delivery {
+	d := &Delivery{
+		WebhookID: w.ID,
+		Event:     e.Type,
+		Zone:      e.Zone,
+		CreatedAt: time.Now(),
+	}
+	d.UpdatedAt = d.CreatedAt
+
+	if err := record(d); err != nil {
+		log.Println(err)
+	}
+
+	delay := retryDelay
+	for {
+		d.Attempts++
+		statusCode, err := post(w, d, body)
+
+		d.StatusCode, d.Success, d.Error = statusCode, err == nil, ""
+		if err != nil {
+			d.Error = err.Error()
+		}
+		d.UpdatedAt = time.Now()
+
+		if err := record(d); err != nil {
+			log.Println(err)
+		}
+
+		if d.Success || !retryable(statusCode) || d.Attempts >= maxAttempts {
+			return d
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func post(w Webhook, d *Delivery, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "audiotic-webhooks")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxResponseBytes))
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return res.StatusCode, fmt.Errorf("Webhook responded with %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+)
+
+// Transitions returns the events of a change of the status of a zone from last to status, in the order they happened.
+// The skipped event is about the track of last, the other ones about the track of status
+func Transitions(last, status *player.VlcStatus) []string {
+	if status == nil {
+		return nil
+	}
+
+	if last == nil {
+		if status.Source == "" || models.IsStoppedState(status.State) {
+			return nil
+		}
+
+		return []string{EventTrackStarted}
+	}
+
+	var res []string
+	if status.Source != last.Source && status.Source != "" {
+		// nothing is skipped when nothing was playing
+		if last.Source != "" && !models.IsStoppedState(last.State) {
+			res = append(res, EventTrackSkipped)
+		}
+
+		res = append(res, EventTrackStarted)
+	}
+
+	if status.State != last.State {
+		switch status.State {
+		case models.StateEnded:
+			res = append(res, EventTrackEnded)
+		case models.StatePaused:
+			res = append(res, EventPaused)
+		case models.StateError:
+			res = append(res, EventError)
+		}
+	}
+
+	return res
+}
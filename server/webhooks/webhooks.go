@@ -0,0 +1,248 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gngeorgiev/audiotic/server/database"
+	"gngeorgiev/audiotic/server/models"
+	"gngeorgiev/audiotic/server/player"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+// the events sent to the webhooks
+const (
+	EventTrackStarted = "track.started"
+	EventTrackEnded   = "track.ended"
+	// EventTrackSkipped is sent for a track replaced by another one before it ended
+	EventTrackSkipped = "track.skipped"
+	EventPaused       = "player.paused"
+	EventError        = "error"
+	EventHistoryAdded = "history.added"
+
+	secretBytes = 32
+	// keptDeliveries is the length of the delivery log of a webhook, the older deliveries are dropped
+	keptDeliveries = 100
+)
+
+var (
+	db *storm.DB
+
+	// ErrNotFound is returned for unknown webhooks
+	ErrNotFound = storm.ErrNotFound
+
+	Events = []string{EventTrackStarted, EventTrackEnded, EventTrackSkipped, EventPaused, EventError, EventHistoryAdded}
+)
+
+// Webhook is a url the events are posted to
+type Webhook struct {
+	ID  int    `json:"id" storm:"id,increment"`
+	URL string `json:"url"`
+	// Secret signs the payloads, it's visible only in the response creating the webhook
+	Secret string `json:"secret,omitempty"`
+	// Events are the events the webhook receives, all of them when it's empty
+	Events []string `json:"events"`
+	// Zone limits the events to the ones of a zone, the history has no zone so its events are always sent
+	Zone      string    `json:"zone"`
+	Enabled   bool      `json:"enabled"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Delivery is an entry of the delivery log, it's updated after every attempt
+type Delivery struct {
+	ID        int    `json:"id" storm:"id,increment"`
+	WebhookID int    `json:"webhookId" storm:"index"`
+	Event     string `json:"event"`
+	Zone      string `json:"zone,omitempty"`
+	Attempts  int    `json:"attempts"`
+	// StatusCode is the status of the response to the last attempt, 0 when there was no response
+	StatusCode int       `json:"statusCode"`
+	Error      string    `json:"error,omitempty"`
+	Success    bool      `json:"success"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Event is the payload posted to the webhooks
+type Event struct {
+	Type   string            `json:"type"`
+	Zone   string            `json:"zone,omitempty"`
+	Time   time.Time         `json:"time"`
+	Track  *models.Track     `json:"track,omitempty"`
+	Status *player.VlcStatus `json:"status,omitempty"`
+	// Error is the api error of the error events
+	Error interface{} `json:"error,omitempty"`
+}
+
+func Init() error {
+	db = database.Get()
+	if err := db.Init(Webhook{}); err != nil {
+		return err
+	}
+
+	return db.Init(Delivery{})
+}
+
+// IsEvent tells whether s is one of Events
+func IsEvent(s string) bool {
+	for _, e := range Events {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wants tells whether the webhook receives the event
+func (w Webhook) Wants(e Event) bool {
+	if !w.Enabled || (w.Zone != "" && e.Zone != "" && w.Zone != e.Zone) {
+		return false
+	}
+
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, t := range w.Events {
+		if t == e.Type {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewSecret generates a random secret for signing the payloads
+func NewSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func all() ([]Webhook, error) {
+	var res []Webhook
+	if err := db.All(&res); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if res == nil {
+		res = make([]Webhook, 0)
+	}
+
+	return res, nil
+}
+
+// All returns the webhooks without their secrets
+func All() ([]Webhook, error) {
+	res, err := all()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range res {
+		res[i].Secret = ""
+	}
+
+	return res, nil
+}
+
+// Get returns a webhook without its secret
+func Get(id int) (Webhook, error) {
+	var w Webhook
+	if err := db.One("ID", id, &w); err != nil {
+		return Webhook{}, err
+	}
+
+	w.Secret = ""
+	return w, nil
+}
+
+// Save creates the webhook, it gets its ID assigned
+func Save(w *Webhook) error {
+	return db.Save(w)
+}
+
+// Update saves an existing webhook, its current secret is kept when w has none.
+// The secret isn't returned in w either way
+func Update(w *Webhook) error {
+	var current Webhook
+	if err := db.One("ID", w.ID, &current); err != nil {
+		return err
+	}
+
+	if w.Secret == "" {
+		w.Secret = current.Secret
+	}
+
+	if err := db.Save(w); err != nil {
+		return err
+	}
+
+	w.Secret = ""
+	return nil
+}
+
+// Remove removes the webhook and its delivery log
+func Remove(id int) error {
+	var w Webhook
+	if err := db.One("ID", id, &w); err != nil {
+		return err
+	}
+
+	if err := db.Remove(&w); err != nil {
+		return err
+	}
+
+	deliveries, err := Deliveries(id)
+	if err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		if err := db.Remove(&deliveries[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliveries returns the delivery log of a webhook, the latest delivery first
+func Deliveries(webhookID int) ([]Delivery, error) {
+	var res []Delivery
+	if err := db.Find("WebhookID", webhookID, &res, storm.Reverse()); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if res == nil {
+		res = make([]Delivery, 0)
+	}
+
+	return res, nil
+}
+
+func saveDelivery(d *Delivery) error {
+	return db.Save(d)
+}
+
+// prune drops the deliveries of a webhook beyond keptDeliveries
+func prune(webhookID int) error {
+	deliveries, err := Deliveries(webhookID)
+	if err != nil || len(deliveries) <= keptDeliveries {
+		return err
+	}
+
+	for i := range deliveries[keptDeliveries:] {
+		if err := db.Remove(&deliveries[keptDeliveries+i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/player"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransitions(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		last, status *player.VlcStatus
+		events       []string
+	}{
+		{"first status", nil, &player.VlcStatus{Source: "a", State: "playing"}, []string{EventTrackStarted}},
+		{"first status stopped", nil, &player.VlcStatus{Source: "a", State: "stopped"}, nil},
+		{"same track", &player.VlcStatus{Source: "a", State: "playing"}, &player.VlcStatus{Source: "a", State: "playing"}, nil},
+		{"paused", &player.VlcStatus{Source: "a", State: "playing"}, &player.VlcStatus{Source: "a", State: "paused"}, []string{EventPaused}},
+		{"ended", &player.VlcStatus{Source: "a", State: "playing"}, &player.VlcStatus{Source: "a", State: "ended"}, []string{EventTrackEnded}},
+		{"error", &player.VlcStatus{Source: "a", State: "playing"}, &player.VlcStatus{Source: "a", State: "error"}, []string{EventError}},
+		{"next after end", &player.VlcStatus{Source: "a", State: "ended"}, &player.VlcStatus{Source: "b", State: "openning"}, []string{EventTrackStarted}},
+		{"skipped", &player.VlcStatus{Source: "a", State: "playing"}, &player.VlcStatus{Source: "b", State: "openning"}, []string{EventTrackSkipped, EventTrackStarted}},
+	} {
+		if events := Transitions(c.last, c.status); !reflect.DeepEqual(events, c.events) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.events, events)
+		}
+	}
+}
+
+func TestWantsFiltersEventsAndZones(t *testing.T) {
+	w := Webhook{Enabled: true, Zone: "kitchen", Events: []string{EventTrackStarted}}
+	if !w.Wants(Event{Type: EventTrackStarted, Zone: "kitchen"}) {
+		t.Error("Expected the webhook to want the started events of its zone")
+	}
+
+	if w.Wants(Event{Type: EventTrackStarted, Zone: "default"}) || w.Wants(Event{Type: EventPaused, Zone: "kitchen"}) {
+		t.Error("Expected the webhook to skip other zones and events")
+	}
+
+	w.Enabled = false
+	if w.Wants(Event{Type: EventTrackStarted, Zone: "kitchen"}) {
+		t.Error("Expected disabled webhooks to want nothing")
+	}
+}
+
+func TestDeliverRetriesAndSigns(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	body := []byte(`{"type":"track.started"}`)
+
+	var mutex sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != string(body) {
+			t.Errorf("Expected body %s, got %s", body, b)
+		}
+
+		mutex.Lock()
+		requests = append(requests, r)
+		n := len(requests)
+		mutex.Unlock()
+
+		if n < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	var recorded []Delivery
+	record := func(d *Delivery) error {
+		if d.ID == 0 {
+			d.ID = 7
+		}
+
+		recorded = append(recorded, *d)
+		return nil
+	}
+
+	w := Webhook{ID: 1, URL: server.URL, Secret: "secret", Enabled: true}
+	d := deliver(w, Event{Type: EventTrackStarted}, body, record)
+
+	if !d.Success || d.Attempts != 3 || d.StatusCode != http.StatusOK || d.Error != "" {
+		t.Fatalf("Expected a successful third attempt, got %+v", d)
+	}
+
+	if len(recorded) != 4 {
+		t.Errorf("Expected the delivery recorded before and after every attempt, got %d records", len(recorded))
+	}
+
+	for _, r := range requests {
+		if s := r.Header.Get(SignatureHeader); s != Sign("secret", body) {
+			t.Errorf("Expected signature %s, got %s", Sign("secret", body), s)
+		}
+
+		if r.Header.Get(EventHeader) != EventTrackStarted || r.Header.Get(DeliveryHeader) != strconv.Itoa(7) {
+			t.Errorf("Unexpected headers %v", r.Header)
+		}
+	}
+}
+
+func TestDeliverDoesntRetryRejectedPayloads(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	d := deliver(Webhook{URL: server.URL}, Event{Type: EventPaused}, []byte("{}"), func(*Delivery) error { return nil })
+	if d.Success || d.Attempts != 1 || d.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a single failed attempt, got %+v", d)
+	}
+}
+
+func TestEventPayload(t *testing.T) {
+	b, err := json.Marshal(Event{Type: EventHistoryAdded, Time: time.Unix(0, 0).UTC()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != `{"type":"history.added","time":"1970-01-01T00:00:00Z"}` {
+		t.Errorf("Unexpected payload %s", b)
+	}
+}
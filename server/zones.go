@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"gngeorgiev/audiotic/server/api"
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/controlProtocol"
+	"gngeorgiev/audiotic/server/eventStream"
+	"gngeorgiev/audiotic/server/hub"
+	"gngeorgiev/audiotic/server/socketSessionsPool"
+	"gngeorgiev/audiotic/server/zones"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gin-gonic/gin.v1"
+	"gopkg.in/igm/sockjs-go.v2/sockjs"
+)
+
+// zoneSessions are the sockjs sessions following a zone, the raw status is pushed to the v1 sessions
+// and the typed events of the control protocol to the v2 sessions
+type zoneSessions struct {
+	stream  *eventStream.Stream
+	updates *socketSessionsPool.SocketSessionsPool
+	control *socketSessionsPool.SocketSessionsPool
+}
+
+var (
+	zoneSessionsMutex sync.Mutex
+	sessionsByZone    = make(map[string]*zoneSessions)
+
+	heartbeatInterval = 15 * time.Second
+)
+
+// zoneName returns the zone of the zone scoped routes, the other routes control the default zone
+func zoneName(c *gin.Context) string {
+	if zone := c.Param("zone"); zone != "" {
+		return zone
+	}
+
+	return zones.Default
+}
+
+// sessionsOf returns the sessions of a zone, the pools are created on first use and their sessions are closed
+// once the zone is removed
+func sessionsOf(zone string) (*zoneSessions, error) {
+	stream, err := eventStream.For(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	zoneSessionsMutex.Lock()
+	defer zoneSessionsMutex.Unlock()
+
+	if s, ok := sessionsByZone[zone]; ok && s.stream == stream {
+		return s, nil
+	}
+
+	s := &zoneSessions{stream: stream}
+	s.updates = socketSessionsPool.NewWithOptions(socketSessionsPool.Options{
+		Snapshot:          s.statusSnapshot,
+		Heartbeat:         s.statusHeartbeat,
+		HeartbeatInterval: heartbeatInterval,
+	})
+	s.control = socketSessionsPool.NewWithOptions(socketSessionsPool.Options{
+		Snapshot:          s.controlSnapshot,
+		Heartbeat:         s.controlHeartbeat,
+		HeartbeatInterval: heartbeatInterval,
+	})
+	sessionsByZone[zone] = s
+
+	go s.forward(zone)
+	return s, nil
+}
+
+func (s *zoneSessions) forward(zone string) {
+	events, unsubscribe := s.stream.Subscribe(hub.Options{Name: "sockjs", BufferSize: 64, Policy: hub.DropOldest})
+	defer unsubscribe()
+
+	for {
+		select {
+		case msg := <-events:
+			event := msg.(eventStream.Event)
+			if event.Type == controlProtocol.TypeStatus {
+				b, err := json.Marshal(event.Data)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+
+				s.updates.Send(string(b))
+			}
+
+			payload, err := controlProtocol.Marshal(event.Event)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			s.control.Send(payload)
+		case <-s.stream.Done():
+			zoneSessionsMutex.Lock()
+			if sessionsByZone[zone] == s {
+				delete(sessionsByZone, zone)
+			}
+			zoneSessionsMutex.Unlock()
+
+			s.updates.Close(4404, "zone removed")
+			s.control.Close(4404, "zone removed")
+			return
+		}
+	}
+}
+
+func (s *zoneSessions) count() int {
+	return s.updates.Count() + s.control.Count()
+}
+
+func (s *zoneSessions) statusSnapshot() ([]string, error) {
+	events, err := s.stream.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		if e.Type == controlProtocol.TypeStatus {
+			b, err := json.Marshal(e.Data)
+			if err != nil {
+				return nil, err
+			}
+
+			return []string{string(b)}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// statusHeartbeat sends the status again, the v1 clients understand only the status messages
+func (s *zoneSessions) statusHeartbeat() (string, error) {
+	messages, err := s.statusSnapshot()
+	if err != nil || len(messages) == 0 {
+		return "", err
+	}
+
+	return messages[0], nil
+}
+
+func (s *zoneSessions) controlSnapshot() ([]string, error) {
+	events, err := s.stream.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]string, len(events))
+	for i, e := range events {
+		msg, err := controlProtocol.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+
+		messages[i] = msg
+	}
+
+	return messages, nil
+}
+
+func (s *zoneSessions) controlHeartbeat() (string, error) {
+	return controlProtocol.Marshal(controlProtocol.NewEvent(controlProtocol.TypeHeartbeat, controlProtocol.Heartbeat{
+		Time:    time.Now().Unix(),
+		Clients: s.count(),
+	}))
+}
+
+// connectedClients returns the number of sockjs sessions following a zone
+func connectedClients(zone string) int {
+	zoneSessionsMutex.Lock()
+	defer zoneSessionsMutex.Unlock()
+
+	if s, ok := sessionsByZone[zone]; ok {
+		return s.count()
+	}
+
+	return 0
+}
+
+// zoneSockJSHandler serves a sockjs endpoint for every zone. The sockjs handlers are created on first use,
+// the prefix they match contains the name of the zone on the zone scoped routes
+func zoneSockJSHandler(prefix string, serve func(s *zoneSessions, zone string, session sockjs.Session)) gin.HandlerFunc {
+	type zoneHandler struct {
+		sessions *zoneSessions
+		handler  http.Handler
+	}
+
+	mutex := sync.Mutex{}
+	handlers := make(map[string]zoneHandler)
+
+	return func(c *gin.Context) {
+		zone := zoneName(c)
+		sessions, err := sessionsOf(zone)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		mutex.Lock()
+		h, ok := handlers[zone]
+		if !ok || h.sessions != sessions {
+			h = zoneHandler{
+				sessions: sessions,
+				handler: sockjs.NewHandler(strings.Replace(prefix, ":zone", zone, 1), sockjs.DefaultOptions, func(session sockjs.Session) {
+					serve(sessions, zone, session)
+				}),
+			}
+			handlers[zone] = h
+		}
+		mutex.Unlock()
+
+		h.handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func v2GetZonesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := api.Zones()
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func v2CreateZoneHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req config.ZoneConfig
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		if req.Name == "" {
+			abortWithApiError(c, missingField("name"))
+			return
+		}
+
+		if req.Volume < 0 || req.Volume > api.MaxVolume {
+			abortWithApiError(c, &api.Error{
+				Code:    api.CodeInvalidArgument,
+				Message: "Invalid volume",
+				Details: map[string]interface{}{"field": "volume", "min": api.MinVolume, "max": api.MaxVolume},
+			})
+			return
+		}
+
+		z, err := api.CreateZone(req)
+		if err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, z)
+	}
+}
+
+func v2DeleteZoneHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.RemoveZone(c.Param("zone")); err != nil {
+			abortWithApiError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
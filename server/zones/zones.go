@@ -0,0 +1,215 @@
+package zones
+
+import (
+	"gngeorgiev/audiotic/server/config"
+	"gngeorgiev/audiotic/server/player"
+	"gngeorgiev/audiotic/server/queue"
+	"gngeorgiev/audiotic/server/settings"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+// Default is the zone served by the routes which don't name a zone
+const Default = "default"
+
+var (
+	ErrInvalidName = errors.New("Zone names can contain only lower case letters, digits, - and _")
+	ErrExists      = errors.New("Zone already exists")
+	ErrNotFound    = errors.New("Zone not found")
+	ErrDefault     = errors.New("The default zone can't be removed")
+
+	mutex sync.Mutex
+	zones = make(map[string]*Zone)
+	// creating are the names of the zones being started, they're reserved so the players start without the lock
+	creating  = make(map[string]bool)
+	validName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+	// start and release manage the player of a zone, the tests replace them as they run without libvlc
+	start   = startZone
+	release = func(z *Zone) error { return z.Player.Release() }
+)
+
+// Zone is a player with its own queue, the zones play independently of each other
+type Zone struct {
+	Name   string
+	Player *player.VlcPlayer
+	Queue  *queue.Queue
+
+	done chan struct{}
+}
+
+// Done is closed once the zone is removed, whatever follows the zone should stop then
+func (z *Zone) Done() <-chan struct{} {
+	return z.done
+}
+
+type byName []*Zone
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// Init creates the default zone and the configured ones
+func Init(configs []config.ZoneConfig) error {
+	hasDefault := false
+	for _, c := range configs {
+		if c.Name == Default {
+			hasDefault = true
+		}
+
+		if _, err := Create(c); err != nil {
+			return err
+		}
+	}
+
+	if !hasDefault {
+		if _, err := Create(config.ZoneConfig{Name: Default}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Create starts a new zone, zones created at runtime are gone after a restart unless they are in the config too
+func Create(c config.ZoneConfig) (*Zone, error) {
+	if !validName.MatchString(c.Name) {
+		return nil, ErrInvalidName
+	}
+
+	mutex.Lock()
+	if _, ok := zones[c.Name]; ok || creating[c.Name] {
+		mutex.Unlock()
+		return nil, ErrExists
+	}
+
+	creating[c.Name] = true
+	mutex.Unlock()
+
+	z, err := start(c)
+
+	mutex.Lock()
+	delete(creating, c.Name)
+	if err == nil {
+		zones[z.Name] = z
+	}
+	mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return z, nil
+}
+
+// startZone starts the player of a zone and sets it up
+func startZone(c config.ZoneConfig) (*Zone, error) {
+	s, err := settings.Get(c.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &Zone{
+		Name:   c.Name,
+		Player: player.New(c.Name),
+		Queue:  queue.New(c.Name),
+		done:   make(chan struct{}),
+	}
+
+	// the player of a zone which can't be set up is released, it'd keep its libvlc instance otherwise
+	if err := setUp(z, c, s); err != nil {
+		if releaseErr := release(z); releaseErr != nil {
+			log.Println(releaseErr)
+		}
+		return nil, err
+	}
+
+	return z, nil
+}
+
+// setUp applies the config of a zone and its saved settings, which take precedence, to its player
+func setUp(z *Zone, c config.ZoneConfig, s settings.Settings) error {
+	if c.Volume > 0 {
+		if err := z.Player.Volume(c.Volume); err != nil {
+			return err
+		}
+	}
+
+	output, device := c.Output, c.OutputDevice
+	if s.Output != "" || s.OutputDevice != "" {
+		output, device = s.Output, s.OutputDevice
+	}
+
+	if output != "" || device != "" {
+		if err := z.Player.SetAudioOutput(output, device); err != nil {
+			return err
+		}
+	}
+
+	if s.FadeLength != nil {
+		z.Player.SetFadeLength(time.Duration(*s.FadeLength) * time.Millisecond)
+	}
+
+	if s.Equalizer != nil {
+		if err := z.Player.SetEqualizer(s.Equalizer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func Get(name string) (*Zone, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	z, ok := zones[name]
+	return z, ok
+}
+
+// All returns the zones sorted by name
+func All() []*Zone {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	res := make([]*Zone, 0, len(zones))
+	for _, z := range zones {
+		res = append(res, z)
+	}
+
+	sort.Sort(byName(res))
+	return res
+}
+
+// Remove stops and releases the player of a zone
+func Remove(name string) error {
+	if name == Default {
+		return ErrDefault
+	}
+
+	mutex.Lock()
+	z, ok := zones[name]
+	delete(zones, name)
+	mutex.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	close(z.done)
+	return release(z)
+}
+
+// Release releases the players of all zones, used on shutdown
+func Release() {
+	for _, z := range All() {
+		if err := release(z); err != nil {
+			log.Println(err)
+		}
+	}
+}
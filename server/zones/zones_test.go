@@ -0,0 +1,182 @@
+package zones
+
+import (
+	"errors"
+	"gngeorgiev/audiotic/server/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePlayers replaces the players of the zones, it records the released zones
+type fakePlayers struct {
+	mutex    sync.Mutex
+	released []string
+}
+
+func (f *fakePlayers) release(z *Zone) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.released = append(f.released, z.Name)
+	return nil
+}
+
+func startFake(c config.ZoneConfig) (*Zone, error) {
+	return &Zone{Name: c.Name, done: make(chan struct{})}, nil
+}
+
+// withFakePlayers starts the zones without libvlc, the returned func restores the real players and forgets the zones
+func withFakePlayers(startFunc func(c config.ZoneConfig) (*Zone, error)) (*fakePlayers, func()) {
+	f := &fakePlayers{}
+	start, release = startFunc, f.release
+
+	return f, func() {
+		mutex.Lock()
+		zones = make(map[string]*Zone)
+		creating = make(map[string]bool)
+		mutex.Unlock()
+
+		start, release = startZone, func(z *Zone) error { return z.Player.Release() }
+	}
+}
+
+func TestCreateAndRemove(t *testing.T) {
+	f, restore := withFakePlayers(startFake)
+	defer restore()
+
+	if err := Init([]config.ZoneConfig{{Name: "kitchen"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	all := All()
+	if len(all) != 2 || all[0].Name != Default || all[1].Name != "kitchen" {
+		t.Fatalf("Expected the default zone and the kitchen, got %v", all)
+	}
+
+	if _, err := Create(config.ZoneConfig{Name: "kitchen"}); err != ErrExists {
+		t.Errorf("Expected the kitchen to exist, got %v", err)
+	}
+
+	if _, err := Create(config.ZoneConfig{Name: "Living Room"}); err != ErrInvalidName {
+		t.Errorf("Expected the name to be invalid, got %v", err)
+	}
+
+	kitchen, ok := Get("kitchen")
+	if !ok {
+		t.Fatal("Expected the kitchen to be found")
+	}
+
+	if err := Remove("kitchen"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-kitchen.Done():
+	default:
+		t.Error("Expected the removed zone to be done")
+	}
+
+	if len(f.released) != 1 || f.released[0] != "kitchen" {
+		t.Errorf("Expected the player of the kitchen to be released, got %v", f.released)
+	}
+
+	if _, ok := Get("kitchen"); ok {
+		t.Error("Expected the kitchen to be removed")
+	}
+
+	if err := Remove("kitchen"); err != ErrNotFound {
+		t.Errorf("Expected the kitchen not to be found, got %v", err)
+	}
+
+	if err := Remove(Default); err != ErrDefault {
+		t.Errorf("Expected the default zone to be kept, got %v", err)
+	}
+
+	if _, err := Create(config.ZoneConfig{Name: "kitchen"}); err != nil {
+		t.Errorf("Expected the kitchen to be created again, got %v", err)
+	}
+}
+
+func TestAFailedSetUpFreesTheName(t *testing.T) {
+	failed := errors.New("no such output")
+	fail := true
+	_, restore := withFakePlayers(func(c config.ZoneConfig) (*Zone, error) {
+		if fail {
+			return nil, failed
+		}
+
+		return startFake(c)
+	})
+	defer restore()
+
+	if z, err := Create(config.ZoneConfig{Name: "kitchen"}); err != failed || z != nil {
+		t.Fatalf("Expected the set up to fail, got %v %v", z, err)
+	}
+
+	if _, ok := Get("kitchen"); ok {
+		t.Fatal("Expected the zone which failed to be set up not to be published")
+	}
+
+	fail = false
+	if _, err := Create(config.ZoneConfig{Name: "kitchen"}); err != nil {
+		t.Fatalf("Expected the name to be free again, got %v", err)
+	}
+}
+
+func TestCreateDoesntHoldTheLockWhileStarting(t *testing.T) {
+	started, proceed := make(chan struct{}), make(chan struct{})
+	_, restore := withFakePlayers(func(c config.ZoneConfig) (*Zone, error) {
+		if c.Name == "kitchen" {
+			close(started)
+			<-proceed
+		}
+
+		return startFake(c)
+	})
+	defer restore()
+
+	created := make(chan error, 1)
+	go func() {
+		_, err := Create(config.ZoneConfig{Name: "kitchen"})
+		created <- err
+	}()
+
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if len(All()) != 0 {
+			t.Error("Expected the zone which is starting not to be listed")
+		}
+
+		if _, ok := Get("kitchen"); ok {
+			t.Error("Expected the zone which is starting not to be found")
+		}
+
+		if _, err := Create(config.ZoneConfig{Name: "kitchen"}); err != ErrExists {
+			t.Errorf("Expected the name of the zone which is starting to be reserved, got %v", err)
+		}
+
+		if _, err := Create(config.ZoneConfig{Name: "garden"}); err != nil {
+			t.Errorf("Expected another zone to be created meanwhile, got %v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the zones to be usable while a player starts")
+	}
+
+	close(proceed)
+	if err := <-created; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := Get("kitchen"); !ok {
+		t.Error("Expected the started zone to be published")
+	}
+}
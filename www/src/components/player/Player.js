@@ -1,7 +1,7 @@
 import React, { Component } from 'react';
 import './Player.css';
 
-import { ServerUrl, DefaultThumbnail } from '../../constants';
+import { ServerUrl, DefaultThumbnail, withToken } from '../../constants';
 import SockJS from 'sockjs-client'
 
 import Button from 'react-md/lib/Buttons/Button';
@@ -13,6 +13,7 @@ const DefaultVolume = 100;
 
 class Player extends Component {
     socket = null;
+    requestId = 0;
 
     state = {
         track: {},
@@ -50,10 +51,14 @@ class Player extends Component {
     }
 
     connectToSocket() {
-        this.socket = new SockJS(`${ServerUrl}/player/updates`);
+        this.socket = new SockJS(withToken(`${ServerUrl}/api/v2/player/updates`));
         this.socket.onmessage = ({ data }) => {
-            const status = JSON.parse(data);
-            this.updatePlayer(status);
+            const message = JSON.parse(data);
+            if (message.type === 'status') {
+                this.updatePlayer(message.data);
+            } else if (message.type === 'error' || (message.type === 'response' && message.error)) {
+                console.error(message.error || message.data);
+            }
         };
         this.socket.onclose = () => {
             this.onSocketDisconnected();
@@ -106,35 +111,37 @@ class Player extends Component {
         });
     }
 
+    send(method, params) {
+        if (!this.socket || this.socket.readyState !== SockJS.OPEN) {
+            console.error(`Not connected, cannot send ${method}`);
+            return;
+        }
+
+        this.socket.send(JSON.stringify({ id: ++this.requestId, method, params }));
+    }
+
     play(track) {
-        fetch(`${ServerUrl}/player/play/${track.provider}/${track.id}`)
-            .catch(err => console.error(err));
+        this.send('play', { provider: track.provider, id: track.id });
     }
 
     pause() {
-        fetch(`${ServerUrl}/player/pause`)
-            .catch(err => console.error(err));
+        this.send('pause');
     }
 
     stop() {
-        fetch(`${ServerUrl}/player/stop`)
-            .catch(err => console.error(err));
+        this.send('stop');
     }
 
     resume() {
-        fetch(`${ServerUrl}/player/resume`)
-            .then(() => this.setState({playing: true}))
-            .catch(err => console.error(err));
+        this.send('resume');
     }
 
     seek(time) {
-        fetch(`${ServerUrl}/player/seek/${time}`)
-            .catch(err => console.error(err));
+        this.send('seek', { time });
     }
 
     volume(vol) {
-        fetch(`${ServerUrl}/player/volume/${vol}`)
-            .catch(err => console.error(err));
+        this.send('volume', { volume: vol });
     }
 
     onSeek(ev, time) {
@@ -1,7 +1,7 @@
 import React, { Component } from 'react';
 import './Search.css';
 
-import { ServerUrl } from '../../constants';
+import { ServerUrl, withToken } from '../../constants';
 
 import List from 'react-md/lib/Lists/List';
 import ListItem from 'react-md/lib/Lists/ListItem';
@@ -42,7 +42,7 @@ class Search extends Component {
     }
 
     fetchHistory() {
-        fetch(`${ServerUrl}/history/get`)
+        fetch(withToken(`${ServerUrl}/history/get`))
             .then(response => response.json())
             .then(history => this.setState({history}))
             .catch(err => console.error(err));
@@ -1,2 +1,19 @@
 export const ServerUrl = `${window.location.protocol}//${window.location.hostname}:8090`;
-export const DefaultThumbnail = 'http://support.yumpu.com/en/wp-content/themes/qaengine/img/default-thumbnail.jpg';
\ No newline at end of file
+export const DefaultThumbnail = 'http://support.yumpu.com/en/wp-content/themes/qaengine/img/default-thumbnail.jpg';
+
+// the api token can be passed once as ?token=... and is remembered afterwards
+const tokenMatch = /[?&]token=([^&]+)/.exec(window.location.search);
+if (tokenMatch) {
+    window.localStorage.setItem('token', decodeURIComponent(tokenMatch[1]));
+}
+
+export const Token = window.localStorage.getItem('token') || '';
+
+export function withToken(url) {
+    if (!Token) {
+        return url;
+    }
+
+    const separator = url.indexOf('?') === -1 ? '?' : '&';
+    return `${url}${separator}token=${encodeURIComponent(Token)}`;
+}